@@ -4,8 +4,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"octlink/ovs/utils/httpresponse"
+	"octlink/ovs/utils/merrors"
 	"octlink/ovs/utils/octlog"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -26,11 +27,47 @@ const (
 	// ParamTypeBoolean boolean type param
 	ParamTypeBoolean = "boolean"
 
+	// ParamTypeIPv4 ipv4 address param like 10.0.0.1
+	ParamTypeIPv4 = "ipv4"
+
+	// ParamTypeCIDR ipv4 cidr param like 10.0.0.0/24
+	ParamTypeCIDR = "cidr"
+
+	// ParamTypeNetmask netmask param like 255.255.255.0
+	ParamTypeNetmask = "netmask"
+
+	// ParamTypeMac mac address param
+	ParamTypeMac = "mac"
+
+	// ParamTypePort port param in 1-65535
+	ParamTypePort = "port"
+
+	// ParamTypePortRange port or port range param like 80-90
+	ParamTypePortRange = "portrange"
+
+	// ParamTypeEnum string param in Values of ProtoPara
+	ParamTypeEnum = "enum"
+
+	// ParamTypeListObject list of objects described by Fields of ProtoPara
+	ParamTypeListObject = "listobject"
+
+	// ParamTypeObject free form json object param
+	ParamTypeObject = "object"
+
 	// ParamNotNull not null param
 	ParamNotNull = "NotNull"
 
+	// APIPrefix of api keys, followed by version, module and api name
+	APIPrefix = "octlink.virtualrouter"
+
+	// APIVersionV5 api version of current center releases
+	APIVersionV5 = "v5"
+
+	// APIVersionCurrent latest api version
+	APIVersionCurrent = APIVersionV5
+
 	// APIPrefixCenter API Prefix of Center API
-	APIPrefixCenter = "octlink.virtualrouter.v5"
+	APIPrefixCenter = APIPrefix + "." + APIVersionCurrent
 )
 
 var logger *octlog.LogConfig
@@ -55,14 +92,49 @@ type ProtoPara struct {
 	Default interface{} `json:"default"`
 	Type    string      `json:"type"`
 	Desc    string      `json:"desc"`
+	Values  []string    `json:"values,omitempty"`
+	Fields  []ProtoPara `json:"fields,omitempty"`
 }
 
 // Proto API proto structure
 type Proto struct {
 	Name    string      `json:"name"`
+	NameEN  string      `json:"nameEn"`
 	Key     string      `json:"key"`
 	Paras   []ProtoPara `json:"paras"`
 	handler func(*Paras) *Response
+
+	// Versions served, versions of module used if empty
+	Versions []string `json:"versions,omitempty"`
+
+	// Renames of old param name to current name by version
+	Renames map[string]map[string]string `json:"renames,omitempty"`
+
+	// Method and Path of restful route under /v1, like GET /dnats
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+
+	// Version of api key, and deprecation message of the version
+	Version    string `json:"version"`
+	Deprecated string `json:"deprecated,omitempty"`
+
+	// Mutating for api changing vyos configuration, dryRun honored
+	Mutating bool `json:"mutating"`
+
+	// exclusive for api taking the commit lock or managing commits itself,
+	// never run in a batch
+	exclusive bool
+
+	// result sample of response data, used by schema export
+	result interface{}
+}
+
+// LocalName of proto in language, Name in chinese and NameEN in english
+func (p *Proto) LocalName(lang string) string {
+	if lang == merrors.LangEN && p.NameEN != "" {
+		return p.NameEN
+	}
+	return p.Name
 }
 
 // InitLog to init api log config
@@ -74,38 +146,46 @@ func InitLog(level int) {
 type Module struct {
 	Name   string           `json:"name"`
 	Protos map[string]Proto `json:"protos"`
+
+	// Versions served by protos of module, all versions if empty
+	Versions []string `json:"versions,omitempty"`
 }
 
-// FindProto for by api key like xx.xxx.xxx.xx
+// FindProto for by api key like octlink.virtualrouter.v5.dnat.APIShowDnats
 func FindProto(api string) *Proto {
 
-	segments := strings.Split(api, ".")
-	moduleName := segments[3]
-	apiKey := segments[4]
-
-	if moduleName == "" || apiKey == "" {
-		fmt.Printf("got bad api key %s\n", api)
-		return nil
-	}
-
-	module, ok := GAPIConfig.Modules[moduleName]
+	proto, ok := GProtos[api]
 	if !ok {
-		fmt.Printf("no module exist for %s\n", moduleName)
+		fmt.Printf("no proto exist for %s\n", api)
 		return nil
 	}
 
-	proto, ok := module.Protos[apiKey]
-	if !ok {
-		fmt.Printf("no proto exist for %s\n", apiKey)
-		return nil
+	// a copy, so callers never change the registry
+	copied := *proto
+
+	return &copied
+}
+
+// localModules of protos with names in language
+func localModules(lang string) map[string]Module {
+
+	modules := make(map[string]Module, len(GAPIConfig.Modules))
+	for name, module := range GAPIConfig.Modules {
+		protos := make(map[string]Proto, len(module.Protos))
+		for key, proto := range module.Protos {
+			proto.Name = proto.LocalName(lang)
+			protos[key] = proto
+		}
+		module.Protos = protos
+		modules[name] = module
 	}
 
-	return &proto
+	return modules
 }
 
 // LoadTestPage to load api test page
 func (api *API) LoadTestPage(c *gin.Context) {
-	apiModules, _ := json.Marshal(GAPIConfig.Modules)
+	apiModules, _ := json.Marshal(localModules(httpresponse.Lang(c)))
 	c.HTML(http.StatusOK, "apitest.html",
 		gin.H{
 			"TESTTITLE": "Mirage",
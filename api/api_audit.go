@@ -0,0 +1,21 @@
+package api
+
+// ShowAuditLog by API
+func ShowAuditLog(paras *Paras) *Response {
+
+	if GAuditor == nil {
+		return &Response{
+			Data: []*AuditRecord{},
+		}
+	}
+
+	records, total := GAuditor.Query(paras.GetInt64("startTime"), paras.GetInt64("endTime"),
+		paras.Get("module"), paras.GetInt("limit"))
+
+	return &Response{
+		Data:  records,
+		Total: total,
+		Count: len(records),
+		paged: true,
+	}
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+)
+
+const (
+	// BatchStepSuccess step succeeded, and changes committed with the batch
+	BatchStepSuccess = "success"
+
+	// BatchStepFailed step failed, the whole batch is dropped
+	BatchStepFailed = "failed"
+
+	// BatchStepSkipped step not run for a previous step failed
+	BatchStepSkipped = "skipped"
+
+	// BatchStepDiscarded step succeeded, but the batch failed to commit
+	BatchStepDiscarded = "discarded"
+)
+
+// BatchOp one api invocation of a batch
+type BatchOp struct {
+	API   string                 `json:"api"`
+	Paras map[string]interface{} `json:"paras" param:"object,optional"`
+}
+
+// BatchResult result of one step of a batch
+type BatchResult struct {
+	API      string      `json:"api"`
+	State    string      `json:"state"`
+	Error    int         `json:"error"`
+	ErrorLog string      `json:"errorLog"`
+	Data     interface{} `json:"data"`
+}
+
+// runBatchOp run op against the shared batch tree
+func runBatchOp(tree *vyos.ConfigTree, op *BatchOp) *Response {
+
+	service := GServices[op.API]
+	if service == nil {
+		return &Response{
+			Error:    merrors.ErrNoSuchAPI,
+			ErrorLog: op.API,
+		}
+	}
+
+	// only changes of the batch tree, the batch commit holds the lock
+	proto := FindProto(op.API)
+	if proto.handler == nil || !proto.Mutating || proto.exclusive {
+		return &Response{
+			Error:    merrors.ErrBadParas,
+			ErrorLog: op.API + " not allowed in batch",
+		}
+	}
+
+	if op.Paras != nil {
+		proto.adaptParas(op.Paras)
+	}
+
+	paras := &Paras{
+		Proto: proto,
+		InParas: &inputParas{
+			Module: op.API,
+			API:    op.API,
+			Paras:  op.Paras,
+		},
+		batch: tree,
+	}
+
+	if ret, msg := checkParas(paras); ret != merrors.ErrSuccess {
+		return &Response{
+			Error:    ret,
+			ErrorLog: msg,
+		}
+	}
+
+	return callHandler(service, paras)
+}
+
+// Batch by API, all steps run against one configuration tree, and the
+// changes are committed only if all steps succeeded
+func Batch(paras *Paras) *Response {
+
+	var ops []*BatchOp
+	if err := paras.GetObjects("ops", &ops); err != nil {
+		return &Response{
+			Error:    merrors.ErrBadParas,
+			ErrorLog: err.Error(),
+		}
+	}
+
+	results := make([]*BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = &BatchResult{
+			API:   op.API,
+			State: BatchStepSkipped,
+		}
+	}
+
+	failed := -1
+
+	err := commitBatch(paras, func(tree *vyos.ConfigTree) error {
+		for i, op := range ops {
+			resp := runBatchOp(tree, op)
+
+			results[i].Error = resp.Error
+			results[i].ErrorLog = resp.ErrorLog
+			results[i].Data = resp.Data
+
+			if resp.Error != merrors.ErrSuccess {
+				logger.Errorf("batch step %d %s failed, %s\n", i, op.API, resp.ErrorLog)
+				results[i].State = BatchStepFailed
+				failed = i
+				return resp.asError()
+			}
+
+			results[i].State = BatchStepSuccess
+		}
+		return nil
+	})
+
+	errorLog := merrors.Log(err)
+	if err != nil {
+		if failed >= 0 {
+			errorLog = fmt.Sprintf("step %d %s failed, %s", failed, ops[failed].API,
+				results[failed].ErrorLog)
+		} else {
+			for _, result := range results {
+				if result.State == BatchStepSuccess {
+					result.State = BatchStepDiscarded
+				}
+			}
+		}
+	}
+
+	return &Response{
+		Error:    merrors.Code(err),
+		ErrorLog: errorLog,
+		Data:     results,
+	}
+}
+
+// commitBatch commit changes of fn in one apply, failure of apply recovered
+func commitBatch(paras *Paras, fn func(tree *vyos.ConfigTree) error) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = panicToResponse(paras.InParas.API, r).asError()
+		}
+	}()
+
+	return paras.Commit(fn)
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+)
+
+// ShowPendingCommit by API, null if no commit waiting for confirm
+func ShowPendingCommit(paras *Paras) *Response {
+	return &Response{
+		Data: vyos.GCommitManager.Pending(),
+	}
+}
+
+// ConfirmCommit by API, keep changes of the pending commit, or just show
+// the commit to be confirmed if dry run
+func ConfirmCommit(paras *Paras) *Response {
+
+	confirm := vyos.GCommitManager.Confirm
+	if paras.InParas.DryRun {
+		confirm = vyos.GCommitManager.PendingConfirm
+	}
+
+	pending, err := confirm()
+	if err != nil {
+		return newResponse(nil, merrors.Wrap(err, merrors.ErrSegmentNotExist, "nothing to confirm"))
+	}
+
+	return newResponse(pending, nil)
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
-	"encoding/json"
 	"octlink/ovs/plugins"
+	"octlink/ovs/utils"
 	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+	"reflect"
 )
 
 // AddDnat to add dnat by API
@@ -21,9 +23,9 @@ func AddDnat(paras *Paras) *Response {
 		AllowedCidr:      paras.Get("allowedCidr"),
 	}
 
-	return &Response{
-		Error: dnat.AddDnat(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return dnat.AddDnat(tree)
+	}))
 }
 
 // RemoveDnat to remove dnat
@@ -39,61 +41,56 @@ func RemoveDnat(paras *Paras) *Response {
 		PrivateNicMac:    paras.Get("privateNicMac"),
 	}
 
-	return &Response{
-		Error: dnat.RemoveDnat(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return dnat.RemoveDnat(tree)
+	}))
 }
 
 // RemoveDnats by API
 func RemoveDnats(paras *Paras) *Response {
-	dnatsJSON := []byte(paras.Get("dnats"))
-	var dnats []plugins.Dnat
 
-	err := json.Unmarshal(dnatsJSON, &dnats)
-	if err != nil {
+	var dnats []*plugins.Dnat
+	if err := paras.GetObjects("dnats", &dnats); err != nil {
 		return &Response{
-			Error: merrors.ErrBadParas,
+			Error:    merrors.ErrBadParas,
+			ErrorLog: err.Error(),
 		}
 	}
 
-	dnatsNew := make([]*plugins.Dnat, len(dnats))
-	for i := range dnats {
-		dnatsNew[i] = &dnats[i]
-	}
-
-	return &Response{
-		Error: plugins.RemoveDnats(dnatsNew),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return plugins.RemoveDnats(tree, dnats)
+	}))
 }
 
 // SyncDnats by API
 func SyncDnats(paras *Paras) *Response {
 
-	dnatsJSON := []byte(paras.Get("dnats"))
-	var dnats []plugins.Dnat
-
-	err := json.Unmarshal(dnatsJSON, &dnats)
-	if err != nil {
+	var dnats []*plugins.Dnat
+	if err := paras.GetObjects("dnats", &dnats); err != nil {
 		return &Response{
-			Error: merrors.ErrBadParas,
+			Error:    merrors.ErrBadParas,
+			ErrorLog: err.Error(),
 		}
 	}
 
-	dnatsNew := make([]*plugins.Dnat, len(dnats))
-	for i := range dnats {
-		dnatsNew[i] = &dnats[i]
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return plugins.SyncDnats(tree, dnats)
+	}))
+}
 
-	return &Response{
-		Error: plugins.SyncDnats(dnatsNew),
-	}
+// matchDnatPort for dnat with port in its vip or private port range
+func matchDnatPort(item reflect.Value, value string) bool {
+	dnat := item.Interface().(*plugins.Dnat)
+	port := utils.StringToInt(value)
+	return (port >= dnat.VipPortStart && port <= dnat.VipPortEnd) ||
+		(port >= dnat.PrivatePortStart && port <= dnat.PrivatePortEnd)
 }
 
 // ShowDnats by api
 func ShowDnats(paras *Paras) *Response {
-	return &Response{
-		Data: plugins.GetAllDnats(),
-	}
+	return pageList(paras, plugins.GetAllDnats(), map[string]listMatcher{
+		"port": matchDnatPort,
+	})
 }
 
 // ShowDnat by api
@@ -101,9 +98,6 @@ func ShowDnat(paras *Paras) *Response {
 
 	dnat, err := plugins.GetDnat(paras.Get("privateNicMac"))
 
-	return &Response{
-		Data:  dnat,
-		Error: err,
-	}
+	return newResponse(dnat, err)
 
 }
@@ -1,6 +1,9 @@
 package api
 
-import "octlink/ovs/plugins"
+import (
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils/vyos"
+)
 
 // AddDns to add dns
 func AddDns(paras *Paras) *Response {
@@ -10,9 +13,9 @@ func AddDns(paras *Paras) *Response {
 		PublicNicMac: paras.Get("publicNicMac"),
 	}
 
-	return &Response{
-		Error: dns.AddDns(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return dns.AddDns(tree)
+	}))
 }
 
 // DeleteDns for delete dns
@@ -22,14 +25,12 @@ func DeleteDns(paras *Paras) *Response {
 		DnsAddress: paras.Get("dnsAddress"),
 	}
 
-	return &Response{
-		Error: dns.DeleteDns(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return dns.DeleteDns(tree)
+	}))
 }
 
 func ShowDns(paras *Paras) *Response {
 
-	return &Response{
-		Data: plugins.ShowDns(),
-	}
+	return pageList(paras, plugins.ShowDns(), nil)
 }
@@ -1,9 +1,9 @@
 package api
 
 import (
-	"encoding/json"
 	"octlink/ovs/plugins"
 	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
 )
 
 // CreateEip to add image by API
@@ -14,9 +14,9 @@ func CreateEip(paras *Paras) *Response {
 		VipIP:      paras.Get("vip"),
 		GuestIP:    paras.Get("guestIp"),
 	}
-	return &Response{
-		Error: eip.CreateEip(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return eip.CreateEip(tree)
+	}))
 }
 
 // RemoveEip by API
@@ -27,62 +27,46 @@ func RemoveEip(paras *Paras) *Response {
 		VipIP:      paras.Get("vip"),
 		GuestIP:    paras.Get("guestIp"),
 	}
-	return &Response{
-		Error: eip.RemoveEip(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return eip.RemoveEip(tree)
+	}))
 }
 
 // RemoveEips by API
 func RemoveEips(paras *Paras) *Response {
 
-	eipsJSON := []byte(paras.Get("eips"))
-	var eips []plugins.EipInfo
-
-	err := json.Unmarshal(eipsJSON, &eips)
-	if err != nil {
+	var eips []*plugins.EipInfo
+	if err := paras.GetObjects("eips", &eips); err != nil {
 		return &Response{
-			Error: merrors.ErrBadParas,
+			Error:    merrors.ErrBadParas,
+			ErrorLog: err.Error(),
 		}
 	}
 
-	eipsNew := make([]*plugins.EipInfo, len(eips))
-	for i := range eips {
-		eipsNew[i] = &eips[i]
-	}
-
-	return &Response{
-		Error: plugins.RemoveEips(eipsNew),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return plugins.RemoveEips(tree, eips)
+	}))
 }
 
 // SyncEips by API
 func SyncEips(paras *Paras) *Response {
 
-	eipsJSON := []byte(paras.Get("eips"))
-	var eips []plugins.EipInfo
-
-	err := json.Unmarshal(eipsJSON, &eips)
-	if err != nil {
+	var eips []*plugins.EipInfo
+	if err := paras.GetObjects("eips", &eips); err != nil {
 		return &Response{
-			Error: merrors.ErrBadParas,
+			Error:    merrors.ErrBadParas,
+			ErrorLog: err.Error(),
 		}
 	}
 
-	eipsNew := make([]*plugins.EipInfo, len(eips))
-	for i := range eips {
-		eipsNew[i] = &eips[i]
-	}
-
-	return &Response{
-		Error: plugins.SyncEips(eipsNew),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return plugins.SyncEips(tree, eips)
+	}))
 }
 
 // ShowEips by api
 func ShowEips(paras *Paras) *Response {
-	return &Response{
-		Data: plugins.GetAllEips(),
-	}
+	return pageList(paras, plugins.GetAllEips(), nil)
 }
 
 // ShowEip by api
@@ -90,9 +74,6 @@ func ShowEip(paras *Paras) *Response {
 
 	eip, err := plugins.GetEip(paras.Get("privateMac"))
 
-	return &Response{
-		Data:  eip,
-		Error: err,
-	}
+	return newResponse(eip, err)
 
 }
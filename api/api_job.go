@@ -0,0 +1,55 @@
+package api
+
+import "octlink/ovs/utils/merrors"
+
+// QueryJob by API
+func QueryJob(paras *Paras) *Response {
+
+	if GJobManager == nil {
+		return &Response{
+			Error: merrors.ErrNotImplemented,
+		}
+	}
+
+	job := GJobManager.Get(paras.Get("id"))
+	if job == nil {
+		return &Response{
+			Error:    merrors.ErrSegmentNotExist,
+			ErrorLog: "job " + paras.Get("id") + " not exist",
+		}
+	}
+
+	return &Response{
+		Data: job,
+	}
+}
+
+// ListJobs by API
+func ListJobs(paras *Paras) *Response {
+
+	if GJobManager == nil {
+		return &Response{
+			Data: []*Job{},
+		}
+	}
+
+	return pageList(paras, GJobManager.List(paras.Get("state")), nil)
+}
+
+// CancelJob by API
+func CancelJob(paras *Paras) *Response {
+
+	if GJobManager == nil {
+		return &Response{
+			Error: merrors.ErrNotImplemented,
+		}
+	}
+
+	job, err, msg := GJobManager.Cancel(paras.Get("id"))
+
+	return &Response{
+		Data:     job,
+		Error:    err,
+		ErrorLog: msg,
+	}
+}
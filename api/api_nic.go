@@ -1,12 +1,13 @@
 package api
 
-import "octlink/ovs/plugins"
+import (
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils/vyos"
+)
 
 // ShowInterfaces by api
 func ShowInterfaces(paras *Paras) *Response {
-	return &Response{
-		Data: plugins.GetNics(),
-	}
+	return pageList(paras, plugins.GetNics(), nil)
 }
 
 // SetInterface by api
@@ -18,9 +19,9 @@ func SetInterface(paras *Paras) *Response {
 		Netmask: paras.Get("netmask"),
 	}
 
-	return &Response{
-		Data: ifInfo.ConfigureNic(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return ifInfo.ConfigureNic(tree)
+	}))
 }
 
 // RemoveInterface by api
@@ -28,7 +29,7 @@ func RemoveInterface(paras *Paras) *Response {
 	ifInfo := &plugins.IfInfo{
 		Mac: paras.Get("mac"),
 	}
-	return &Response{
-		Data: ifInfo.RemoveNic(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return ifInfo.RemoveNic(tree)
+	}))
 }
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+)
+
+// SnapshotDiff of running configuration to snapshot, commands to roll back
+type SnapshotDiff struct {
+	Name     string   `json:"name"`
+	Commands []string `json:"commands"`
+}
+
+// snapshotError of snapshot store error
+func snapshotError(err error, name string) error {
+
+	code := merrors.ErrSystemErr
+	switch err {
+	case vyos.ErrSnapshotNotExist:
+		code = merrors.ErrSegmentNotExist
+	case vyos.ErrSnapshotExist:
+		code = merrors.ErrSegmentAlreadyExist
+	case vyos.ErrBadSnapshotName:
+		code = merrors.ErrBadParas
+	}
+
+	return merrors.Wrap(err, code, "snapshot %s", name)
+}
+
+// snapshots store, nil if snapshots not enabled
+func snapshots() (*vyos.SnapshotStore, *Response) {
+
+	store := vyos.GCommitManager.Snapshots
+	if store == nil {
+		return nil, newResponse(nil, merrors.Errorf(merrors.ErrNotImplemented, "snapshots not enabled"))
+	}
+
+	return store, nil
+}
+
+// ListSnapshots by API
+func ListSnapshots(paras *Paras) *Response {
+
+	store, resp := snapshots()
+	if resp != nil {
+		return resp
+	}
+
+	return pageList(paras, store.List(), nil)
+}
+
+// CreateSnapshot of running configuration by API
+func CreateSnapshot(paras *Paras) *Response {
+
+	store, resp := snapshots()
+	if resp != nil {
+		return resp
+	}
+
+	name := paras.Get("name")
+
+	var snapshot *vyos.Snapshot
+	var err error
+
+	// no commit in the middle of reading configuration
+	vyos.GCommitManager.Exclusive(func() {
+		snapshot, err = store.Save(name, vyos.ShowConfiguration())
+	})
+	if err != nil {
+		return newResponse(nil, snapshotError(err, name))
+	}
+
+	snapshot.Config = ""
+
+	return newResponse(snapshot, nil)
+}
+
+// DiffSnapshot with running configuration by API
+func DiffSnapshot(paras *Paras) *Response {
+
+	store, resp := snapshots()
+	if resp != nil {
+		return resp
+	}
+
+	name := paras.Get("name")
+
+	snapshot, err := store.Get(name)
+	if err != nil {
+		return newResponse(nil, snapshotError(err, name))
+	}
+
+	return newResponse(&SnapshotDiff{
+		Name:     name,
+		Commands: vyos.RollbackCommands(vyos.ShowConfiguration(), snapshot.Config),
+	}, nil)
+}
+
+// RollbackSnapshot restore running configuration to snapshot by API
+func RollbackSnapshot(paras *Paras) *Response {
+
+	store, resp := snapshots()
+	if resp != nil {
+		return resp
+	}
+
+	name := paras.Get("name")
+
+	snapshot, err := store.Get(name)
+	if err != nil {
+		return newResponse(nil, snapshotError(err, name))
+	}
+
+	err = paras.Commit(func(tree *vyos.ConfigTree) error {
+		tree.Converge(snapshot.Tree())
+		return nil
+	})
+	if err != nil {
+		return newResponse(nil, err)
+	}
+
+	commands := paras.commands
+	if commands == nil {
+		commands = []string{}
+	}
+
+	return newResponse(&SnapshotDiff{
+		Name:     name,
+		Commands: commands,
+	}, nil)
+}
+
+// DeleteSnapshot by API
+func DeleteSnapshot(paras *Paras) *Response {
+
+	store, resp := snapshots()
+	if resp != nil {
+		return resp
+	}
+
+	name := paras.Get("name")
+
+	if err := store.Delete(name); err != nil {
+		return newResponse(nil, snapshotError(err, name))
+	}
+
+	return newResponse(&vyos.Snapshot{Name: name}, nil)
+}
@@ -1,6 +1,9 @@
 package api
 
-import "octlink/ovs/plugins"
+import (
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils/vyos"
+)
 
 // AddSnat to add image by API
 func AddSnat(paras *Paras) *Response {
@@ -11,9 +14,9 @@ func AddSnat(paras *Paras) *Response {
 		PublicIP:      paras.Get("publicIp"),
 	}
 
-	return &Response{
-		Error: sn.AddSnat(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return sn.AddSnat(tree)
+	}))
 }
 
 // SyncSnat to add image by API
@@ -25,9 +28,9 @@ func SyncSnat(paras *Paras) *Response {
 		PublicIP:      paras.Get("publicIp"),
 	}
 
-	return &Response{
-		Error: sn.SyncSnat(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return sn.SyncSnat(tree)
+	}))
 }
 
 // ShowSnat by api
@@ -35,10 +38,7 @@ func ShowSnat(paras *Paras) *Response {
 
 	nat, err := plugins.GetSnat(paras.Get("privateNicMac"))
 
-	return &Response{
-		Data:  nat,
-		Error: err,
-	}
+	return newResponse(nat, err)
 }
 
 // DeleteSnat to delete image
@@ -48,14 +48,12 @@ func DeleteSnat(paras *Paras) *Response {
 		PrivateNicMac: paras.Get("privateNicMac"),
 	}
 
-	return &Response{
-		Error: sn.RemoveSnat(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return sn.RemoveSnat(tree)
+	}))
 }
 
 // ShowAllSnats to display all images by condition
 func ShowAllSnats(paras *Paras) *Response {
-	return &Response{
-		Data: plugins.GetAllSnats(),
-	}
+	return pageList(paras, plugins.GetAllSnats(), nil)
 }
@@ -1,9 +1,9 @@
 package api
 
 import (
-	"encoding/json"
 	"octlink/ovs/plugins"
 	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
 )
 
 // AddVip to add image by API
@@ -14,9 +14,9 @@ func AddVip(paras *Paras) *Response {
 		OwnerEthernetMac: paras.Get("ownerEthernetMac"),
 	}
 
-	return &Response{
-		Error: vip.AddVip(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return vip.AddVip(tree)
+	}))
 }
 
 // DeleteVip to delete vip
@@ -27,29 +27,22 @@ func DeleteVip(paras *Paras) *Response {
 		OwnerEthernetMac: paras.Get("ownerEthernetMac"),
 	}
 
-	return &Response{
-		Error: vip.DeleteVip(),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return vip.DeleteVip(tree)
+	}))
 }
 
 func SyncVips(paras *Paras) *Response {
 
-	vipsJSON := []byte(paras.Get("vips"))
-	var vips []plugins.Vip
-
-	err := json.Unmarshal(vipsJSON, &vips)
-	if err != nil {
+	var vips []*plugins.Vip
+	if err := paras.GetObjects("vips", &vips); err != nil {
 		return &Response{
-			Error: merrors.ErrBadParas,
+			Error:    merrors.ErrBadParas,
+			ErrorLog: err.Error(),
 		}
 	}
 
-	vipsNew := make([]*plugins.Vip, len(vips))
-	for i := range vips {
-		vipsNew[i] = &vips[i]
-	}
-
-	return &Response{
-		Error: plugins.SyncVips(vipsNew),
-	}
+	return newResponse(nil, paras.Commit(func(tree *vyos.ConfigTree) error {
+		return plugins.SyncVips(tree, vips)
+	}))
 }
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/configuration"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultAuditFile under log directory if not configured
+	DefaultAuditFile = "audit.log"
+
+	// DefaultAuditMaxSize in bytes of audit journal before rotated
+	DefaultAuditMaxSize = 10 * 1024 * 1024
+
+	// DefaultAuditMaxFiles of rotated audit journals kept
+	DefaultAuditMaxFiles = 5
+
+	auditMask = "******"
+)
+
+// sensitive param names masked in audit journal
+var auditSensitiveParas = []string{"password", "passwd", "secret", "token", "key"}
+
+// AuditRecord of one api request
+type AuditRecord struct {
+	Time     int64                  `json:"time"`
+	Remote   string                 `json:"remote"`
+	Caller   string                 `json:"caller"`
+	API      string                 `json:"api"`
+	Module   string                 `json:"module"`
+	Paras    map[string]interface{} `json:"paras"`
+	Async    bool                   `json:"async,omitempty"`
+	DryRun   bool                   `json:"dryRun,omitempty"`
+	Request  string                 `json:"requestId,omitempty"`
+	Error    int                    `json:"error"`
+	ErrorLog string                 `json:"errorLog,omitempty"`
+	Commands []string               `json:"commands,omitempty"`
+	Duration int64                  `json:"duration"`
+}
+
+// Auditor for append only audit journal with size based rotation
+type Auditor struct {
+	lock     sync.Mutex
+	file     string
+	maxSize  int64
+	maxFiles int
+	fd       *os.File
+	size     int64
+}
+
+// GAuditor for global audit journal, nil for no audit
+var GAuditor *Auditor
+
+// InitAudit to init audit journal
+func InitAudit(file string, maxSize int64, maxFiles int) {
+
+	if file == "" {
+		file = configuration.LogDirectory() + "/" + DefaultAuditFile
+	}
+
+	GAuditor = NewAuditor(file, maxSize, maxFiles)
+}
+
+// NewAuditor to new an auditor writing to file
+func NewAuditor(file string, maxSize int64, maxFiles int) *Auditor {
+
+	if maxSize <= 0 {
+		maxSize = DefaultAuditMaxSize
+	}
+
+	if maxFiles <= 0 {
+		maxFiles = DefaultAuditMaxFiles
+	}
+
+	return &Auditor{
+		file:     file,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+	}
+}
+
+// open journal file for appending, must be called with lock held
+func (a *Auditor) open() error {
+
+	if a.fd != nil {
+		return nil
+	}
+
+	if err := utils.MkdirForFile(a.file, 0755); err != nil {
+		return err
+	}
+
+	fd, err := os.OpenFile(a.file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return err
+	}
+
+	a.fd = fd
+	a.size = info.Size()
+
+	return nil
+}
+
+// rotate journal to file.1, file.1 to file.2 and so on, must be called with
+// lock held
+func (a *Auditor) rotate() {
+
+	if a.fd != nil {
+		a.fd.Close()
+		a.fd = nil
+	}
+
+	os.Remove(fmt.Sprintf("%s.%d", a.file, a.maxFiles))
+	for i := a.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", a.file, i), fmt.Sprintf("%s.%d", a.file, i+1))
+	}
+	os.Rename(a.file, a.file+".1")
+}
+
+// Record append record to journal
+func (a *Auditor) Record(record *AuditRecord) {
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		logger.Errorf("marshal audit record of %s error %s\n", record.API, err)
+		return
+	}
+	data = append(data, '\n')
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if err := a.open(); err != nil {
+		logger.Errorf("open audit journal %s error %s\n", a.file, err)
+		return
+	}
+
+	if a.size > 0 && a.size+int64(len(data)) > a.maxSize {
+		a.rotate()
+		if err := a.open(); err != nil {
+			logger.Errorf("open audit journal %s error %s\n", a.file, err)
+			return
+		}
+	}
+
+	n, err := a.fd.Write(data)
+	a.size += int64(n)
+	if err != nil {
+		logger.Errorf("write audit journal %s error %s\n", a.file, err)
+	}
+}
+
+// Query records in [startTime, endTime] of module, the latest limit records
+// returned in time order with count of all matched, zero for no limitation
+func (a *Auditor) Query(startTime int64, endTime int64, module string, limit int) ([]*AuditRecord, int) {
+
+	records := make([]*AuditRecord, 0)
+
+	for _, journal := range a.journals() {
+		scanner := bufio.NewScanner(journal)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			record := new(AuditRecord)
+			if err := json.Unmarshal(scanner.Bytes(), record); err != nil {
+				continue
+			}
+			if startTime != 0 && record.Time < startTime {
+				continue
+			}
+			if endTime != 0 && record.Time > endTime {
+				continue
+			}
+			if module != "" && record.Module != module {
+				continue
+			}
+			records = append(records, record)
+		}
+
+		journal.Close()
+	}
+
+	total := len(records)
+	if limit > 0 && total > limit {
+		records = records[total-limit:]
+	}
+
+	return records, total
+}
+
+// auditJournal opened for reading, up to its size when opened
+type auditJournal struct {
+	io.Reader
+	fd *os.File
+}
+
+// Close journal
+func (j *auditJournal) Close() error {
+	return j.fd.Close()
+}
+
+// journals opened, oldest rotated first. Opened with lock held, so none
+// is rotated in the middle, and read after lock released, so records are
+// never blocked by queries.
+func (a *Auditor) journals() []*auditJournal {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	journals := make([]*auditJournal, 0, a.maxFiles+1)
+	for i := a.maxFiles; i >= 0; i-- {
+		file := a.file
+		if i > 0 {
+			file = fmt.Sprintf("%s.%d", a.file, i)
+		}
+
+		fd, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+
+		info, err := fd.Stat()
+		if err != nil {
+			fd.Close()
+			continue
+		}
+
+		journals = append(journals, &auditJournal{
+			Reader: io.LimitReader(fd, info.Size()),
+			fd:     fd,
+		})
+	}
+
+	return journals
+}
+
+// sanitizeParas copy paras with sensitive values masked
+func sanitizeParas(paras map[string]interface{}) map[string]interface{} {
+
+	if paras == nil {
+		return nil
+	}
+
+	sanitized := make(map[string]interface{}, len(paras))
+	for name, value := range paras {
+		sanitized[name] = sanitizeValue(name, value)
+	}
+
+	return sanitized
+}
+
+func sanitizeValue(name string, value interface{}) interface{} {
+
+	lower := strings.ToLower(name)
+	for _, sensitive := range auditSensitiveParas {
+		if strings.Contains(lower, sensitive) {
+			return auditMask
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return sanitizeParas(v)
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = sanitizeValue("", item)
+		}
+		return list
+	}
+
+	return value
+}
+
+// apiModule of api key like octlink.virtualrouter.v5.dnat.APIAddDnat
+func apiModule(api string) string {
+	segments := strings.Split(api, ".")
+	if len(segments) < 2 {
+		return ""
+	}
+	return segments[len(segments)-2]
+}
+
+// audit api request with its response
+func audit(paras *Paras, resp *Response, start time.Time) {
+
+	if GAuditor == nil || paras.batch != nil {
+		return
+	}
+
+	GAuditor.Record(&AuditRecord{
+		Time:     start.Unix(),
+		Remote:   paras.remote,
+		Caller:   paras.caller,
+		API:      paras.InParas.API,
+		Module:   apiModule(paras.InParas.API),
+		Paras:    sanitizeParas(paras.InParas.Paras),
+		Async:    paras.InParas.Async,
+		DryRun:   paras.InParas.DryRun,
+		Request:  paras.InParas.RequestID,
+		Error:    resp.Error,
+		ErrorLog: resp.ErrorLog,
+		Commands: paras.commands,
+		Duration: int64(time.Since(start) / time.Millisecond),
+	})
+}
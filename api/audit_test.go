@@ -0,0 +1,70 @@
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAuditor(t *testing.T) {
+
+	initTestLog()
+
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatalf("create temp dir error %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	auditor := NewAuditor(dir+"/audit.log", 512, 2)
+
+	for i := 0; i < 20; i++ {
+		module := "dnat"
+		if i%2 == 1 {
+			module = "eip"
+		}
+		auditor.Record(&AuditRecord{
+			Time:   int64(i),
+			API:    APIPrefixCenter + "." + module + ".APIShow",
+			Module: module,
+			Paras:  sanitizeParas(map[string]interface{}{"password": "123456"}),
+		})
+	}
+
+	if _, err := os.Stat(dir + "/audit.log.3"); err == nil {
+		t.Fatalf("rotated journals should be limited to 2")
+	}
+
+	records, _ := auditor.Query(0, 0, "", 0)
+	if len(records) == 0 || len(records) == 20 {
+		t.Fatalf("old records should be dropped by rotation, %d got", len(records))
+	}
+
+	last := records[len(records)-1]
+	if last.Time != 19 || last.Paras["password"] != auditMask {
+		t.Fatalf("bad last record %+v", last)
+	}
+
+	records, total := auditor.Query(16, 18, "dnat", 1)
+	if len(records) != 1 || records[0].Time != 18 || total != 2 {
+		t.Fatalf("query by time and module failed, %+v", records)
+	}
+
+	// records and rotations while journals read never block or mix in
+	before, _ := auditor.Query(0, 0, "", 0)
+	journals := auditor.journals()
+	for i := 20; i < 40; i++ {
+		auditor.Record(&AuditRecord{Time: int64(i), Module: "dnat"})
+	}
+
+	lines := 0
+	for _, journal := range journals {
+		data, _ := ioutil.ReadAll(journal)
+		lines += strings.Count(string(data), "\n")
+		journal.Close()
+	}
+	if lines != len(before) {
+		t.Fatalf("journals should be read as of opening, %d of %d lines got", lines, len(before))
+	}
+}
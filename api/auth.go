@@ -0,0 +1,242 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/configuration"
+	"octlink/ovs/utils/httpresponse"
+	"octlink/ovs/utils/merrors"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderAuthKey for key id of caller
+	HeaderAuthKey = "X-Ovs-Key"
+
+	// HeaderAuthTimestamp for unix timestamp in seconds of request
+	HeaderAuthTimestamp = "X-Ovs-Timestamp"
+
+	// HeaderAuthNonce for random nonce of request, never reused
+	HeaderAuthNonce = "X-Ovs-Nonce"
+
+	// HeaderAuthSignature for hex encoded hmac-sha256 signature of request
+	HeaderAuthSignature = "X-Ovs-Signature"
+
+	// ContextAuthKey for key id of authenticated caller in gin context
+	ContextAuthKey = "authKey"
+
+	// DefaultAuthMaxSkew in seconds if not configured
+	DefaultAuthMaxSkew = 300
+
+	// DefaultAuthNonceTTL in seconds if not configured
+	DefaultAuthNonceTTL = 600
+)
+
+// Authenticator to verify signed api requests
+type Authenticator struct {
+	enabled   bool
+	keys      map[string]string
+	maxSkew   int64
+	nonceTTL  int64
+	allowList map[string]bool
+
+	lock   sync.Mutex
+	nonces map[string]int64
+}
+
+// GAuthenticator for global api authentication, nil for no authentication
+var GAuthenticator *Authenticator
+
+// placeholderSecrets never accepted as secret of an enabled authenticator
+var placeholderSecrets = map[string]bool{
+	"changeme": true,
+}
+
+// InitAuth to init api authentication by config, refused if enabled
+// without keys or with an empty or placeholder secret
+func InitAuth(conf *configuration.AuthConfig) error {
+
+	if conf.Enabled {
+		if len(conf.Keys) == 0 {
+			return fmt.Errorf("api authentication enabled without keys")
+		}
+		for key, secret := range conf.Keys {
+			if strings.TrimSpace(secret) == "" || placeholderSecrets[secret] {
+				return fmt.Errorf("empty or placeholder secret of key %s", key)
+			}
+		}
+	}
+
+	GAuthenticator = NewAuthenticator(conf)
+	if !GAuthenticator.enabled {
+		logger.Warnf("api authentication disabled\n")
+	}
+
+	return nil
+}
+
+// NewAuthenticator to new an authenticator by config
+func NewAuthenticator(conf *configuration.AuthConfig) *Authenticator {
+
+	auth := &Authenticator{
+		enabled:   conf.Enabled,
+		keys:      conf.Keys,
+		maxSkew:   int64(conf.MaxSkew),
+		nonceTTL:  int64(conf.NonceTTL),
+		allowList: make(map[string]bool, len(conf.AllowList)),
+		nonces:    make(map[string]int64, 1000),
+	}
+
+	if auth.maxSkew <= 0 {
+		auth.maxSkew = DefaultAuthMaxSkew
+	}
+
+	// nonce must be remembered as long as its timestamp is acceptable
+	if auth.nonceTTL < 2*auth.maxSkew {
+		auth.nonceTTL = 2 * auth.maxSkew
+	}
+
+	for _, item := range conf.AllowList {
+		auth.allowList[strings.Join(strings.Fields(item), " ")] = true
+	}
+
+	return auth
+}
+
+// Sign request of method, path, raw query, timestamp, nonce and body by
+// secret, each but body followed by a newline
+func Sign(secret string, method string, path string, query string, timestamp string,
+	nonce string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	for _, field := range []string{method, path, query, timestamp, nonce} {
+		mac.Write([]byte(field))
+		mac.Write([]byte("\n"))
+	}
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// allowed for request served without authentication
+func (auth *Authenticator) allowed(method string, path string) bool {
+	return auth.allowList[method+" "+path] || auth.allowList[path]
+}
+
+// useNonce return false if nonce of key already used, must be called with lock held
+func (auth *Authenticator) useNonce(key string, nonce string, now int64) bool {
+
+	for n, expire := range auth.nonces {
+		if expire < now {
+			delete(auth.nonces, n)
+		}
+	}
+
+	id := key + ":" + nonce
+	if _, ok := auth.nonces[id]; ok {
+		return false
+	}
+
+	auth.nonces[id] = now + auth.nonceTTL
+
+	return true
+}
+
+// Verify signed request, return key id of caller
+func (auth *Authenticator) Verify(method string, path string, query string, header func(string) string,
+	body []byte) (string, int, string) {
+
+	if !auth.enabled || auth.allowed(method, path) {
+		return "", merrors.ErrSuccess, ""
+	}
+
+	key := header(HeaderAuthKey)
+	timestamp := header(HeaderAuthTimestamp)
+	nonce := header(HeaderAuthNonce)
+	signature := header(HeaderAuthSignature)
+
+	if key == "" || timestamp == "" || nonce == "" || signature == "" {
+		return key, merrors.ErrUnauthorized, "headers " + HeaderAuthKey + "," + HeaderAuthTimestamp +
+			"," + HeaderAuthNonce + "," + HeaderAuthSignature + " must be specified"
+	}
+
+	secret, ok := auth.keys[key]
+	if !ok {
+		return key, merrors.ErrUnauthorized, "unknown key " + key
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return key, merrors.ErrUnauthorized, "bad timestamp " + timestamp
+	}
+
+	now := utils.CurrentTime()
+	if ts < now-auth.maxSkew || ts > now+auth.maxSkew {
+		return key, merrors.ErrUnauthorized, "timestamp " + timestamp + " out of allowed clock skew"
+	}
+
+	expected := Sign(secret, method, path, query, timestamp, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return key, merrors.ErrUnauthorized, "signature mismatch"
+	}
+
+	auth.lock.Lock()
+	defer auth.lock.Unlock()
+
+	if !auth.useNonce(key, nonce, now) {
+		return key, merrors.ErrUnauthorized, "nonce " + nonce + " already used"
+	}
+
+	return key, merrors.ErrSuccess, ""
+}
+
+// authenticate request of gin context, the body is read and restored for
+// binding later
+func authenticate(c *gin.Context) (int, string) {
+
+	if GAuthenticator == nil {
+		return merrors.ErrSuccess, ""
+	}
+
+	var body []byte
+	if c.Request.Body != nil {
+		data, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			return merrors.ErrUnauthorized, "read request body error " + err.Error()
+		}
+		body = data
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	key, ret, msg := GAuthenticator.Verify(c.Request.Method, c.Request.URL.Path,
+		c.Request.URL.RawQuery, c.Request.Header.Get, body)
+	if ret != merrors.ErrSuccess {
+		logger.Errorf("reject request of %s from %s, %s\n", key, c.ClientIP(), msg)
+		return ret, msg
+	}
+
+	if key != "" {
+		c.Set(ContextAuthKey, key)
+	}
+
+	return merrors.ErrSuccess, ""
+}
+
+// AuthRequired middleware for routes out of dispatcher
+func AuthRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ret, msg := authenticate(c); ret != merrors.ErrSuccess {
+			httpresponse.Error(c, ret, msg)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/configuration"
+	"octlink/ovs/utils/merrors"
+	"strconv"
+	"testing"
+)
+
+func TestAuthenticatorVerify(t *testing.T) {
+
+	auth := NewAuthenticator(&configuration.AuthConfig{
+		Enabled:   true,
+		Keys:      map[string]string{"center": "secret"},
+		AllowList: []string{"GET /api/"},
+	})
+
+	body := []byte(`{"module":"octlink.virtualrouter.v5.nic.APIShowInterfaces"}`)
+	timestamp := strconv.FormatInt(utils.CurrentTime(), 10)
+
+	headers := map[string]string{
+		HeaderAuthKey:       "center",
+		HeaderAuthTimestamp: timestamp,
+		HeaderAuthNonce:     "nonce1",
+		HeaderAuthSignature: Sign("secret", "POST", "/api/", "", timestamp, "nonce1", body),
+	}
+	header := func(name string) string {
+		return headers[name]
+	}
+
+	if key, ret, msg := auth.Verify("POST", "/api/", "", header, body); ret != merrors.ErrSuccess || key != "center" {
+		t.Fatalf("signed request should be accepted, %s", msg)
+	}
+
+	if _, ret, _ := auth.Verify("POST", "/api/", "", header, body); ret != merrors.ErrUnauthorized {
+		t.Fatalf("replayed request should be rejected")
+	}
+
+	headers[HeaderAuthNonce] = "nonce2"
+	if _, ret, _ := auth.Verify("POST", "/api/", "", header, body); ret != merrors.ErrUnauthorized {
+		t.Fatalf("request with bad signature should be rejected")
+	}
+
+	old := strconv.FormatInt(utils.CurrentTime()-DefaultAuthMaxSkew-10, 10)
+	headers[HeaderAuthTimestamp] = old
+	headers[HeaderAuthSignature] = Sign("secret", "POST", "/api/", "", old, "nonce2", body)
+	if _, ret, _ := auth.Verify("POST", "/api/", "", header, body); ret != merrors.ErrUnauthorized {
+		t.Fatalf("request out of clock skew should be rejected")
+	}
+
+	none := func(string) string { return "" }
+	if _, ret, _ := auth.Verify("POST", "/api/", "", none, body); ret != merrors.ErrUnauthorized {
+		t.Fatalf("unsigned request should be rejected")
+	}
+
+	if _, ret, _ := auth.Verify("GET", "/api/", "", none, nil); ret != merrors.ErrSuccess {
+		t.Fatalf("health check should be allowed")
+	}
+}
+
+func TestAuthenticatorVerifyTampered(t *testing.T) {
+
+	auth := NewAuthenticator(&configuration.AuthConfig{
+		Enabled: true,
+		Keys:    map[string]string{"center": "secret"},
+	})
+
+	body := []byte(`{"privateMac":"fa:16:3e:00:00:02"}`)
+	timestamp := strconv.FormatInt(utils.CurrentTime(), 10)
+
+	signed := func(nonce string) func(string) string {
+		headers := map[string]string{
+			HeaderAuthKey:       "center",
+			HeaderAuthTimestamp: timestamp,
+			HeaderAuthNonce:     nonce,
+			HeaderAuthSignature: Sign("secret", "DELETE", "/v1/eips/10.0.0.1", "dryRun=true", timestamp, nonce, body),
+		}
+		return func(name string) string {
+			return headers[name]
+		}
+	}
+
+	tampered := []struct {
+		method string
+		path   string
+		query  string
+	}{
+		{"POST", "/v1/eips/10.0.0.1", "dryRun=true"},
+		{"DELETE", "/v1/eips/10.0.0.2", "dryRun=true"},
+		{"DELETE", "/v1/eips/10.0.0.1", "dryRun=false"},
+		{"DELETE", "/v1/eips/10.0.0.1", ""},
+	}
+
+	for i, r := range tampered {
+		nonce := strconv.Itoa(i)
+		if _, ret, _ := auth.Verify(r.method, r.path, r.query, signed(nonce), body); ret != merrors.ErrUnauthorized {
+			t.Fatalf("tampered request %s %s?%s should be rejected", r.method, r.path, r.query)
+		}
+	}
+
+	if _, ret, msg := auth.Verify("DELETE", "/v1/eips/10.0.0.1", "dryRun=true", signed("last"), body); ret != merrors.ErrSuccess {
+		t.Fatalf("request as signed should be accepted, %s", msg)
+	}
+}
+
+func TestInitAuth(t *testing.T) {
+
+	initTestLog()
+
+	saved := GAuthenticator
+	defer func() { GAuthenticator = saved }()
+
+	bads := []map[string]string{
+		nil,
+		{"center": ""},
+		{"center": "changeme"},
+	}
+	for _, keys := range bads {
+		if err := InitAuth(&configuration.AuthConfig{Enabled: true, Keys: keys}); err == nil {
+			t.Fatalf("auth enabled with keys %v should be refused", keys)
+		}
+	}
+
+	if err := InitAuth(&configuration.AuthConfig{Enabled: true, Keys: map[string]string{"center": "s3cr3t"}}); err != nil {
+		t.Fatalf("auth enabled with keys should be accepted, %s", err)
+	}
+	if err := InitAuth(&configuration.AuthConfig{}); err != nil {
+		t.Fatalf("auth disabled should be accepted without keys, %s", err)
+	}
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"errors"
+	"io/ioutil"
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useMemoryBackend of config for commits, returns func to restore
+func useMemoryBackend(config string) (*vyos.MemoryBackend, func()) {
+
+	dir, _ := ioutil.TempDir("", "ovs-api")
+	lockFile, backend, resources := vyos.CommitLockFile, vyos.GBackend, plugins.GResources
+
+	vyos.CommitLockFile = filepath.Join(dir, "commit.lock")
+	memory := vyos.NewMemoryBackend(config)
+	vyos.GBackend = memory
+	vyos.ResetRuleAllocator()
+	plugins.GResources, _ = plugins.NewResourceStore("")
+
+	return memory, func() {
+		vyos.CommitLockFile, vyos.GBackend, plugins.GResources = lockFile, backend, resources
+		os.RemoveAll(dir)
+	}
+}
+
+// batchParas of ops, each op as api and its paras
+func batchParas(ops ...map[string]interface{}) *Paras {
+
+	list := make([]interface{}, len(ops))
+	for i, op := range ops {
+		list[i] = op
+	}
+
+	return &Paras{
+		Proto: FindProto(batchAPIKey),
+		InParas: &inputParas{
+			API:   batchAPIKey,
+			Paras: map[string]interface{}{"ops": list},
+		},
+	}
+}
+
+func addVipOp(ip, mac string) map[string]interface{} {
+	return map[string]interface{}{
+		"api": APIPrefixCenter + ".vip.APIAddVip",
+		"paras": map[string]interface{}{
+			"ip":               ip,
+			"netmask":          "255.255.255.0",
+			"ownerEthernetMac": mac,
+		},
+	}
+}
+
+func TestBatch(t *testing.T) {
+
+	initTestLog()
+	vyos.InitLog(0)
+	plugins.InitLog(0)
+
+	backend, restore := useMemoryBackend(e2eConfig)
+	defer restore()
+	defer useFakeNics()()
+
+	running, _ := backend.ShowConfiguration()
+
+	// failing step drops changes of steps before it
+	paras := batchParas(
+		addVipOp("192.168.0.5", "fa:16:3e:00:00:02"),
+		addVipOp("192.168.0.6", "fa:16:3e:00:00:99"),
+		addVipOp("192.168.0.7", "fa:16:3e:00:00:02"),
+	)
+	if ret, msg := checkParas(paras); ret != merrors.ErrSuccess {
+		t.Fatalf("batch paras should be accepted, %s", msg)
+	}
+
+	resp := Batch(paras)
+	if resp.Error == merrors.ErrSuccess {
+		t.Fatalf("batch should fail by step 1, %s got", resp.ErrorLog)
+	}
+
+	results := resp.Data.([]*BatchResult)
+	states := []string{BatchStepSuccess, BatchStepFailed, BatchStepSkipped}
+	for i, state := range states {
+		if results[i].State != state {
+			t.Fatalf("step %d should be %s, %s got", i, state, results[i].State)
+		}
+	}
+
+	if config, _ := backend.ShowConfiguration(); config != running || backend.Commits != 0 {
+		t.Fatalf("failed batch should change nothing, %d commits %s got", backend.Commits, config)
+	}
+
+	// failing commit discards all steps
+	backend.CommitError = errors.New("commit failed")
+	resp = Batch(batchParas(addVipOp("192.168.0.5", "fa:16:3e:00:00:02")))
+	if resp.Error == merrors.ErrSuccess || resp.Data.([]*BatchResult)[0].State != BatchStepDiscarded {
+		t.Fatalf("batch failed to commit should be discarded, %v got", resp)
+	}
+	if config, _ := backend.ShowConfiguration(); config != running {
+		t.Fatalf("batch failed to commit should change nothing, %s got", config)
+	}
+
+	// all steps applied in one commit
+	resp = Batch(batchParas(
+		addVipOp("192.168.0.5", "fa:16:3e:00:00:02"),
+		addVipOp("192.168.0.6", "fa:16:3e:00:00:02"),
+	))
+	if resp.Error != merrors.ErrSuccess {
+		t.Fatalf("batch should succeed, %s got", resp.ErrorLog)
+	}
+
+	if backend.Commits != 1 {
+		t.Fatalf("batch should be applied in one commit, %d got", backend.Commits)
+	}
+
+	config, _ := backend.ShowConfiguration()
+	addresses := vyos.NewParserFromConfiguration(config).Tree.Get("interfaces ethernet eth1 address")
+	if addresses == nil || len(addresses.Values()) != 3 {
+		t.Fatalf("vips of all steps should be applied, %s got", config)
+	}
+}
+
+func TestBatchRejectsExclusive(t *testing.T) {
+
+	initTestLog()
+	vyos.InitLog(0)
+	plugins.InitLog(0)
+
+	backend, restore := useMemoryBackend(e2eConfig)
+	defer restore()
+	defer useFakeNics()()
+
+	for _, api := range []string{
+		APIPrefixCenter + ".commit.APIShowPendingCommit",
+		APIPrefixCenter + ".commit.APIConfirmCommit",
+		APIPrefixCenter + ".snapshot.APICreateSnapshot",
+		APIPrefixCenter + ".snapshot.APIRollbackSnapshot",
+		APIPrefixCenter + ".job.APIListJobs",
+		batchAPIKey,
+	} {
+		done := make(chan *Response, 1)
+		go func() {
+			done <- Batch(batchParas(
+				addVipOp("192.168.0.5", "fa:16:3e:00:00:02"),
+				map[string]interface{}{"api": api, "paras": map[string]interface{}{}},
+			))
+		}()
+
+		select {
+		case resp := <-done:
+			if resp.Error != merrors.ErrBadParas || resp.Data.([]*BatchResult)[1].State != BatchStepFailed {
+				t.Fatalf("%s should be rejected in batch, %d %s got", api, resp.Error, resp.ErrorLog)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("batch with %s should not hang", api)
+		}
+	}
+
+	// commit lock released by batches rejected
+	resp := Batch(batchParas(addVipOp("192.168.0.5", "fa:16:3e:00:00:02")))
+	if resp.Error != merrors.ErrSuccess || backend.Commits != 1 {
+		t.Fatalf("batch after rejected ones should commit, %s got", resp.ErrorLog)
+	}
+}
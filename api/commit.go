@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+	"time"
+)
+
+// Commit run fn against the running configuration through the commit
+// manager, changes are applied only if fn returns nil and there are any.
+// With confirmTimeout, changes are reverted unless confirmed in time.
+// In a batch, fn runs against the shared batch tree and nothing is applied.
+// Commit hooks of the tree run if fn returns nil and not dry run, once
+// confirmed if changes are to be confirmed.
+func (p *Paras) Commit(fn func(tree *vyos.ConfigTree) error) error {
+
+	if p.batch != nil {
+		return fn(p.batch)
+	}
+
+	var err error
+
+	var changes *vyos.ConfigTree
+	applying := false
+	confirming := false
+
+	defer func() {
+		if r := recover(); r != nil {
+			if applying {
+				notifyCommit(p, changes.Commands(), fmt.Sprint(r))
+			}
+			panic(r)
+		}
+	}()
+
+	modify := func(tree *vyos.ConfigTree) bool {
+		err = fn(tree)
+		changes = tree
+		applying = err == nil && !p.InParas.DryRun && tree.HasChanges()
+
+		// hooks left to the commit manager, run once confirmed
+		confirming = applying && p.InParas.ConfirmTimeout > 0
+		if confirming {
+			commands := tree.Commands()
+			tree.OnRevert(func() {
+				notifyRevert(p, commands)
+			})
+		}
+
+		return applying
+	}
+
+	var tree *vyos.ConfigTree
+	var cerr error
+	if timeout := p.InParas.ConfirmTimeout; timeout > 0 && !p.InParas.DryRun {
+		tree, cerr = vyos.GCommitManager.CommitConfirm(false, time.Duration(timeout)*time.Second, modify)
+	} else {
+		tree, cerr = vyos.GCommitManager.Commit(false, modify)
+	}
+
+	if cerr != nil {
+		return merrors.Wrap(cerr, merrors.ErrCommitPending, "confirm the pending commit or wait for it reverted")
+	}
+
+	if applying {
+		notifyCommit(p, tree.Commands(), "")
+	}
+
+	if err == nil && !p.InParas.DryRun && !confirming {
+		tree.Committed()
+	}
+
+	if err == nil || p.InParas.DryRun {
+		p.commands = append(p.commands, tree.Commands()...)
+	}
+
+	return err
+}
+
+// DryRunResult of mutating api called with dryRun
+type DryRunResult struct {
+	DryRun   bool        `json:"dryRun"`
+	Commands []string    `json:"commands"`
+	Data     interface{} `json:"data"`
+}
+
+// dryRunResponse wrap data of resp with planned commands
+func dryRunResponse(paras *Paras, resp *Response) *Response {
+
+	commands := paras.commands
+	if commands == nil {
+		commands = []string{}
+	}
+
+	resp.Data = &DryRunResult{
+		DryRun:   true,
+		Commands: commands,
+		Data:     resp.Data,
+	}
+
+	return resp
+}
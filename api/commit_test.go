@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils/vyos"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDryRun(t *testing.T) {
+
+	initTestLog()
+	vyos.InitLog(0)
+	plugins.InitLog(0)
+
+	backend, restore := useMemoryBackend(e2eConfig)
+	defer restore()
+	defer useFakeNics()()
+
+	router := gin.New()
+	api := &API{}
+	api.restRoutes(router)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	running, _ := backend.ShowConfiguration()
+
+	vip := `{"ip":"192.168.0.5","netmask":"255.255.255.0","ownerEthernetMac":"fa:16:3e:00:00:02"}`
+	w := call(http.MethodPost, "/v1/vips?dryRun=true", vip)
+
+	var planned struct {
+		Data DryRunResult `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &planned); err != nil || !planned.Data.DryRun ||
+		len(planned.Data.Commands) != 1 || !strings.Contains(planned.Data.Commands[0], "192.168.0.5/24") {
+		t.Fatalf("dry run should return planned commands, %d %s got", w.Code, w.Body.String())
+	}
+
+	if config, _ := backend.ShowConfiguration(); config != running || backend.Commits != 0 {
+		t.Fatalf("dry run should change nothing, %d commits %s got", backend.Commits, config)
+	}
+	if len(plugins.GResources.List("")) != 0 {
+		t.Fatalf("dry run should store nothing, %v got", plugins.GResources.List(""))
+	}
+
+	// commit waiting for confirm kept by dry run of confirm
+	if w := call(http.MethodPost, "/v1/vips?confirmTimeout=60", vip); w.Code != http.StatusCreated {
+		t.Fatalf("add vip to confirm should succeed, %d %s got", w.Code, w.Body.String())
+	}
+	defer vyos.GCommitManager.Confirm()
+
+	if len(plugins.GResources.List(plugins.ResourceVip)) != 0 {
+		t.Fatalf("vip should not be stored before confirmed")
+	}
+
+	if w := call(http.MethodPost, "/v1/commits/confirm?dryRun=true", ""); w.Code != http.StatusCreated {
+		t.Fatalf("dry run of confirm should succeed, %d %s got", w.Code, w.Body.String())
+	}
+	if vyos.GCommitManager.Pending() == nil {
+		t.Fatalf("dry run of confirm should keep commit pending")
+	}
+
+	if w := call(http.MethodPost, "/v1/commits/confirm", ""); w.Code != http.StatusCreated ||
+		vyos.GCommitManager.Pending() != nil {
+		t.Fatalf("confirm should keep changes, %d %s got", w.Code, w.Body.String())
+	}
+	if len(plugins.GResources.List(plugins.ResourceVip)) != 1 {
+		t.Fatalf("vip should be stored once confirmed")
+	}
+}
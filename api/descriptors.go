@@ -8,6 +8,11 @@ var apiDescriptors = []Module{
 	nicDescriptors,
 	vipDescriptors,
 	eipDescriptors,
+	jobDescriptors,
+	batchDescriptors,
+	auditDescriptors,
+	commitDescriptors,
+	snapshotDescriptors,
 }
 
 func loadModules(module Module) {
@@ -20,18 +25,40 @@ func loadModules(module Module) {
 }
 
 func init() {
+	loadDescriptors()
+}
+
+// loadDescriptors of all modules, protos registered per version served
+func loadDescriptors() {
 
 	GServices = make(map[string]*Service, 10000)
+	GProtos = make(map[string]*Proto, 10000)
 
-	for _, descriptor := range apiDescriptors {
+	for i := range apiDescriptors {
+		descriptor := &apiDescriptors[i]
 		for key, proto := range descriptor.Protos {
-			service := new(Service)
-			service.Name = proto.Name
-			service.Handler = proto.handler
-			proto.Key = APIPrefixCenter + "." + descriptor.Name + "." + key
-			descriptor.Protos[key] = proto
-			GServices[APIPrefixCenter+"."+descriptor.Name+"."+key] = service
+			for _, version := range GAPIVersions {
+				if !proto.serves(descriptor, version.Name) {
+					continue
+				}
+
+				versioned := proto
+				versioned.Key = APIPrefix + "." + version.Name + "." + descriptor.Name + "." + key
+				versioned.Version = version.Name
+				versioned.Deprecated = version.Deprecated
+
+				GProtos[versioned.Key] = &versioned
+				GServices[versioned.Key] = &Service{
+					Name:    versioned.Name,
+					Handler: versioned.handler,
+				}
+
+				// test page shows protos of current version
+				if version.Name == APIVersionCurrent {
+					descriptor.Protos[key] = versioned
+				}
+			}
 		}
-		loadModules(descriptor)
+		loadModules(*descriptor)
 	}
 }
@@ -0,0 +1,42 @@
+package api
+
+// auditDescriptors for audit journal by API
+var auditDescriptors = Module{
+	Name:     "audit",
+	Versions: []string{APIVersionV5},
+	Protos: map[string]Proto{
+
+		"APIShowAuditLog": {
+			Name:    "查看审计日志",
+			NameEN:  "Show Audit Log",
+			handler: ShowAuditLog,
+			result:  []*AuditRecord{},
+			Paras: []ProtoPara{
+				{
+					Name:    "startTime",
+					Type:    ParamTypeInt,
+					Desc:    "Start time in unix seconds, 0 for no limitation",
+					Default: 0,
+				},
+				{
+					Name:    "endTime",
+					Type:    ParamTypeInt,
+					Desc:    "End time in unix seconds, 0 for no limitation",
+					Default: 0,
+				},
+				{
+					Name:    "module",
+					Type:    ParamTypeString,
+					Desc:    "Module of api like dnat,eip, empty for all",
+					Default: "",
+				},
+				{
+					Name:    "limit",
+					Type:    ParamTypeInt,
+					Desc:    "Max count of latest records, 0 for no limitation",
+					Default: 100,
+				},
+			},
+		},
+	},
+}
@@ -0,0 +1,29 @@
+package api
+
+const batchAPIKey = APIPrefixCenter + ".batch.APIBatch"
+
+// batchDescriptors for atomic batch of apis
+var batchDescriptors = Module{
+	Name:     "batch",
+	Versions: []string{APIVersionV5},
+	Protos: map[string]Proto{
+
+		"APIBatch": {
+			Name:      "批量执行",
+			NameEN:    "Run Batch",
+			handler:   Batch,
+			Mutating:  true,
+			exclusive: true,
+			result:    []*BatchResult{},
+			Paras: []ProtoPara{
+				{
+					Name:    "ops",
+					Type:    ParamTypeListObject,
+					Desc:    "Mutating APIs to run in order, like [{\"api\":\"octlink.virtualrouter.v5.vip.APIAddVip\",\"paras\":{}}]",
+					Default: ParamNotNull,
+					Fields:  objectFields(BatchOp{}),
+				},
+			},
+		},
+	},
+}
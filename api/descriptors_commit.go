@@ -0,0 +1,37 @@
+package api
+
+import (
+	"net/http"
+	"octlink/ovs/utils/vyos"
+)
+
+// commitDescriptors for commit confirm management by API
+var commitDescriptors = Module{
+	Name:     "commit",
+	Versions: []string{APIVersionV5},
+	Protos: map[string]Proto{
+
+		"APIShowPendingCommit": {
+			Name:      "查看待确认提交",
+			NameEN:    "Show Pending Commit",
+			handler:   ShowPendingCommit,
+			exclusive: true,
+			Method:    http.MethodGet,
+			Path:      "/commits/pending",
+			result:    &vyos.PendingConfirm{},
+			Paras:     []ProtoPara{},
+		},
+
+		"APIConfirmCommit": {
+			Name:      "确认提交",
+			NameEN:    "Confirm Commit",
+			handler:   ConfirmCommit,
+			Mutating:  true,
+			exclusive: true,
+			Method:    http.MethodPost,
+			Path:      "/commits/confirm",
+			result:    &vyos.PendingConfirm{},
+			Paras:     []ProtoPara{},
+		},
+	},
+}
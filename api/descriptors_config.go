@@ -1,12 +1,21 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 // configDescriptors for image management by API
 var configDescriptors = Module{
 	Name: "config",
 	Protos: map[string]Proto{
 		"APIShowSystemInfo": {
 			Name:    "查看系统信息",
+			NameEN:  "Show System Info",
 			handler: ShowSystemConfig,
+			Method:  http.MethodGet,
+			Path:    "/system",
+			result:  &plugins.SystemConfig{},
 			Paras:   []ProtoPara{},
 		},
 	},
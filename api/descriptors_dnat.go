@@ -1,5 +1,10 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 // dnatDescriptors for DNAT management by API
 var dnatDescriptors = Module{
 	Name: "dnat",
@@ -7,17 +12,37 @@ var dnatDescriptors = Module{
 
 		"APIShowDnats": {
 			Name:    "查看所有DNAT配置",
+			NameEN:  "Show All DNATs",
 			handler: ShowDnats,
-			Paras:   []ProtoPara{},
+			Method:  http.MethodGet,
+			Path:    "/dnats",
+			result:  []*plugins.Dnat{},
+			Paras: listParas(
+				filterPara("vipIp", ParamTypeIPv4, "VIP Address"),
+				filterPara("privateNicMac", ParamTypeMac, "Private Nic Mac Address"),
+				filterPara("privateIp", ParamTypeIPv4, "Private IP Address"),
+				ProtoPara{
+					Name:    "protocolType",
+					Type:    ParamTypeEnum,
+					Desc:    "Protocol Type",
+					Default: "",
+					Values:  []string{"TCP", "UDP"},
+				},
+				filterPara("port", ParamTypePort, "Port in vip or private port range"),
+			),
 		},
 
 		"APIShowDnat": {
 			Name:    "查看DNAT配置",
+			NameEN:  "Show DNAT",
 			handler: ShowDnat,
+			Method:  http.MethodGet,
+			Path:    "/dnats/:privateNicMac",
+			result:  &plugins.Dnat{},
 			Paras: []ProtoPara{
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of private nic",
 					Default: ParamNotNull,
 				},
@@ -25,134 +50,152 @@ var dnatDescriptors = Module{
 		},
 
 		"APIAddDnat": {
-			Name:    "添加DNAT",
-			handler: AddDnat,
+			Name:     "添加DNAT",
+			NameEN:   "Add DNAT",
+			handler:  AddDnat,
+			Method:   http.MethodPost,
+			Path:     "/dnats",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "vipPortStart",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "vip start port",
 					Default: 22,
 				},
 				{
 					Name:    "vipPortEnd",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "vip end port",
 					Default: 22,
 				},
 				{
 					Name:    "privatePortStart",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "private ip start port",
 					Default: 22,
 				},
 				{
 					Name:    "privatePortEnd",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "private ip end port",
 					Default: 22,
 				},
 				{
 					Name:    "protocolType",
-					Type:    ParamTypeString,
+					Type:    ParamTypeEnum,
+					Values:  []string{"TCP", "UDP"},
 					Desc:    "prototol type",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "vipIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Vip Ip Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "privateIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Private IP Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Private Nic Mac Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "allowedCidr",
-					Type:    ParamTypeString,
+					Type:    ParamTypeCIDR,
 					Desc:    "allowed CIDR",
 					Default: "",
 				},
 			},
 		},
 		"APISyncDnats": {
-			Name:    "同步所有DNAT配置",
-			handler: SyncDnats,
+			Name:     "同步所有DNAT配置",
+			NameEN:   "Sync All DNATs",
+			handler:  SyncDnats,
+			Method:   http.MethodPut,
+			Path:     "/dnats",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "dnats",
-					Type:    ParamTypeString,
+					Type:    ParamTypeListObject,
 					Desc:    "DNAT Config in list []",
 					Default: ParamNotNull,
+					Fields:  objectFields(plugins.Dnat{}),
 				},
 			},
 		},
 		"APIRemoveDnat": {
-			Name:    "删除DNAT配置",
-			handler: RemoveDnat,
+			Name:     "删除DNAT配置",
+			NameEN:   "Remove DNAT",
+			handler:  RemoveDnat,
+			Method:   http.MethodDelete,
+			Path:     "/dnats",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "vipPortStart",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "vip start port",
 					Default: 22,
 				},
 				{
 					Name:    "vipPortEnd",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "vip end port",
 					Default: 22,
 				},
 				{
 					Name:    "privatePortStart",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "private ip start port",
 					Default: 22,
 				},
 				{
 					Name:    "privatePortEnd",
-					Type:    ParamTypeInt,
+					Type:    ParamTypePort,
 					Desc:    "private ip end port",
 					Default: 22,
 				},
 				{
 					Name:    "protocolType",
-					Type:    ParamTypeString,
+					Type:    ParamTypeEnum,
+					Values:  []string{"TCP", "UDP"},
 					Desc:    "prototol type",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "vipIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Vip Ip Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Private Nic Mac Address",
 					Default: ParamNotNull,
 				},
 			},
 		},
 		"APIRemoveDnats": {
-			Name:    "删除所有DNAT配置",
-			handler: RemoveDnats,
+			Name:     "删除所有DNAT配置",
+			NameEN:   "Remove DNATs",
+			handler:  RemoveDnats,
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "dnats",
-					Type:    ParamTypeString,
+					Type:    ParamTypeListObject,
 					Desc:    "DNAT Config in list []",
 					Default: ParamNotNull,
+					Fields:  objectFields(plugins.Dnat{}),
 				},
 			},
 		},
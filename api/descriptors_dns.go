@@ -1,23 +1,32 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 // dnsDescriptors for DNS management by API
 var dnsDescriptors = Module{
 	Name: "dns",
 	Protos: map[string]Proto{
 
 		"APIAddDns": {
-			Name:    "添加DNS",
-			handler: AddDns,
+			Name:     "添加DNS",
+			NameEN:   "Add DNS",
+			handler:  AddDns,
+			Method:   http.MethodPost,
+			Path:     "/dns",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "dnsAddress",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "dns server address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Public Nic Mac Address",
 					Default: ParamNotNull,
 				},
@@ -25,12 +34,16 @@ var dnsDescriptors = Module{
 		},
 
 		"APIRemoveDns": {
-			Name:    "删除DNS",
-			handler: DeleteDns,
+			Name:     "删除DNS",
+			NameEN:   "Remove DNS",
+			handler:  DeleteDns,
+			Method:   http.MethodDelete,
+			Path:     "/dns",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "dnsAddress",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "dns server address",
 					Default: ParamNotNull,
 				},
@@ -39,8 +52,15 @@ var dnsDescriptors = Module{
 
 		"APIShowDns": {
 			Name:    "查看DNS",
+			NameEN:  "Show DNS",
 			handler: ShowDns,
-			Paras:   []ProtoPara{},
+			Method:  http.MethodGet,
+			Path:    "/dns",
+			result:  []*plugins.Dns{},
+			Paras: listParas(
+				filterPara("dnsAddress", ParamTypeIPv4, "dns server address"),
+				filterPara("publicNicMac", ParamTypeMac, "Public Nic Mac Address"),
+			),
 		},
 	},
 }
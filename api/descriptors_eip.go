@@ -1,22 +1,38 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 var eipDescriptors = Module{
 	Name: "eip",
 	Protos: map[string]Proto{
 
 		"APIShowEips": {
 			Name:    "查看所有EIP配置",
+			NameEN:  "Show All EIPs",
 			handler: ShowEips,
-			Paras:   []ProtoPara{},
+			Method:  http.MethodGet,
+			Path:    "/eips",
+			result:  []*plugins.EipInfo{},
+			Paras: listParas(
+				filterPara("vip", ParamTypeIPv4, "VIP Address"),
+				filterPara("guestIp", ParamTypeIPv4, "Guest IP Address"),
+				filterPara("privateMac", ParamTypeMac, "Mac Address of private nic"),
+				filterPara("publicMac", ParamTypeMac, "Mac Address of public nic"),
+			),
 		},
 
 		"APIShowEip": {
 			Name:    "查看EIP配置",
+			NameEN:  "Show EIP",
 			handler: ShowEip,
+			result:  &plugins.EipInfo{},
 			Paras: []ProtoPara{
 				{
 					Name:    "privateMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of private nic",
 					Default: ParamNotNull,
 				},
@@ -24,30 +40,34 @@ var eipDescriptors = Module{
 		},
 
 		"APICreateEip": {
-			Name:    "建立EIP配置",
-			handler: CreateEip,
+			Name:     "建立EIP配置",
+			NameEN:   "Create EIP",
+			handler:  CreateEip,
+			Method:   http.MethodPost,
+			Path:     "/eips",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "privateMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of private nic",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of public nic",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "vip",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Virtual IP Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "guestIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Guest IP Address",
 					Default: ParamNotNull,
 				},
@@ -55,56 +75,68 @@ var eipDescriptors = Module{
 		},
 
 		"APISyncEips": {
-			Name:    "同步所有EIP配置",
-			handler: SyncEips,
+			Name:     "同步所有EIP配置",
+			NameEN:   "Sync All EIPs",
+			handler:  SyncEips,
+			Method:   http.MethodPut,
+			Path:     "/eips",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "eips",
-					Type:    ParamTypeString,
+					Type:    ParamTypeListObject,
 					Desc:    "EIP Config in list []",
 					Default: ParamNotNull,
+					Fields:  objectFields(plugins.EipInfo{}),
 				},
 			},
 		},
 
 		"APIRemoveEips": {
-			Name:    "删除所有EIP配置",
-			handler: RemoveEips,
+			Name:     "删除所有EIP配置",
+			NameEN:   "Remove EIPs",
+			handler:  RemoveEips,
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "eips",
-					Type:    ParamTypeString,
+					Type:    ParamTypeListObject,
 					Desc:    "EIP Config in list []",
 					Default: ParamNotNull,
+					Fields:  objectFields(plugins.EipInfo{}),
 				},
 			},
 		},
 
 		"APIRemoveEip": {
-			Name:    "删除EIP配置",
-			handler: RemoveEip,
+			Name:     "删除EIP配置",
+			NameEN:   "Remove EIP",
+			handler:  RemoveEip,
+			Method:   http.MethodDelete,
+			Path:     "/eips/:vip",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "privateMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of private nic",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of public nic",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "vip",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Virtual IP Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "guestIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Guest IP Address",
 					Default: ParamNotNull,
 				},
@@ -0,0 +1,58 @@
+package api
+
+import "net/http"
+
+// jobDescriptors for async job management by API
+var jobDescriptors = Module{
+	Name:     "job",
+	Versions: []string{APIVersionV5},
+	Protos: map[string]Proto{
+
+		"APIQueryJob": {
+			Name:    "查看任务",
+			NameEN:  "Show Job",
+			handler: QueryJob,
+			Method:  http.MethodGet,
+			Path:    "/jobs/:id",
+			result:  &Job{},
+			Paras: []ProtoPara{
+				{
+					Name:    "id",
+					Type:    ParamTypeString,
+					Desc:    "Job ID",
+					Default: ParamNotNull,
+				},
+			},
+		},
+
+		"APIListJobs": {
+			Name:    "查看所有任务",
+			NameEN:  "Show All Jobs",
+			handler: ListJobs,
+			Method:  http.MethodGet,
+			Path:    "/jobs",
+			result:  []*Job{},
+			Paras: listParas(
+				filterPara("state", ParamTypeString, "Job State, pending,running,finished,failed,cancelled"),
+				filterPara("api", ParamTypeString, "API of job"),
+			),
+		},
+
+		"APICancelJob": {
+			Name:    "取消任务",
+			NameEN:  "Cancel Job",
+			handler: CancelJob,
+			Method:  http.MethodDelete,
+			Path:    "/jobs/:id",
+			result:  &Job{},
+			Paras: []ProtoPara{
+				{
+					Name:    "id",
+					Type:    ParamTypeString,
+					Desc:    "Job ID",
+					Default: ParamNotNull,
+				},
+			},
+		},
+	},
+}
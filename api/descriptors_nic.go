@@ -1,44 +1,65 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 var nicDescriptors = Module{
 	Name: "nic",
 	Protos: map[string]Proto{
 		"APIShowInterfaces": {
 			Name:    "查看接口信息",
+			NameEN:  "Show Interfaces",
 			handler: ShowInterfaces,
-			Paras:   []ProtoPara{},
+			Method:  http.MethodGet,
+			Path:    "/interfaces",
+			result:  []*plugins.IfInfo{},
+			Paras: listParas(
+				filterPara("name", ParamTypeString, "Interface name like eth0"),
+				filterPara("mac", ParamTypeMac, "Mac Address"),
+				filterPara("ip", ParamTypeIPv4, "IP Address"),
+			),
 		},
 		"APISetInterface": {
-			Name:    "设置接口信息",
-			handler: SetInterface,
+			Name:     "设置接口信息",
+			NameEN:   "Set Interface",
+			handler:  SetInterface,
+			Method:   http.MethodPut,
+			Path:     "/interfaces/:mac",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "mac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of this nic",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "ip",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "IP Address of this nic",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "netmask",
-					Type:    ParamTypeString,
+					Type:    ParamTypeNetmask,
 					Desc:    "Netmask of Address",
 					Default: ParamNotNull,
 				},
 			},
 		},
 		"APIRemoveInterface": {
-			Name:    "删除接口配置",
-			handler: RemoveInterface,
+			Name:     "删除接口配置",
+			NameEN:   "Remove Interface",
+			handler:  RemoveInterface,
+			Method:   http.MethodDelete,
+			Path:     "/interfaces/:mac",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "mac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Mac Address of this nic",
 					Default: ParamNotNull,
 				},
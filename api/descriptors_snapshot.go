@@ -0,0 +1,77 @@
+package api
+
+import (
+	"net/http"
+	"octlink/ovs/utils/vyos"
+)
+
+// snapshotNamePara of snapshot apis
+var snapshotNamePara = ProtoPara{
+	Name:    "name",
+	Type:    ParamTypeString,
+	Desc:    "Snapshot Name",
+	Default: ParamNotNull,
+}
+
+// snapshotDescriptors for configuration snapshots by API
+var snapshotDescriptors = Module{
+	Name:     "snapshot",
+	Versions: []string{APIVersionV5},
+	Protos: map[string]Proto{
+
+		"APIListSnapshots": {
+			Name:    "查看所有快照",
+			NameEN:  "Show All Snapshots",
+			handler: ListSnapshots,
+			Method:  http.MethodGet,
+			Path:    "/snapshots",
+			result:  []*vyos.Snapshot{},
+			Paras: listParas(
+				filterPara("auto", ParamTypeString, "true for snapshots taken before commits"),
+			),
+		},
+
+		"APICreateSnapshot": {
+			Name:      "创建快照",
+			NameEN:    "Create Snapshot",
+			handler:   CreateSnapshot,
+			exclusive: true,
+			Method:    http.MethodPost,
+			Path:      "/snapshots",
+			result:    &vyos.Snapshot{},
+			Paras:     []ProtoPara{snapshotNamePara},
+		},
+
+		"APIDiffSnapshot": {
+			Name:    "对比快照",
+			NameEN:  "Diff Snapshot",
+			handler: DiffSnapshot,
+			Method:  http.MethodGet,
+			Path:    "/snapshots/:name/diff",
+			result:  &SnapshotDiff{},
+			Paras:   []ProtoPara{snapshotNamePara},
+		},
+
+		"APIRollbackSnapshot": {
+			Name:      "回滚到快照",
+			NameEN:    "Rollback To Snapshot",
+			handler:   RollbackSnapshot,
+			Mutating:  true,
+			exclusive: true,
+			Method:    http.MethodPost,
+			Path:      "/snapshots/:name/rollback",
+			result:    &SnapshotDiff{},
+			Paras:     []ProtoPara{snapshotNamePara},
+		},
+
+		"APIDeleteSnapshot": {
+			Name:    "删除快照",
+			NameEN:  "Delete Snapshot",
+			handler: DeleteSnapshot,
+			Method:  http.MethodDelete,
+			Path:    "/snapshots/:name",
+			result:  &vyos.Snapshot{},
+			Paras:   []ProtoPara{snapshotNamePara},
+		},
+	},
+}
@@ -1,29 +1,38 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 // snatDescriptors for SNAT management by API
 var snatDescriptors = Module{
 	Name: "snat",
 	Protos: map[string]Proto{
 
 		"APIAddSnat": {
-			Name:    "添加SNAT",
-			handler: AddSnat,
+			Name:     "添加SNAT",
+			NameEN:   "Add SNAT",
+			handler:  AddSnat,
+			Method:   http.MethodPost,
+			Path:     "/snats",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Private Nic Mac Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Public Nic Mac Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Public IP Address",
 					Default: ParamNotNull,
 				},
@@ -31,24 +40,28 @@ var snatDescriptors = Module{
 		},
 
 		"APISyncSnat": {
-			Name:    "同步SNAT",
-			handler: SyncSnat,
+			Name:     "同步SNAT",
+			NameEN:   "Sync SNAT",
+			handler:  SyncSnat,
+			Method:   http.MethodPut,
+			Path:     "/snats",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Private Nic Mac Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Public Nic Mac Address",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "publicIp",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Public IP Address",
 					Default: ParamNotNull,
 				},
@@ -57,11 +70,15 @@ var snatDescriptors = Module{
 
 		"APIShowSnat": {
 			Name:    "查看单个SNAT",
+			NameEN:  "Show SNAT",
 			handler: ShowSnat,
+			Method:  http.MethodGet,
+			Path:    "/snats/:privateNicMac",
+			result:  &plugins.Snat{},
 			Paras: []ProtoPara{
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Private Nic Mac Address",
 					Default: ParamNotNull,
 				},
@@ -70,30 +87,29 @@ var snatDescriptors = Module{
 
 		"APIShowAllSnat": {
 			Name:    "查看所有SNAT",
+			NameEN:  "Show All SNATs",
 			handler: ShowAllSnats,
-			Paras: []ProtoPara{
-				{
-					Name:    "start",
-					Type:    ParamTypeInt,
-					Desc:    "开始位置",
-					Default: 0,
-				},
-				{
-					Name:    "limit",
-					Type:    "int",
-					Desc:    "获取条目",
-					Default: 15,
-				},
-			},
+			Method:  http.MethodGet,
+			Path:    "/snats",
+			result:  []*plugins.Snat{},
+			Paras: listParas(
+				filterPara("privateNicMac", ParamTypeMac, "Private Nic Mac Address"),
+				filterPara("publicIp", ParamTypeIPv4, "Public IP Address"),
+				filterPara("publicNicMac", ParamTypeMac, "Public Nic Mac Address"),
+			),
 		},
 
 		"APIRemoveSnat": {
-			Name:    "删除SNAT",
-			handler: DeleteSnat,
+			Name:     "删除SNAT",
+			NameEN:   "Remove SNAT",
+			handler:  DeleteSnat,
+			Method:   http.MethodDelete,
+			Path:     "/snats",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "privateNicMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Private Nic Mac Address",
 					Default: ParamNotNull,
 				},
@@ -1,53 +1,66 @@
 package api
 
+import (
+	"net/http"
+	"octlink/ovs/plugins"
+)
+
 // dnsDescriptors for VIP management by API
 var vipDescriptors = Module{
 	Name: "vip",
 	Protos: map[string]Proto{
 
 		"APIAddVip": {
-			Name:    "添加VIP",
-			handler: AddVip,
+			Name:     "添加VIP",
+			NameEN:   "Add VIP",
+			handler:  AddVip,
+			Method:   http.MethodPost,
+			Path:     "/vips",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "ip",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Virtual Ip",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "netmask",
-					Type:    ParamTypeString,
+					Type:    ParamTypeNetmask,
 					Desc:    "Virtual Ip Netmask",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "ownerEthernetMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Vip Owner Ethernet Mac",
 					Default: ParamNotNull,
 				},
 			},
 		},
 		"APIRemoveVip": {
-			Name:    "删除VIP",
-			handler: DeleteVip,
+			Name:     "删除VIP",
+			NameEN:   "Remove VIP",
+			handler:  DeleteVip,
+			Method:   http.MethodDelete,
+			Path:     "/vips",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "ip",
-					Type:    ParamTypeString,
+					Type:    ParamTypeIPv4,
 					Desc:    "Virtual Ip",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "netmask",
-					Type:    ParamTypeString,
+					Type:    ParamTypeNetmask,
 					Desc:    "Virtual Ip Netmask",
 					Default: ParamNotNull,
 				},
 				{
 					Name:    "ownerEthernetMac",
-					Type:    ParamTypeString,
+					Type:    ParamTypeMac,
 					Desc:    "Vip Owner Ethernet Mac",
 					Default: ParamNotNull,
 				},
@@ -55,14 +68,19 @@ var vipDescriptors = Module{
 		},
 
 		"APISyncVips": {
-			Name:    "同步所有VIP",
-			handler: SyncVips,
+			Name:     "同步所有VIP",
+			NameEN:   "Sync All VIPs",
+			handler:  SyncVips,
+			Method:   http.MethodPut,
+			Path:     "/vips",
+			Mutating: true,
 			Paras: []ProtoPara{
 				{
 					Name:    "vips",
-					Type:    ParamTypeString,
+					Type:    ParamTypeListObject,
 					Desc:    "VIP Config in list []",
 					Default: ParamNotNull,
+					Fields:  objectFields(plugins.Vip{}),
 				},
 			},
 		},
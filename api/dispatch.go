@@ -1,10 +1,15 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"octlink/ovs/utils"
 	"octlink/ovs/utils/httpresponse"
 	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
 	"reflect"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,6 +21,58 @@ type Response struct {
 	Data     interface{} `json:"data"`
 	Total    int         `json:"total"`
 	Count    int         `json:"count"`
+
+	// paged for list response with Total and Count
+	paged bool
+
+	// submitted for async request submitted as job
+	submitted bool
+
+	// retryAfter in seconds for request over limit
+	retryAfter int
+
+	// replayed for stored response of retried request id
+	replayed bool
+
+	// err structured error of response
+	err error
+}
+
+// newResponse of data, or of err if not nil
+func newResponse(data interface{}, err error) *Response {
+
+	if err == nil {
+		return &Response{
+			Data: data,
+		}
+	}
+
+	return &Response{
+		Error:    merrors.Code(err),
+		ErrorLog: merrors.Log(err),
+		err:      err,
+	}
+}
+
+// asError of failed response
+func (resp *Response) asError() error {
+
+	if resp.err != nil {
+		return resp.err
+	}
+
+	return merrors.Errorf(resp.Error, "%s", resp.ErrorLog)
+}
+
+// errorLog for rendering, structured error if any
+func (resp *Response) errorLog() interface{} {
+
+	var e *merrors.MError
+	if errors.As(resp.err, &e) {
+		return e
+	}
+
+	return resp.ErrorLog
 }
 
 /*
@@ -27,6 +84,9 @@ type Response struct {
 		"password": ""
 	},
 	"async": false,
+	"dryRun": false,
+	"requestId": "",
+	"confirmTimeout": 0,
 }
 */
 type inputParas struct {
@@ -34,18 +94,39 @@ type inputParas struct {
 	API    string
 	Paras  map[string]interface{}
 	Async  bool
+	DryRun bool
+
+	// RequestID for mutating request executed at most once
+	RequestID string
+
+	// ConfirmTimeout in seconds to revert changes unless confirmed, 0 for
+	// changes committed without confirm
+	ConfirmTimeout int
 }
 
 // Paras of API
 type Paras struct {
 	Proto   *Proto
 	InParas *inputParas
+
+	// batch tree shared by steps of APIBatch, changes are applied by batch
+	batch *vyos.ConfigTree
+
+	// commands committed, or planned by dry run
+	commands []string
+
+	// remote address and authenticated key of caller
+	remote string
+	caller string
 }
 
 // Get paras from Paras structure
 func (p *Paras) Get(name string) string {
 	if v := p.InParas.Paras[name]; v != nil {
-		return v.(string)
+		if s, ok := v.(string); ok {
+			return s
+		}
+		return fmt.Sprint(v)
 	}
 	return ""
 }
@@ -65,6 +146,15 @@ func (p *Paras) GetInt(name string) int {
 	return utils.NumberToInt(raw)
 }
 
+// GetObjects decode object list para into v, v is a pointer to slice
+func (p *Paras) GetObjects(name string, v interface{}) error {
+	data, err := json.Marshal(p.InParas.Paras[name])
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
 // GetInt64 paras from Paras structure
 func (p *Paras) GetInt64(name string) int64 {
 	return utils.StringToInt64(p.Get(name))
@@ -117,29 +207,31 @@ func getParas(c *gin.Context) (*Paras, int) {
 
 	apiParas.Proto = proto
 
+	if apiParas.InParas.Paras != nil {
+		proto.adaptParas(apiParas.InParas.Paras)
+	}
+
 	return apiParas, 0
 }
 
 func checkParas(apiParas *Paras) (int, string) {
 
+	if apiParas.InParas.Paras == nil {
+		apiParas.InParas.Paras = make(map[string]interface{})
+	}
+
 	protoParas := apiParas.Proto.Paras
 
 	for i := 0; i < len(protoParas); i++ {
 
-		protoParam := protoParas[i]
-		inParam := apiParas.InParas.Paras[protoParam.Name]
-
-		// if paras have default value and no input sepecified, set a default value
-		if protoParam.Default != ParamNotNull && inParam == nil {
-			apiParas.InParas.Paras[protoParam.Name] = protoParam.Default
-		}
+		protoParam := &protoParas[i]
 
-		logger.Debugf("param:%s, default:%s, value:%s\n", protoParam.Name,
-			protoParam.Default, inParam)
+		logger.Debugf("param:%s, default:%v, value:%v\n", protoParam.Name,
+			protoParam.Default, apiParas.InParas.Paras[protoParam.Name])
 
-		if protoParam.Default == ParamNotNull && inParam.(string) == "" {
-			errorMsg := "paras \"" + protoParam.Name + "\" must be specified"
-			return merrors.ErrNotEnoughParas, errorMsg
+		ret, msg := checkPara(protoParam, apiParas.InParas.Paras, "")
+		if ret != merrors.ErrSuccess {
+			return ret, msg
 		}
 	}
 
@@ -151,6 +243,11 @@ func (api *API) Dispatch(c *gin.Context) {
 
 	logger.Debugf("got api request\n")
 
+	if ret, msg := authenticate(c); ret != merrors.ErrSuccess {
+		httpresponse.Error(c, ret, msg)
+		return
+	}
+
 	paras, err := getParas(c)
 	if paras == nil {
 		logger.Errorf("No match proto found\n")
@@ -158,25 +255,84 @@ func (api *API) Dispatch(c *gin.Context) {
 		return
 	}
 
+	resp := serve(c, paras)
+	retryHeader(c, resp)
+	replayedHeader(c, resp)
+
+	if resp.Error == 0 && resp.paged {
+		httpresponse.OkList(c, resp.Data, resp.Total, resp.Count)
+	} else if resp.Error == 0 {
+		httpresponse.Ok(c, resp.Data)
+	} else {
+		httpresponse.Error(c, resp.Error, resp.errorLog())
+	}
+}
+
+// serve api request of paras, async request submitted as job
+func serve(c *gin.Context, paras *Paras) *Response {
+
+	if paras.Proto.Deprecated != "" {
+		logger.Warnf("deprecated api %s called by %s\n", paras.InParas.API, c.ClientIP())
+		httpresponse.Warn(c, paras.Proto.Deprecated)
+	}
+
+	paras.remote = c.ClientIP()
+	if key, ok := c.Get(ContextAuthKey); ok {
+		paras.caller = key.(string)
+	} else if c.Request.TLS != nil && len(c.Request.TLS.PeerCertificates) > 0 {
+		paras.caller = "cn:" + c.Request.TLS.PeerCertificates[0].Subject.CommonName
+	}
+
 	service := GetService(paras.InParas.API)
 	if service == nil {
 		logger.Errorf("No match service found\n")
-		httpresponse.Error(c, merrors.ErrNoSuchAPI, paras.InParas.API)
-		return
+		return &Response{
+			Error:    merrors.ErrNoSuchAPI,
+			ErrorLog: paras.InParas.API,
+		}
+	}
+
+	release, limited := limit(paras)
+	if limited != nil {
+		return limited
 	}
+	defer release()
 
 	ret, msg := checkParas(paras)
 	if ret != merrors.ErrSuccess {
-		logger.Errorf("Not Enough Paras\n")
-		httpresponse.Error(c, merrors.ErrNotEnoughParas, msg)
-		return
+		logger.Errorf("check paras error %s\n", msg)
+		resp := &Response{
+			Error:    ret,
+			ErrorLog: msg,
+		}
+		audit(paras, resp, time.Now())
+		notify(paras, resp)
+		return resp
 	}
 
-	resp := service.Handler(paras)
+	return idempotent(paras, func() *Response {
+		return execute(service, paras)
+	})
+}
 
-	if resp.Error == 0 {
-		httpresponse.Ok(c, resp.Data)
-	} else {
-		httpresponse.Error(c, resp.Error, resp.ErrorLog)
+// execute request of paras, async request submitted as job
+func execute(service *Service, paras *Paras) *Response {
+
+	if paras.InParas.Async && GJobManager != nil {
+		job, err := GJobManager.Submit(service, paras)
+		if err != merrors.ErrSuccess {
+			return &Response{
+				Error:    err,
+				ErrorLog: "submit job error",
+			}
+		}
+		return &Response{
+			Data: map[string]interface{}{
+				"jobId": job.ID,
+			},
+			submitted: true,
+		}
 	}
+
+	return callHandler(service, paras)
 }
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/vyos"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+const e2eConfig = `
+interfaces {
+    ethernet eth0 {
+        address 172.20.0.10/16
+        hw-id fa:16:3e:00:00:01
+    }
+    ethernet eth1 {
+        address 192.168.0.1/24
+        hw-id fa:16:3e:00:00:02
+    }
+    loopback lo {
+    }
+}
+service {
+    ssh {
+        port 22
+    }
+}
+`
+
+// fakeNics of the box, by name, with mac and addresses
+var fakeNics = map[string][]string{
+	"eth0": {"fa:16:3e:00:00:01", "172.20.0.10", "172.20.0.100"},
+	"eth1": {"fa:16:3e:00:00:02", "192.168.0.1"},
+	"eth2": {"fa:16:3e:00:00:03", "10.1.0.1"},
+}
+
+// useFakeNics for nic lookups, returns func to restore the system ones
+func useFakeNics() func() {
+
+	nics, info, byIP := utils.NicsSourceFunc, utils.NicInfoSourceFunc, utils.NicNameByIPSourceFunc
+
+	utils.NicsSourceFunc = func() (map[string]utils.Nic, error) {
+		nics := make(map[string]utils.Nic)
+		for name, nic := range fakeNics {
+			nics[name] = utils.Nic{Name: name, Mac: nic[0]}
+		}
+		return nics, nil
+	}
+
+	utils.NicInfoSourceFunc = func(nicname string) (string, string, string, error) {
+		if nic, ok := fakeNics[nicname]; ok {
+			return nic[1], "255.255.0.0", "", nil
+		}
+		return "", "", "", fmt.Errorf("no nic %s", nicname)
+	}
+
+	utils.NicNameByIPSourceFunc = func(ip string) (string, error) {
+		for name, nic := range fakeNics {
+			for _, addr := range nic[1:] {
+				if addr == ip {
+					return name, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("no nic with ip %s", ip)
+	}
+
+	return func() {
+		utils.NicsSourceFunc, utils.NicInfoSourceFunc, utils.NicNameByIPSourceFunc = nics, info, byIP
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+
+	initTestLog()
+	vyos.InitLog(0)
+	plugins.InitLog(0)
+
+	dir, _ := ioutil.TempDir("", "ovs-e2e")
+	defer os.RemoveAll(dir)
+	vyos.CommitLockFile = filepath.Join(dir, "commit.lock")
+
+	backend := vyos.NewMemoryBackend(e2eConfig)
+	saved := vyos.GBackend
+	vyos.GBackend = backend
+	vyos.ResetRuleAllocator()
+	defer func() { vyos.GBackend = saved }()
+
+	defer useFakeNics()()
+
+	store, _ := plugins.NewResourceStore(filepath.Join(dir, "resources.json"))
+	plugins.GResources = store
+
+	router := gin.New()
+	api := &API{}
+	api.restRoutes(router)
+
+	call := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return w
+	}
+
+	eip := `{"privateMac":"fa:16:3e:00:00:02","publicMac":"fa:16:3e:00:00:01",` +
+		`"vip":"172.20.0.100","guestIp":"192.168.0.10"}`
+	if w := call(http.MethodPost, "/v1/eips", eip); w.Code != http.StatusCreated {
+		t.Fatalf("create eip should succeed, %d %s got", w.Code, w.Body.String())
+	}
+
+	config, _ := backend.ShowConfiguration()
+	running := vyos.NewParserFromConfiguration(config).Tree
+	if running.Get("firewall name eth0.in rule") == nil ||
+		running.Get("interfaces ethernet eth0 firewall in name") == nil ||
+		running.Get("service ssh port").Value() != "22" {
+		t.Fatalf("eip should be committed to running configuration, %s got", config)
+	}
+
+	w := call(http.MethodGet, "/v1/eips", "")
+	var shown struct {
+		Data []*plugins.EipInfo `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &shown); err != nil || len(shown.Data) != 1 {
+		t.Fatalf("eip should be shown, %d %s got", w.Code, w.Body.String())
+	}
+	if got := shown.Data[0]; got.VipIP != "172.20.0.100" || got.PublicMac != "fa:16:3e:00:00:01" {
+		t.Fatalf("eip shown should be the one created, %+v got", got)
+	}
+
+	// failed commit leaves running configuration untouched
+	backend.CommitError = errors.New("commit failed")
+	other := strings.Replace(eip, "192.168.0.10", "192.168.0.11", 1)
+	if w := call(http.MethodPost, "/v1/eips", other); w.Code < http.StatusBadRequest {
+		t.Fatalf("create eip should fail by commit, %s got", w.Body.String())
+	}
+	if after, _ := backend.ShowConfiguration(); after != config {
+		t.Fatalf("failed commit should change nothing, %s got", after)
+	}
+
+	if backend.Commits != 1 {
+		t.Fatalf("one commit should succeed, %d got", backend.Commits)
+	}
+	if len(store.List(plugins.ResourceEip)) != 1 {
+		t.Fatalf("eip failed to commit should not be stored, %v got", store.List(""))
+	}
+
+	// stored resources survive restart
+	if reloaded, err := plugins.NewResourceStore(filepath.Join(dir, "resources.json")); err != nil ||
+		len(reloaded.List(plugins.ResourceEip)) != 1 {
+		t.Fatalf("eip should be stored in file, %v", err)
+	}
+
+	// removal deletes exactly the nodes owned
+	if w := call(http.MethodDelete, "/v1/eips/172.20.0.100", eip); w.Code != http.StatusOK {
+		t.Fatalf("remove eip should succeed, %d %s got", w.Code, w.Body.String())
+	}
+
+	config, _ = backend.ShowConfiguration()
+	running = vyos.NewParserFromConfiguration(config).Tree
+	if running.Get("nat") != nil || running.Get("firewall name eth0.in rule") != nil {
+		t.Fatalf("rules owned by eip should be deleted, %s got", config)
+	}
+	if running.Get("interfaces ethernet eth0 firewall in name") == nil {
+		t.Fatalf("nodes not owned by eip should be kept, %s got", config)
+	}
+	if len(store.List("")) != 0 {
+		t.Fatalf("eip removed should be dropped from store, %v got", store.List(""))
+	}
+
+	// nodes shared by resources kept until the last owner removed
+	for _, address := range []string{"8.8.8.8", "8.8.4.4"} {
+		body := `{"dnsAddress":"` + address + `","publicNicMac":"fa:16:3e:00:00:01"}`
+		if w := call(http.MethodPost, "/v1/dns", body); w.Code != http.StatusCreated {
+			t.Fatalf("add dns should succeed, %d %s got", w.Code, w.Body.String())
+		}
+	}
+	if w := call(http.MethodDelete, "/v1/dns", `{"dnsAddress":"8.8.8.8"}`); w.Code != http.StatusOK {
+		t.Fatalf("remove dns should succeed, %d %s got", w.Code, w.Body.String())
+	}
+
+	config, _ = backend.ShowConfiguration()
+	running = vyos.NewParserFromConfiguration(config).Tree
+	if running.Get("service dns forwarding listen-on eth0") == nil ||
+		running.Get("service dns forwarding name-server").Value() != "8.8.4.4" {
+		t.Fatalf("dns left should be kept with nic it listens on, %s got", config)
+	}
+
+	w = call(http.MethodGet, "/v1/dns", "")
+	var dns struct {
+		Data []*plugins.Dns `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &dns); err != nil || len(dns.Data) != 1 {
+		t.Fatalf("dns left should be shown, %s got", w.Body.String())
+	}
+
+	// each snat a rule of its own
+	for _, mac := range []string{"fa:16:3e:00:00:02", "fa:16:3e:00:00:03"} {
+		body := `{"privateNicMac":"` + mac + `","publicNicMac":"fa:16:3e:00:00:01","publicIp":"172.20.0.10"}`
+		if w := call(http.MethodPost, "/v1/snats", body); w.Code != http.StatusCreated {
+			t.Fatalf("add snat should succeed, %d %s got", w.Code, w.Body.String())
+		}
+	}
+	if w := call(http.MethodDelete, "/v1/snats", `{"privateNicMac":"fa:16:3e:00:00:02"}`); w.Code != http.StatusOK {
+		t.Fatalf("remove snat should succeed, %d %s got", w.Code, w.Body.String())
+	}
+
+	config, _ = backend.ShowConfiguration()
+	running = vyos.NewParserFromConfiguration(config).Tree
+	if running.Get("nat source rule 8888") != nil ||
+		running.Get("nat source rule 8889 source address").Value() != "10.1.0.0/16" {
+		t.Fatalf("snat left should keep its rule, %s got", config)
+	}
+	if sn, err := plugins.GetSnat("fa:16:3e:00:00:03"); err != nil || sn.PrivateNicIP != "10.1.0.1" {
+		t.Fatalf("snat left should be shown, %v got", err)
+	}
+}
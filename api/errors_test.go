@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"octlink/ovs/utils/merrors"
+	"testing"
+)
+
+func TestParseLang(t *testing.T) {
+
+	cases := map[string]string{
+		"":                           merrors.LangEN,
+		"zh-CN,zh;q=0.9,en;q=0.8":    merrors.LangZH,
+		"en-US,zh;q=0.5":             merrors.LangEN,
+		"fr-FR,zh_CN;q=0.7,en;q=0.3": merrors.LangZH,
+		"de":                         merrors.LangEN,
+	}
+
+	for header, want := range cases {
+		if lang := merrors.ParseLang(header); lang != want {
+			t.Fatalf("lang of %q should be %s, %s got", header, want, lang)
+		}
+	}
+}
+
+func TestNewResponse(t *testing.T) {
+
+	cause := errors.New("exit status 1")
+	err := merrors.Wrap(cause, merrors.ErrSegmentNotExist, "dnat of %s not exist", "10.0.0.1")
+
+	resp := newResponse(nil, err)
+	if resp.Error != merrors.ErrSegmentNotExist {
+		t.Fatalf("code of wrapped error should be kept, %d got", resp.Error)
+	}
+
+	if resp.ErrorLog != "dnat of 10.0.0.1 not exist: exit status 1" {
+		t.Fatalf("error log should carry detail and cause, %s got", resp.ErrorLog)
+	}
+
+	if e, ok := resp.errorLog().(*merrors.MError); !ok || !errors.Is(e, cause) {
+		t.Fatalf("structured error should be rendered, %v got", resp.errorLog())
+	}
+
+	resp = newResponse(nil, cause)
+	if resp.Error != merrors.ErrCommonErr || resp.errorLog() != "exit status 1" {
+		t.Fatalf("plain error should be common error, %v got", resp)
+	}
+
+	if resp = newResponse(1, nil); resp.Error != merrors.ErrSuccess || resp.Data != 1 {
+		t.Fatalf("nil error should be success, %v got", resp)
+	}
+}
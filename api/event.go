@@ -0,0 +1,455 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/httpresponse"
+	"octlink/ovs/utils/merrors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// EventTypeAPI for api request served
+	EventTypeAPI = "api"
+
+	// EventTypeCommit for configuration commit, succeeded or failed
+	EventTypeCommit = "commit"
+
+	// EventTypeNic for nic appeared or disappeared
+	EventTypeNic = "nic"
+
+	// EventTypeBoot for bootstrap stage recorded by ovsboot
+	EventTypeBoot = "boot"
+
+	// EventTypeLost for events dropped out of buffer before resuming point
+	EventTypeLost = "lost"
+
+	// EventModuleNic module of nic events
+	EventModuleNic = "nic"
+
+	// EventModuleBoot module of bootstrap events
+	EventModuleBoot = "boot"
+
+	// NicStateAdded for nic appeared
+	NicStateAdded = "added"
+
+	// NicStateRemoved for nic disappeared
+	NicStateRemoved = "removed"
+
+	// DefaultEventBuffer of latest events kept for resuming
+	DefaultEventBuffer = 1000
+
+	// DefaultNicPollInterval in seconds to watch nics
+	DefaultNicPollInterval = 5
+
+	// HeaderLastEventID sent by event source when reconnecting
+	HeaderLastEventID = "Last-Event-ID"
+
+	// pending events of one subscriber, slow subscriber dropped if exceeded
+	eventSubscriberBuffer = 256
+
+	eventHeartbeat = 15 * time.Second
+)
+
+// Event published to event stream
+type Event struct {
+	Seq    int64       `json:"seq"`
+	Time   int64       `json:"time"`
+	Type   string      `json:"type"`
+	Module string      `json:"module"`
+	Data   interface{} `json:"data"`
+}
+
+// APIEvent data of api event
+type APIEvent struct {
+	API      string `json:"api"`
+	Remote   string `json:"remote"`
+	Caller   string `json:"caller"`
+	Async    bool   `json:"async,omitempty"`
+	DryRun   bool   `json:"dryRun,omitempty"`
+	Error    int    `json:"error"`
+	ErrorLog string `json:"errorLog,omitempty"`
+}
+
+// CommitEvent data of commit event
+type CommitEvent struct {
+	API      string   `json:"api"`
+	Caller   string   `json:"caller"`
+	Success  bool     `json:"success"`
+	Commands []string `json:"commands"`
+	Error    string   `json:"error,omitempty"`
+
+	// Reverted for commands of commit reverted, not confirmed in time
+	Reverted bool `json:"reverted,omitempty"`
+}
+
+// NicEvent data of nic event
+type NicEvent struct {
+	Name  string `json:"name"`
+	Mac   string `json:"mac"`
+	State string `json:"state"`
+}
+
+// LostEvent data of lost event
+type LostEvent struct {
+	Since  int64 `json:"since"`
+	Oldest int64 `json:"oldest"`
+}
+
+// EventBus to publish events to subscribers, the latest events are kept for
+// subscribers resuming from a sequence number
+type EventBus struct {
+	lock        sync.Mutex
+	seq         int64
+	size        int
+	events      []*Event
+	subscribers map[*EventSubscriber]bool
+}
+
+// EventSubscriber receive events of its modules from C, C is closed if the
+// subscriber is too slow to keep up
+type EventSubscriber struct {
+	C       chan *Event
+	modules map[string]bool
+	bus     *EventBus
+}
+
+// GEventBus for global event bus, nil for no events
+var GEventBus *EventBus
+
+// InitEvents to init event bus, bootstrap stages replayed and nics watched
+func InitEvents(buffer int, nicPoll int) {
+
+	GEventBus = NewEventBus(buffer)
+
+	replayBootStages()
+
+	if nicPoll <= 0 {
+		nicPoll = DefaultNicPollInterval
+	}
+
+	go watchNics(time.Duration(nicPoll) * time.Second)
+}
+
+// NewEventBus to new an event bus keeping size latest events
+func NewEventBus(size int) *EventBus {
+
+	if size <= 0 {
+		size = DefaultEventBuffer
+	}
+
+	return &EventBus{
+		size:        size,
+		events:      make([]*Event, 0, size),
+		subscribers: make(map[*EventSubscriber]bool),
+	}
+}
+
+// match event to modules of subscriber, empty modules for all
+func (s *EventSubscriber) match(event *Event) bool {
+	return len(s.modules) == 0 || s.modules[event.Module]
+}
+
+// Close subscriber
+func (s *EventSubscriber) Close() {
+
+	s.bus.lock.Lock()
+	defer s.bus.lock.Unlock()
+
+	if s.bus.subscribers[s] {
+		delete(s.bus.subscribers, s)
+		close(s.C)
+	}
+}
+
+// Publish event of type and module
+func (bus *EventBus) Publish(typ string, module string, data interface{}) *Event {
+
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	bus.seq++
+	event := &Event{
+		Seq:    bus.seq,
+		Time:   utils.CurrentTime(),
+		Type:   typ,
+		Module: module,
+		Data:   data,
+	}
+
+	if len(bus.events) == bus.size {
+		copy(bus.events, bus.events[1:])
+		bus.events[len(bus.events)-1] = event
+	} else {
+		bus.events = append(bus.events, event)
+	}
+
+	for s := range bus.subscribers {
+		if !s.match(event) {
+			continue
+		}
+		select {
+		case s.C <- event:
+		default:
+			logger.Warnf("event subscriber too slow, dropped at event %d\n", event.Seq)
+			delete(bus.subscribers, s)
+			close(s.C)
+		}
+	}
+
+	return event
+}
+
+// Seq of the latest event
+func (bus *EventBus) Seq() int64 {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	return bus.seq
+}
+
+// Subscribe events of modules after sequence number since, buffered events
+// after since returned, lost is true if some of them already dropped
+func (bus *EventBus) Subscribe(since int64, modules []string) (*EventSubscriber, []*Event, bool) {
+
+	s := &EventSubscriber{
+		C:       make(chan *Event, eventSubscriberBuffer),
+		modules: make(map[string]bool, len(modules)),
+		bus:     bus,
+	}
+
+	for _, module := range modules {
+		s.modules[module] = true
+	}
+
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+
+	backlog := make([]*Event, 0)
+	for _, event := range bus.events {
+		if event.Seq > since && s.match(event) {
+			backlog = append(backlog, event)
+		}
+	}
+
+	lost := len(bus.events) > 0 && bus.events[0].Seq > since+1
+
+	bus.subscribers[s] = true
+
+	return s, backlog, lost
+}
+
+// publishEvent to global event bus if enabled
+func publishEvent(typ string, module string, data interface{}) {
+	if GEventBus != nil {
+		GEventBus.Publish(typ, module, data)
+	}
+}
+
+// notify api request with its response
+func notify(paras *Paras, resp *Response) {
+
+	if paras.batch != nil {
+		return
+	}
+
+	publishEvent(EventTypeAPI, apiModule(paras.InParas.API), &APIEvent{
+		API:      paras.InParas.API,
+		Remote:   paras.remote,
+		Caller:   paras.caller,
+		Async:    paras.InParas.Async,
+		DryRun:   paras.InParas.DryRun,
+		Error:    resp.Error,
+		ErrorLog: resp.ErrorLog,
+	})
+}
+
+// notifyCommit of changes applied by api of paras, errorLog empty for success
+func notifyCommit(paras *Paras, commands []string, errorLog string) {
+
+	if commands == nil {
+		commands = []string{}
+	}
+
+	publishEvent(EventTypeCommit, apiModule(paras.InParas.API), &CommitEvent{
+		API:      paras.InParas.API,
+		Caller:   paras.caller,
+		Success:  errorLog == "",
+		Commands: commands,
+		Error:    errorLog,
+	})
+}
+
+// notifyRevert of commands of api reverted, not confirmed in time
+func notifyRevert(paras *Paras, commands []string) {
+
+	publishEvent(EventTypeCommit, apiModule(paras.InParas.API), &CommitEvent{
+		API:      paras.InParas.API,
+		Caller:   paras.caller,
+		Success:  true,
+		Commands: commands,
+		Reverted: true,
+	})
+}
+
+// replayBootStages recorded by ovsboot as boot events
+func replayBootStages() {
+
+	stages, err := utils.LoadBootStages()
+	if err != nil {
+		logger.Warnf("load bootstrap stages from %s error %s\n", utils.BootStageFile, err)
+		return
+	}
+
+	for _, stage := range stages {
+		publishEvent(EventTypeBoot, EventModuleBoot, stage)
+	}
+}
+
+// watchNics poll nics, and publish nic events for appeared or disappeared ones
+func watchNics(interval time.Duration) {
+
+	known, err := utils.GetAllNics()
+	if err != nil {
+		logger.Errorf("get nics error %s\n", err)
+		known = make(map[string]utils.Nic)
+	}
+
+	for range time.Tick(interval) {
+		nics, err := utils.GetAllNics()
+		if err != nil {
+			logger.Errorf("get nics error %s\n", err)
+			continue
+		}
+
+		for name, nic := range nics {
+			if old, ok := known[name]; !ok || old.Mac != nic.Mac {
+				publishEvent(EventTypeNic, EventModuleNic, &NicEvent{
+					Name:  nic.Name,
+					Mac:   nic.Mac,
+					State: NicStateAdded,
+				})
+			}
+		}
+
+		for name, nic := range known {
+			if _, ok := nics[name]; !ok {
+				publishEvent(EventTypeNic, EventModuleNic, &NicEvent{
+					Name:  nic.Name,
+					Mac:   nic.Mac,
+					State: NicStateRemoved,
+				})
+			}
+		}
+
+		known = nics
+	}
+}
+
+// writeEvent in server-sent events format, lost events carry no id to keep
+// the resuming point of event source
+func writeEvent(w io.Writer, event *Event) error {
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if event.Type != EventTypeLost {
+		fmt.Fprintf(w, "id: %d\n", event.Seq)
+	}
+
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+
+	return err
+}
+
+// ShowEvents stream events as server-sent events, resumed after sequence
+// number of Last-Event-ID header or "since" query, and filtered by
+// comma separated "modules" query
+func (api *API) ShowEvents(c *gin.Context) {
+
+	if GEventBus == nil {
+		httpresponse.Error(c, merrors.ErrNotImplemented, "event stream disabled")
+		return
+	}
+
+	since := GEventBus.Seq()
+
+	value := c.Request.Header.Get(HeaderLastEventID)
+	if value == "" {
+		value = c.Query("since")
+	}
+
+	if value != "" {
+		seq, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || seq < 0 {
+			httpresponse.Error(c, merrors.ErrBadParas, "bad event sequence number "+value)
+			return
+		}
+		since = seq
+	}
+
+	modules := make([]string, 0)
+	for _, module := range strings.Split(c.Query("modules"), ",") {
+		if module = strings.TrimSpace(module); module != "" {
+			modules = append(modules, module)
+		}
+	}
+
+	s, backlog, lost := GEventBus.Subscribe(since, modules)
+	defer s.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Status(200)
+
+	w := c.Writer
+
+	if lost {
+		oldest := since + 1
+		if len(backlog) > 0 {
+			oldest = backlog[0].Seq
+		}
+		writeEvent(w, &Event{
+			Time: utils.CurrentTime(),
+			Type: EventTypeLost,
+			Data: &LostEvent{Since: since, Oldest: oldest},
+		})
+	}
+
+	for _, event := range backlog {
+		if writeEvent(w, event) != nil {
+			return
+		}
+	}
+	w.Flush()
+
+	gone := w.CloseNotify()
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-gone:
+			return
+		case event, ok := <-s.C:
+			if !ok {
+				return
+			}
+			if writeEvent(w, event) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+		}
+		w.Flush()
+	}
+}
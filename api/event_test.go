@@ -0,0 +1,96 @@
+package api
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestEventBus(t *testing.T) {
+
+	initTestLog()
+
+	bus := NewEventBus(3)
+	for _, module := range []string{"dnat", "eip", "dnat", "snat"} {
+		bus.Publish(EventTypeAPI, module, nil)
+	}
+
+	// event 1 dropped out of buffer
+	s, backlog, lost := bus.Subscribe(0, []string{"dnat"})
+	if !lost {
+		t.Fatalf("resuming from 0 should lose events")
+	}
+	if len(backlog) != 1 || backlog[0].Seq != 3 {
+		t.Fatalf("backlog should be event 3 of dnat, %v got", backlog)
+	}
+
+	bus.Publish(EventTypeAPI, "eip", nil)
+	bus.Publish(EventTypeCommit, "dnat", nil)
+
+	event := <-s.C
+	if event.Seq != 6 || event.Type != EventTypeCommit {
+		t.Fatalf("event 6 of dnat should be received, %v got", event)
+	}
+
+	s.Close()
+	s.Close()
+
+	_, backlog, lost = bus.Subscribe(4, nil)
+	if lost || len(backlog) != 2 {
+		t.Fatalf("events 5 and 6 should be resumed, %v got", backlog)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+
+	initTestLog()
+
+	GEventBus = NewEventBus(10)
+	defer func() {
+		GEventBus = nil
+	}()
+
+	publishEvent(EventTypeBoot, EventModuleBoot, nil)
+	publishEvent(EventTypeAPI, "dnat", nil)
+
+	router := gin.New()
+	router.GET("/api/events/", (&API{}).ShowEvents)
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/events/?modules=dnat,eip", nil)
+	req.Header.Set(HeaderLastEventID, "0")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request event stream error %s", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("content type should be text/event-stream, %s got", ct)
+	}
+
+	publishEvent(EventTypeCommit, "eip", nil)
+
+	reader := bufio.NewReader(resp.Body)
+	ids := make([]string, 0)
+	for len(ids) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event stream error %s", err)
+		}
+		if strings.HasPrefix(line, "id: ") {
+			ids = append(ids, strings.TrimSpace(line[4:]))
+		}
+	}
+
+	if ids[0] != "2" || ids[1] != "3" {
+		t.Fatalf("events 2 and 3 should be streamed, %v got", ids)
+	}
+}
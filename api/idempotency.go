@@ -0,0 +1,163 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"octlink/ovs/utils/merrors"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// HeaderIdempotencyKey for request id of restful request
+	HeaderIdempotencyKey = "Idempotency-Key"
+
+	// HeaderReplayed set if the response is replayed for a retried request id
+	HeaderReplayed = "Idempotent-Replayed"
+
+	// DefaultIdempotencyRetention in seconds of stored responses
+	DefaultIdempotencyRetention = 24 * 3600
+)
+
+// idempotentEntry of a request id, done closed when resp stored
+type idempotentEntry struct {
+	hash   string
+	resp   *Response
+	done   chan struct{}
+	expire time.Time
+}
+
+// IdempotencyStore of responses by request id of mutating requests
+type IdempotencyStore struct {
+	lock      sync.Mutex
+	retention time.Duration
+	entries   map[string]*idempotentEntry
+}
+
+// GIdempotencyStore for global idempotency, nil for requestId ignored
+var GIdempotencyStore *IdempotencyStore
+
+// InitIdempotency to init idempotency store with retention in seconds
+func InitIdempotency(retention int) {
+	GIdempotencyStore = NewIdempotencyStore(retention)
+}
+
+// NewIdempotencyStore to new a store keeping responses for retention seconds
+func NewIdempotencyStore(retention int) *IdempotencyStore {
+
+	if retention <= 0 {
+		retention = DefaultIdempotencyRetention
+	}
+
+	return &IdempotencyStore{
+		retention: time.Duration(retention) * time.Second,
+		entries:   make(map[string]*idempotentEntry),
+	}
+}
+
+// Run fn once for key, a retry with the same key and hash gets the stored
+// response, waiting for it if still running. Returns true if replayed.
+func (s *IdempotencyStore) Run(key string, hash string, fn func() *Response) (*Response, bool) {
+
+	now := time.Now()
+
+	s.lock.Lock()
+
+	for k, entry := range s.entries {
+		if entry.resp != nil && entry.expire.Before(now) {
+			delete(s.entries, k)
+		}
+	}
+
+	entry, ok := s.entries[key]
+	if ok {
+		s.lock.Unlock()
+
+		if entry.hash != hash {
+			return &Response{
+				Error:    merrors.ErrRequestConflict,
+				ErrorLog: "requestId already used with different paras",
+			}, false
+		}
+
+		<-entry.done
+
+		if entry.resp == nil {
+			return s.Run(key, hash, fn)
+		}
+
+		resp := *entry.resp
+		resp.replayed = true
+
+		return &resp, true
+	}
+
+	entry = &idempotentEntry{
+		hash: hash,
+		done: make(chan struct{}),
+	}
+	s.entries[key] = entry
+
+	s.lock.Unlock()
+
+	defer func() {
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		if entry.resp == nil {
+			// fn panicked, let the request id be retried
+			delete(s.entries, key)
+		} else {
+			entry.expire = time.Now().Add(s.retention)
+		}
+		close(entry.done)
+	}()
+
+	entry.resp = fn()
+
+	return entry.resp, false
+}
+
+// parasHash of api, paras and flags of request
+func parasHash(paras *Paras) string {
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"api":   paras.InParas.API,
+		"paras": paras.InParas.Paras,
+		"async": paras.InParas.Async,
+	})
+
+	sum := sha256.Sum256(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// idempotent run fn for mutating request with requestId at most once, request
+// ids are scoped by caller
+func idempotent(paras *Paras, fn func() *Response) *Response {
+
+	if GIdempotencyStore == nil || paras.InParas.RequestID == "" ||
+		!paras.Proto.Mutating || paras.InParas.DryRun {
+		return fn()
+	}
+
+	key := paras.caller + ":" + paras.InParas.RequestID
+
+	resp, replayed := GIdempotencyStore.Run(key, parasHash(paras), fn)
+	if replayed {
+		logger.Infof("replay response of request %s for %s\n", paras.InParas.RequestID,
+			paras.InParas.API)
+	}
+
+	return resp
+}
+
+// replayedHeader set header for replayed response
+func replayedHeader(c *gin.Context, resp *Response) {
+	if resp.replayed {
+		c.Header(HeaderReplayed, "true")
+	}
+}
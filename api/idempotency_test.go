@@ -0,0 +1,65 @@
+package api
+
+import (
+	"octlink/ovs/utils/merrors"
+	"sync"
+	"testing"
+)
+
+func TestIdempotencyStore(t *testing.T) {
+
+	initTestLog()
+
+	s := NewIdempotencyStore(60)
+
+	calls := 0
+	fn := func() *Response {
+		calls++
+		return &Response{Data: calls}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run("center:req-1", "hash", fn)
+		}()
+	}
+	wg.Wait()
+
+	resp, replayed := s.Run("center:req-1", "hash", fn)
+	if calls != 1 || !replayed || !resp.replayed || resp.Data != 1 {
+		t.Fatalf("request should be executed once and replayed, %d calls, %v got", calls, resp)
+	}
+
+	resp, _ = s.Run("center:req-1", "other", fn)
+	if resp.Error != merrors.ErrRequestConflict || calls != 1 {
+		t.Fatalf("request id reused with different paras should be rejected, %v got", resp)
+	}
+
+	resp, replayed = s.Run("center:req-2", "hash", fn)
+	if calls != 2 || replayed {
+		t.Fatalf("new request id should be executed, %v got", resp)
+	}
+}
+
+func TestParasHash(t *testing.T) {
+
+	paras := func(ip string) *Paras {
+		return &Paras{
+			InParas: &inputParas{
+				API:   "octlink.virtualrouter.v5.eip.APICreateEip",
+				Paras: map[string]interface{}{"vipIp": ip, "guestIp": "10.0.0.2"},
+			},
+		}
+	}
+
+	if parasHash(paras("192.168.1.10")) != parasHash(paras("192.168.1.10")) {
+		t.Fatalf("hash of same paras should be equal")
+	}
+
+	if parasHash(paras("192.168.1.10")) == parasHash(paras("192.168.1.11")) {
+		t.Fatalf("hash of different paras should differ")
+	}
+}
@@ -0,0 +1,242 @@
+package api
+
+import (
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/uuid"
+	"sort"
+	"sync"
+)
+
+const (
+	// JobStatePending job waiting for a worker
+	JobStatePending = "pending"
+
+	// JobStateRunning job running by a worker
+	JobStateRunning = "running"
+
+	// JobStateFinished job finished successfully
+	JobStateFinished = "finished"
+
+	// JobStateFailed job finished with error
+	JobStateFailed = "failed"
+
+	// JobStateCancelled job cancelled before running
+	JobStateCancelled = "cancelled"
+
+	// DefaultJobWorkers for job workers if not configured
+	DefaultJobWorkers = 4
+
+	// DefaultJobRetention in seconds for finished jobs
+	DefaultJobRetention = 3600
+
+	jobQueueSize = 1024
+)
+
+// Job for async api execution
+type Job struct {
+	ID         string      `json:"id"`
+	API        string      `json:"api"`
+	State      string      `json:"state"`
+	Error      int         `json:"error"`
+	ErrorLog   string      `json:"errorLog"`
+	Data       interface{} `json:"data"`
+	CreateTime int64       `json:"createTime"`
+	StartTime  int64       `json:"startTime"`
+	FinishTime int64       `json:"finishTime"`
+
+	service *Service
+	paras   *Paras
+}
+
+// JobManager for async jobs
+type JobManager struct {
+	lock      sync.Mutex
+	jobs      map[string]*Job
+	queue     chan *Job
+	retention int64
+}
+
+// GJobManager for global async jobs
+var GJobManager *JobManager
+
+// InitJobManager to init job manager and start workers
+func InitJobManager(workers int, retention int) {
+
+	if workers <= 0 {
+		workers = DefaultJobWorkers
+	}
+
+	if retention <= 0 {
+		retention = DefaultJobRetention
+	}
+
+	GJobManager = NewJobManager(workers, retention)
+}
+
+// NewJobManager to new a job manager with workers started
+func NewJobManager(workers int, retention int) *JobManager {
+
+	m := &JobManager{
+		jobs:      make(map[string]*Job, 100),
+		queue:     make(chan *Job, jobQueueSize),
+		retention: int64(retention),
+	}
+
+	for i := 0; i < workers; i++ {
+		go m.worker()
+	}
+
+	return m
+}
+
+func (m *JobManager) worker() {
+	for job := range m.queue {
+		m.run(job)
+	}
+}
+
+func (m *JobManager) run(job *Job) {
+
+	m.lock.Lock()
+	if job.State != JobStatePending {
+		m.lock.Unlock()
+		return
+	}
+	job.State = JobStateRunning
+	job.StartTime = utils.CurrentTime()
+	m.lock.Unlock()
+
+	logger.Debugf("job %s of %s started\n", job.ID, job.API)
+
+	resp := callHandler(job.service, job.paras)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	job.Error = resp.Error
+	job.ErrorLog = resp.ErrorLog
+	job.Data = resp.Data
+	job.FinishTime = utils.CurrentTime()
+	if resp.Error == merrors.ErrSuccess {
+		job.State = JobStateFinished
+	} else {
+		job.State = JobStateFailed
+	}
+
+	logger.Debugf("job %s of %s %s\n", job.ID, job.API, job.State)
+}
+
+// expire finished jobs out of retention, must be called with lock held
+func (m *JobManager) expire() {
+	now := utils.CurrentTime()
+	for id, job := range m.jobs {
+		if job.FinishTime != 0 && now-job.FinishTime > m.retention {
+			delete(m.jobs, id)
+		}
+	}
+}
+
+// Submit api call as a job, return the new job
+func (m *JobManager) Submit(service *Service, paras *Paras) (*Job, int) {
+
+	job := &Job{
+		ID:         uuid.Generate().Simple(),
+		API:        paras.InParas.API,
+		State:      JobStatePending,
+		CreateTime: utils.CurrentTime(),
+		service:    service,
+		paras:      paras,
+	}
+
+	m.lock.Lock()
+	m.expire()
+	m.jobs[job.ID] = job
+	m.lock.Unlock()
+
+	select {
+	case m.queue <- job:
+	default:
+		m.lock.Lock()
+		delete(m.jobs, job.ID)
+		m.lock.Unlock()
+		logger.Errorf("job queue is full, reject job of %s\n", job.API)
+		return nil, merrors.ErrSystemErr
+	}
+
+	return job, merrors.ErrSuccess
+}
+
+// copy of job, safe to be read without lock
+func (job *Job) snapshot() *Job {
+	return &Job{
+		ID:         job.ID,
+		API:        job.API,
+		State:      job.State,
+		Error:      job.Error,
+		ErrorLog:   job.ErrorLog,
+		Data:       job.Data,
+		CreateTime: job.CreateTime,
+		StartTime:  job.StartTime,
+		FinishTime: job.FinishTime,
+	}
+}
+
+// Get job by id
+func (m *JobManager) Get(id string) *Job {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.expire()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil
+	}
+
+	return job.snapshot()
+}
+
+// List jobs by state, all jobs returned if state is empty
+func (m *JobManager) List(state string) []*Job {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.expire()
+
+	jobs := make([]*Job, 0)
+	for _, job := range m.jobs {
+		if state == "" || job.State == state {
+			jobs = append(jobs, job.snapshot())
+		}
+	}
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].CreateTime < jobs[j].CreateTime
+	})
+
+	return jobs
+}
+
+// Cancel job by id, only pending job can be cancelled
+func (m *JobManager) Cancel(id string) (*Job, int, string) {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, merrors.ErrSegmentNotExist, "job " + id + " not exist"
+	}
+
+	if job.State != JobStatePending {
+		return job.snapshot(), merrors.ErrCommonErr, "job " + id + " is " + job.State
+	}
+
+	job.State = JobStateCancelled
+	job.FinishTime = utils.CurrentTime()
+
+	return job.snapshot(), merrors.ErrSuccess, ""
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/merrors"
+	"testing"
+	"time"
+)
+
+func TestJobManager(t *testing.T) {
+
+	initTestLog()
+
+	// no workers, jobs kept pending until run by test
+	m := NewJobManager(0, 60)
+	m.queue = make(chan *Job, 2)
+
+	service := &Service{
+		Handler: func(paras *Paras) *Response {
+			return &Response{Data: paras.InParas.API}
+		},
+	}
+	submit := func(api string) *Job {
+		job, ret := m.Submit(service, &Paras{Proto: &Proto{}, InParas: &inputParas{API: api}})
+		if ret != merrors.ErrSuccess {
+			t.Fatalf("submit %s should succeed, %d got", api, ret)
+		}
+		return job
+	}
+
+	done := submit("test.APIDone")
+	cancelled := submit("test.APICancelled")
+	if _, ret := m.Submit(service, &Paras{Proto: &Proto{}, InParas: &inputParas{API: "test.APIFull"}}); ret == merrors.ErrSuccess {
+		t.Fatalf("submit to full queue should be rejected")
+	}
+	if len(m.List("")) != 2 {
+		t.Fatalf("job rejected should not be kept, %d jobs got", len(m.List("")))
+	}
+
+	m.run(<-m.queue)
+	if job := m.Get(done.ID); job.State != JobStateFinished || job.Data != "test.APIDone" {
+		t.Fatalf("job should be finished with data, %+v got", job)
+	}
+
+	if _, ret, _ := m.Cancel(done.ID); ret == merrors.ErrSuccess {
+		t.Fatalf("finished job should not be cancelled")
+	}
+	if job, ret, msg := m.Cancel(cancelled.ID); ret != merrors.ErrSuccess || job.State != JobStateCancelled {
+		t.Fatalf("pending job should be cancelled, %d %s got", ret, msg)
+	}
+
+	// job cancelled never run by worker
+	m.run(<-m.queue)
+	if job := m.Get(cancelled.ID); job.State != JobStateCancelled || job.StartTime != 0 {
+		t.Fatalf("cancelled job should not run, %+v got", job)
+	}
+
+	if _, ret, _ := m.Cancel("nosuchjob"); ret != merrors.ErrSegmentNotExist {
+		t.Fatalf("cancel of unknown job should be not exist, %d got", ret)
+	}
+
+	// jobs finished out of retention expired
+	m.lock.Lock()
+	m.jobs[done.ID].FinishTime = utils.CurrentTime() - 61
+	m.lock.Unlock()
+
+	if m.Get(done.ID) != nil || len(m.List("")) != 1 {
+		t.Fatalf("job out of retention should expire, %d jobs got", len(m.List("")))
+	}
+}
+
+func TestJobWorkers(t *testing.T) {
+
+	initTestLog()
+
+	m := NewJobManager(2, 60)
+	job, _ := m.Submit(&Service{
+		Handler: func(paras *Paras) *Response {
+			panic("job crashed")
+		},
+	}, &Paras{Proto: &Proto{}, InParas: &inputParas{API: "test.APIPanic"}})
+
+	for i := 0; i < 100; i++ {
+		if got := m.Get(job.ID); got.State == JobStateFailed {
+			if got.Error != merrors.ErrSystemErr {
+				t.Fatalf("panic of job should be system error, %d got", got.Error)
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("job should fail by panic, %+v got", m.Get(job.ID))
+}
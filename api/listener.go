@@ -0,0 +1,239 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"octlink/ovs/utils/configuration"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// NetworkTCP for tcp listener
+	NetworkTCP = "tcp"
+
+	// NetworkUnix for unix domain socket listener
+	NetworkUnix = "unix"
+
+	// DefaultSocketMode of unix socket file
+	DefaultSocketMode = 0600
+)
+
+// certLoader keep certificate and client cas of a tls listener, reloaded
+// without restarting the listener
+type certLoader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	lock      sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// load certificate and client cas from files, kept unchanged on error
+func (l *certLoader) load() error {
+
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCAs *x509.CertPool
+	if l.clientCAFile != "" {
+		data, err := ioutil.ReadFile(l.clientCAFile)
+		if err != nil {
+			return err
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificate found in %s", l.clientCAFile)
+		}
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.cert = &cert
+	l.clientCAs = clientCAs
+
+	return nil
+}
+
+// tlsConfig of current certificate for each handshake
+func (l *certLoader) tlsConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*l.cert},
+	}
+
+	if l.clientCAs != nil {
+		config.ClientCAs = l.clientCAs
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return config, nil
+}
+
+// apiListener one configured listener with its server
+type apiListener struct {
+	conf     *configuration.ListenerConfig
+	listener net.Listener
+	server   *http.Server
+	certs    *certLoader
+}
+
+// listenerName for logging
+func listenerName(conf *configuration.ListenerConfig) string {
+	network := conf.Network
+	if network == "" {
+		network = NetworkTCP
+	}
+	if conf.CertFile != "" {
+		network += "+tls"
+	}
+	return network + "://" + conf.Addr
+}
+
+// newListener listen as conf, handler served by its server
+func newListener(conf *configuration.ListenerConfig, handler http.Handler) (*apiListener, error) {
+
+	l := &apiListener{
+		conf: conf,
+		server: &http.Server{
+			Handler:        handler,
+			MaxHeaderBytes: 1 << 20,
+			ReadTimeout:    time.Duration(conf.ReadTimeout) * time.Second,
+			WriteTimeout:   time.Duration(conf.WriteTimeout) * time.Second,
+			IdleTimeout:    time.Duration(conf.IdleTimeout) * time.Second,
+		},
+	}
+
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		l.certs = &certLoader{
+			certFile:     conf.CertFile,
+			keyFile:      conf.KeyFile,
+			clientCAFile: conf.ClientCAFile,
+		}
+		if err := l.certs.load(); err != nil {
+			return nil, err
+		}
+	} else if conf.ClientCAFile != "" {
+		return nil, fmt.Errorf("clientcafile of %s requires certfile and keyfile", conf.Addr)
+	}
+
+	var err error
+
+	switch conf.Network {
+	case "", NetworkTCP:
+		l.listener, err = net.Listen(NetworkTCP, conf.Addr)
+
+	case NetworkUnix:
+		mode := int64(DefaultSocketMode)
+		if conf.Mode != "" {
+			if mode, err = strconv.ParseInt(conf.Mode, 8, 32); err != nil {
+				return nil, fmt.Errorf("bad mode %s of %s", conf.Mode, conf.Addr)
+			}
+		}
+
+		// stale socket left by previous process
+		os.Remove(conf.Addr)
+
+		if l.listener, err = net.Listen(NetworkUnix, conf.Addr); err == nil {
+			err = os.Chmod(conf.Addr, os.FileMode(mode))
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown network %s of %s", conf.Network, conf.Addr)
+	}
+
+	if err != nil {
+		if l.listener != nil {
+			l.listener.Close()
+		}
+		return nil, err
+	}
+
+	if l.certs != nil {
+		l.listener = tls.NewListener(l.listener, &tls.Config{
+			GetConfigForClient: l.certs.tlsConfig,
+		})
+	}
+
+	return l, nil
+}
+
+// reloadCerts of all tls listeners, old certificates kept on error
+func reloadCerts(listeners []*apiListener) {
+	for _, l := range listeners {
+		if l.certs == nil {
+			continue
+		}
+		if err := l.certs.load(); err != nil {
+			logger.Errorf("reload certificate of %s error %s\n", listenerName(l.conf), err)
+		} else {
+			logger.Infof("certificate of %s reloaded\n", listenerName(l.conf))
+		}
+	}
+}
+
+// ListenAndServe api on all listeners concurrently, plain http on addr if no
+// listeners configured. Certificates are reloaded on SIGHUP. Returns when
+// any listener failed.
+func (api *API) ListenAndServe(addr string, confs []configuration.ListenerConfig) error {
+
+	if len(confs) == 0 {
+		confs = []configuration.ListenerConfig{{Addr: addr}}
+	}
+
+	handler := api.Router()
+
+	listeners := make([]*apiListener, 0, len(confs))
+	for i := range confs {
+		l, err := newListener(&confs[i], handler)
+		if err != nil {
+			for _, l := range listeners {
+				l.listener.Close()
+			}
+			return fmt.Errorf("listen on %s error: %s", listenerName(&confs[i]), err)
+		}
+		listeners = append(listeners, l)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		logger.Warnf("%s serving on %s\n", api.Name, listenerName(l.conf))
+		go func(l *apiListener) {
+			err := l.server.Serve(l.listener)
+			errs <- fmt.Errorf("serve on %s error: %s", listenerName(l.conf), err)
+		}(l)
+	}
+
+	for {
+		select {
+		case <-hup:
+			reloadCerts(listeners)
+		case err := <-errs:
+			for _, l := range listeners {
+				l.server.Close()
+			}
+			return err
+		}
+	}
+}
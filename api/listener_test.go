@@ -0,0 +1,169 @@
+package api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"octlink/ovs/utils/configuration"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert issue a certificate of cn by parent, self signed if parent is nil
+func testCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key error %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("create certificate error %s", err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestListenerMutualTLS(t *testing.T) {
+
+	initTestLog()
+
+	dir, _ := ioutil.TempDir("", "ovs-listener")
+	defer os.RemoveAll(dir)
+
+	ca, caKey, caPem, _ := testCert(t, "ca", nil, nil)
+	_, _, serverPem, serverKeyPem := testCert(t, "server", ca, caKey)
+	_, _, clientPem, clientKeyPem := testCert(t, "center", ca, caKey)
+
+	ioutil.WriteFile(filepath.Join(dir, "ca.crt"), caPem, 0600)
+	ioutil.WriteFile(filepath.Join(dir, "server.crt"), serverPem, 0600)
+	ioutil.WriteFile(filepath.Join(dir, "server.key"), serverKeyPem, 0600)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	})
+
+	l, err := newListener(&configuration.ListenerConfig{
+		Addr:         "127.0.0.1:0",
+		CertFile:     filepath.Join(dir, "server.crt"),
+		KeyFile:      filepath.Join(dir, "server.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+	}, handler)
+	if err != nil {
+		t.Fatalf("new listener error %s", err)
+	}
+	go l.server.Serve(l.listener)
+	defer l.server.Close()
+
+	url := "https://" + l.listener.Addr().String() + "/"
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	if _, err := client.Get(url); err == nil {
+		t.Fatalf("request without client certificate should be rejected")
+	}
+
+	clientCert, _ := tls.X509KeyPair(clientPem, clientKeyPem)
+	client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+	}}}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("request with client certificate error %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "center" {
+		t.Fatalf("client certificate of center should be verified, %s got", body)
+	}
+
+	// bad certificate kept out, old one still served
+	ioutil.WriteFile(filepath.Join(dir, "server.crt"), []byte("bad"), 0600)
+	reloadCerts([]*apiListener{l})
+	if _, err := client.Get(url); err != nil {
+		t.Fatalf("old certificate should be kept on reload error, %s", err)
+	}
+}
+
+func TestListenerUnix(t *testing.T) {
+
+	initTestLog()
+
+	dir, _ := ioutil.TempDir("", "ovs-listener")
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "ovs.sock")
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	l, err := newListener(&configuration.ListenerConfig{
+		Network: NetworkUnix,
+		Addr:    socket,
+		Mode:    "0660",
+	}, handler)
+	if err != nil {
+		t.Fatalf("new listener error %s", err)
+	}
+	go l.server.Serve(l.listener)
+	defer l.server.Close()
+
+	info, err := os.Stat(socket)
+	if err != nil || info.Mode().Perm() != 0660 {
+		t.Fatalf("socket should be created with mode 0660, %v %v", info, err)
+	}
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return net.Dial(NetworkUnix, socket)
+		},
+	}}
+
+	resp, err := client.Get("http://ovs/")
+	if err != nil {
+		t.Fatalf("request over unix socket error %s", err)
+	}
+	resp.Body.Close()
+
+	if _, err := newListener(&configuration.ListenerConfig{Network: "udp", Addr: ":0"}, handler); err == nil {
+		t.Fatalf("unknown network should be rejected")
+	}
+}
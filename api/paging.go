@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"octlink/ovs/utils/merrors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	// SortOrderAsc for ascending order
+	SortOrderAsc = "asc"
+
+	// SortOrderDesc for descending order
+	SortOrderDesc = "desc"
+)
+
+// paging params handled by pageList, other params are field filters
+var pagingParas = map[string]bool{
+	"start": true,
+	"limit": true,
+	"sort":  true,
+	"order": true,
+}
+
+// listParas of paging and sorting for list apis, with field filters
+func listParas(filters ...ProtoPara) []ProtoPara {
+
+	paras := []ProtoPara{
+		{
+			Name:    "start",
+			Type:    ParamTypeInt,
+			Desc:    "开始位置",
+			Default: 0,
+		},
+		{
+			Name:    "limit",
+			Type:    ParamTypeInt,
+			Desc:    "获取条目, 0 for all",
+			Default: 0,
+		},
+		{
+			Name:    "sort",
+			Type:    ParamTypeString,
+			Desc:    "Field to sort by, empty for no sorting",
+			Default: "",
+		},
+		{
+			Name:    "order",
+			Type:    ParamTypeEnum,
+			Desc:    "Sort order",
+			Default: SortOrderAsc,
+			Values:  []string{SortOrderAsc, SortOrderDesc},
+		},
+	}
+
+	return append(paras, filters...)
+}
+
+// filterPara of list api, items matched if value of the field equals
+func filterPara(name string, typ string, desc string) ProtoPara {
+	return ProtoPara{
+		Name:    name,
+		Type:    typ,
+		Desc:    desc,
+		Default: "",
+	}
+}
+
+// listMatcher for filters can't be done by field equality
+type listMatcher func(item reflect.Value, value string) bool
+
+// jsonField of struct item by json name
+func jsonField(item reflect.Value, name string) (reflect.Value, bool) {
+
+	for item.Kind() == reflect.Ptr || item.Kind() == reflect.Interface {
+		if item.IsNil() {
+			return reflect.Value{}, false
+		}
+		item = item.Elem()
+	}
+
+	if item.Kind() != reflect.Struct {
+		return reflect.Value{}, false
+	}
+
+	t := item.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == name {
+			return item.Field(i), true
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// lessValue compare numbers by value, others by string
+func lessValue(a reflect.Value, b reflect.Value) bool {
+
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return a.Int() < b.Int()
+	case reflect.Float32, reflect.Float64:
+		return a.Float() < b.Float()
+	}
+
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// pageList filter, sort and page list by paging params and field filters of
+// proto, Total and Count of response filled
+func pageList(paras *Paras, list interface{}, matchers map[string]listMatcher) *Response {
+
+	all := reflect.ValueOf(list)
+	if all.Kind() != reflect.Slice {
+		return &Response{
+			Data: list,
+		}
+	}
+
+	items := make([]reflect.Value, 0, all.Len())
+
+	for i := 0; i < all.Len(); i++ {
+		item := all.Index(i)
+		matched := true
+
+		for _, para := range paras.Proto.Paras {
+			value := paras.Get(para.Name)
+			if pagingParas[para.Name] || value == "" {
+				continue
+			}
+
+			if matcher, ok := matchers[para.Name]; ok {
+				matched = matcher(item, value)
+			} else if field, ok := jsonField(item, para.Name); ok {
+				matched = strings.EqualFold(fmt.Sprint(field.Interface()), value)
+			}
+
+			if !matched {
+				break
+			}
+		}
+
+		if matched {
+			items = append(items, item)
+		}
+	}
+
+	if name := paras.Get("sort"); name != "" {
+		if len(items) > 0 {
+			if _, ok := jsonField(items[0], name); !ok {
+				return &Response{
+					Error:    merrors.ErrBadParas,
+					ErrorLog: "paras \"sort\" no field " + name + " to sort by",
+				}
+			}
+		}
+
+		desc := paras.Get("order") == SortOrderDesc
+		sort.SliceStable(items, func(i, j int) bool {
+			a, _ := jsonField(items[i], name)
+			b, _ := jsonField(items[j], name)
+			if !a.IsValid() || !b.IsValid() {
+				return false
+			}
+			if desc {
+				return lessValue(b, a)
+			}
+			return lessValue(a, b)
+		})
+	}
+
+	total := len(items)
+
+	start := paras.GetInt("start")
+	if start < 0 {
+		start = 0
+	}
+	if start > total {
+		start = total
+	}
+
+	end := total
+	if limit := paras.GetInt("limit"); limit > 0 && start+limit < total {
+		end = start + limit
+	}
+
+	page := reflect.MakeSlice(all.Type(), 0, end-start)
+	for _, item := range items[start:end] {
+		page = reflect.Append(page, item)
+	}
+
+	return &Response{
+		Data:  page.Interface(),
+		Total: total,
+		Count: end - start,
+		paged: true,
+	}
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"octlink/ovs/plugins"
+	"octlink/ovs/utils/merrors"
+	"testing"
+)
+
+func TestPageList(t *testing.T) {
+
+	initTestLog()
+
+	dnats := []*plugins.Dnat{
+		{VipIp: "192.168.1.10", VipPortStart: 80, VipPortEnd: 80, ProtocolType: "TCP"},
+		{VipIp: "192.168.1.10", VipPortStart: 8000, VipPortEnd: 8100, ProtocolType: "UDP"},
+		{VipIp: "192.168.1.11", VipPortStart: 22, VipPortEnd: 22, ProtocolType: "TCP"},
+		{VipIp: "192.168.1.10", VipPortStart: 443, VipPortEnd: 443, ProtocolType: "TCP"},
+	}
+
+	paras := &Paras{
+		Proto: FindProto(APIPrefixCenter + ".dnat.APIShowDnats"),
+		InParas: &inputParas{
+			Paras: map[string]interface{}{
+				"vipIp":        "192.168.1.10",
+				"protocolType": "tcp",
+				"sort":         "vipPortStart",
+				"order":        "desc",
+				"limit":        float64(1),
+			},
+		},
+	}
+
+	if ret, msg := checkParas(paras); ret != merrors.ErrSuccess {
+		t.Fatalf("list paras should be accepted, %s", msg)
+	}
+
+	resp := pageList(paras, dnats, nil)
+	page := resp.Data.([]*plugins.Dnat)
+	if resp.Total != 2 || resp.Count != 1 || page[0].VipPortStart != 443 {
+		t.Fatalf("bad page, total %d count %d %+v", resp.Total, resp.Count, page)
+	}
+
+	paras.InParas.Paras = map[string]interface{}{"port": float64(8080)}
+	checkParas(paras)
+
+	resp = pageList(paras, dnats, map[string]listMatcher{"port": matchDnatPort})
+	page = resp.Data.([]*plugins.Dnat)
+	if resp.Total != 1 || page[0].VipPortStart != 8000 {
+		t.Fatalf("port 8080 should match range 8000-8100, %+v", page)
+	}
+
+	paras.InParas.Paras = map[string]interface{}{"sort": "nothing"}
+	checkParas(paras)
+	if resp := pageList(paras, dnats, nil); resp.Error != merrors.ErrBadParas {
+		t.Fatalf("sort by unknown field should be rejected")
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"octlink/ovs/utils/configuration"
+	"octlink/ovs/utils/merrors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// HeaderRetryAfter for seconds to wait before retrying limited request
+	HeaderRetryAfter = "Retry-After"
+
+	// remote limiters idle longer than this are dropped
+	rateLimitIdle = 10 * time.Minute
+
+	// retry hint for requests over concurrency limit
+	mutatingRetryAfter = time.Second
+)
+
+// limiterEntry of remote address with its last use
+type limiterEntry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// RateLimiter for token bucket limits per remote and per module, and
+// concurrency limit of mutating requests
+type RateLimiter struct {
+	remote  configuration.LimitConfig
+	module  configuration.LimitConfig
+	modules map[string]configuration.LimitConfig
+
+	lock      sync.Mutex
+	remotes   map[string]*limiterEntry
+	byModule  map[string]*rate.Limiter
+	lastSweep time.Time
+
+	// semaphore of mutating requests, nil for no limitation
+	mutating chan struct{}
+}
+
+// GRateLimiter for global rate limiting, nil for no limitation
+var GRateLimiter *RateLimiter
+
+// InitRateLimit to init rate limiting by config
+func InitRateLimit(conf *configuration.RateLimitConfig) {
+	GRateLimiter = NewRateLimiter(conf)
+}
+
+// NewRateLimiter to new a rate limiter by config
+func NewRateLimiter(conf *configuration.RateLimitConfig) *RateLimiter {
+
+	l := &RateLimiter{
+		remote:    conf.Remote,
+		module:    conf.Module,
+		modules:   conf.Modules,
+		remotes:   make(map[string]*limiterEntry),
+		byModule:  make(map[string]*rate.Limiter),
+		lastSweep: time.Now(),
+	}
+
+	if conf.MaxMutating > 0 {
+		l.mutating = make(chan struct{}, conf.MaxMutating)
+	}
+
+	return l
+}
+
+// newLimiter of config, nil for no limitation
+func newLimiter(conf configuration.LimitConfig) *rate.Limiter {
+
+	if conf.Rate <= 0 {
+		return nil
+	}
+
+	burst := conf.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(conf.Rate))
+	}
+
+	return rate.NewLimiter(rate.Limit(conf.Rate), burst)
+}
+
+// remoteLimiter of remote, must be called with lock held
+func (l *RateLimiter) remoteLimiter(remote string, now time.Time) *rate.Limiter {
+
+	if l.remote.Rate <= 0 {
+		return nil
+	}
+
+	if now.Sub(l.lastSweep) > rateLimitIdle {
+		for r, entry := range l.remotes {
+			if now.Sub(entry.lastSeen) > rateLimitIdle {
+				delete(l.remotes, r)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	entry, ok := l.remotes[remote]
+	if !ok {
+		entry = &limiterEntry{limiter: newLimiter(l.remote)}
+		l.remotes[remote] = entry
+	}
+	entry.lastSeen = now
+
+	return entry.limiter
+}
+
+// moduleLimiter of module, must be called with lock held
+func (l *RateLimiter) moduleLimiter(module string) *rate.Limiter {
+
+	if limiter, ok := l.byModule[module]; ok {
+		return limiter
+	}
+
+	conf, ok := l.modules[module]
+	if !ok {
+		conf = l.module
+	}
+
+	limiter := newLimiter(conf)
+	l.byModule[module] = limiter
+
+	return limiter
+}
+
+// Allow request of remote to module, or the time to wait before retrying
+func (l *RateLimiter) Allow(remote string, module string) (bool, time.Duration) {
+
+	now := time.Now()
+
+	l.lock.Lock()
+	limiters := []*rate.Limiter{l.remoteLimiter(remote, now), l.moduleLimiter(module)}
+	l.lock.Unlock()
+
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+
+	for _, limiter := range limiters {
+		if limiter == nil {
+			continue
+		}
+
+		r := limiter.ReserveN(now, 1)
+		reservations = append(reservations, r)
+
+		if delay := r.DelayFrom(now); delay > 0 {
+			// tokens taken by passed limiters given back
+			for _, r := range reservations {
+				r.CancelAt(now)
+			}
+			return false, delay
+		}
+	}
+
+	return true, 0
+}
+
+// AcquireMutating take a slot of mutating requests, release must be called
+// when done if acquired
+func (l *RateLimiter) AcquireMutating() (func(), bool) {
+
+	if l.mutating == nil {
+		return func() {}, true
+	}
+
+	select {
+	case l.mutating <- struct{}{}:
+		return func() { <-l.mutating }, true
+	default:
+		return nil, false
+	}
+}
+
+// limitedResponse for request over limit, retry after at least one second
+func limitedResponse(reason string, retryAfter time.Duration) *Response {
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return &Response{
+		Error:      merrors.ErrTooManyRequests,
+		ErrorLog:   fmt.Sprintf("%s, retry after %d seconds", reason, seconds),
+		retryAfter: seconds,
+	}
+}
+
+// limit request of paras, release must be called when done if not limited
+func limit(paras *Paras) (func(), *Response) {
+
+	if GRateLimiter == nil {
+		return func() {}, nil
+	}
+
+	module := apiModule(paras.InParas.API)
+
+	if ok, delay := GRateLimiter.Allow(paras.remote, module); !ok {
+		logger.Warnf("request %s from %s over rate limit\n", paras.InParas.API, paras.remote)
+		return nil, limitedResponse("over rate limit of "+paras.remote+" or module "+module, delay)
+	}
+
+	// async requests are bounded by job workers
+	if !paras.Proto.Mutating || paras.InParas.Async {
+		return func() {}, nil
+	}
+
+	release, ok := GRateLimiter.AcquireMutating()
+	if !ok {
+		logger.Warnf("request %s from %s over mutating concurrency limit\n", paras.InParas.API, paras.remote)
+		return nil, limitedResponse("too many mutating requests in flight", mutatingRetryAfter)
+	}
+
+	return release, nil
+}
+
+// retryHeader set Retry-After header for limited response
+func retryHeader(c *gin.Context, resp *Response) {
+	if resp.retryAfter > 0 {
+		c.Header(HeaderRetryAfter, strconv.Itoa(resp.retryAfter))
+	}
+}
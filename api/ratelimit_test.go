@@ -0,0 +1,64 @@
+package api
+
+import (
+	"net/http"
+	"octlink/ovs/utils/configuration"
+	"octlink/ovs/utils/merrors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+
+	l := NewRateLimiter(&configuration.RateLimitConfig{
+		Remote: configuration.LimitConfig{Rate: 1, Burst: 3},
+		Modules: map[string]configuration.LimitConfig{
+			"dnat": {Rate: 0.1, Burst: 1},
+		},
+		MaxMutating: 1,
+	})
+
+	if ok, _ := l.Allow("10.0.0.1", "dnat"); !ok {
+		t.Fatalf("first request of dnat should be allowed")
+	}
+
+	ok, delay := l.Allow("10.0.0.1", "dnat")
+	if ok || delay <= 0 {
+		t.Fatalf("second request of dnat should be limited with retry delay")
+	}
+
+	// token of remote given back when limited by module
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow("10.0.0.1", "eip"); !ok {
+			t.Fatalf("request %d of eip should be allowed", i)
+		}
+	}
+
+	if ok, _ := l.Allow("10.0.0.1", "eip"); ok {
+		t.Fatalf("request over burst of remote should be limited")
+	}
+
+	if ok, _ := l.Allow("10.0.0.2", "eip"); !ok {
+		t.Fatalf("request of another remote should be allowed")
+	}
+
+	release, ok := l.AcquireMutating()
+	if !ok {
+		t.Fatalf("first mutating request should be allowed")
+	}
+
+	if _, ok := l.AcquireMutating(); ok {
+		t.Fatalf("mutating request over concurrency limit should be rejected")
+	}
+
+	release()
+
+	if _, ok := l.AcquireMutating(); !ok {
+		t.Fatalf("mutating request should be allowed after released")
+	}
+
+	resp := limitedResponse("limited", 1500*time.Millisecond)
+	if resp.retryAfter != 2 || merrors.HTTPStatus(resp.Error) != http.StatusTooManyRequests {
+		t.Fatalf("limited response should retry after 2 seconds with status 429, %v got", resp)
+	}
+}
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/httpresponse"
+	"octlink/ovs/utils/merrors"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// panicToResponse convert a recovered panic to error response,
+// shell failures carry the failing command and its stderr.
+func panicToResponse(api string, r interface{}) *Response {
+
+	logger.Errorf("panic when calling api %s: %v\n%s\n", api, r, debug.Stack())
+
+	// errors wrapped, like by a failed rollback, are matched by their cause
+	var merr *merrors.MError
+	var bashErr *utils.BashError
+	err, _ := r.(error)
+
+	switch {
+	case errors.As(err, &merr):
+		return newResponse(nil, merr)
+
+	case errors.As(err, &bashErr):
+		if bashErr.Err != nil {
+			return newResponse(nil, merrors.Wrap(bashErr.Err, merrors.ErrCmdErr, "command[%s] error", bashErr.Command))
+		}
+		return newResponse(nil, merrors.Errorf(merrors.ErrCmdErr, "command[%s] return code %d, stderr: %s",
+			bashErr.Command, bashErr.RetCode, strings.TrimSpace(bashErr.Stderr)))
+
+	case err != nil:
+		return newResponse(nil, merrors.Wrap(err, merrors.ErrSystemErr, ""))
+
+	default:
+		return newResponse(nil, merrors.Errorf(merrors.ErrSystemErr, "%v", r))
+	}
+}
+
+// callHandler run service handler, and recover from plugin panics
+func callHandler(service *Service, paras *Paras) (resp *Response) {
+
+	start := time.Now()
+	defer func() {
+		audit(paras, resp, start)
+		notify(paras, resp)
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			resp = panicToResponse(paras.InParas.API, r)
+		}
+	}()
+
+	resp = service.Handler(paras)
+	if resp == nil {
+		resp = &Response{}
+	}
+
+	if paras.InParas.DryRun && paras.Proto.Mutating && paras.batch == nil {
+		resp = dryRunResponse(paras, resp)
+	}
+
+	return resp
+}
+
+// Recovery middleware for panics outside of service handlers
+func Recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			if r := recover(); r != nil {
+				resp := panicToResponse(c.Request.URL.Path, r)
+				httpresponse.Error(c, resp.Error, resp.ErrorLog)
+				c.Abort()
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,105 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/configuration"
+	"octlink/ovs/utils/merrors"
+	"octlink/ovs/utils/vyos"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func initTestLog() {
+	configuration.Conf.LogDirectory = os.TempDir()
+	InitLog(0)
+}
+
+func TestCallHandlerRecovery(t *testing.T) {
+
+	initTestLog()
+
+	cases := []struct {
+		panic interface{}
+		code  int
+		log   string
+	}{
+		{&utils.BashError{Command: "ip link", RetCode: 2, Stderr: "no device\n"},
+			merrors.ErrCmdErr, "command[ip link] return code 2, stderr: no device"},
+		{fmt.Errorf("%w, and rollback error broken", &utils.BashError{Command: "commit", RetCode: 1, Stderr: "fail to commit"}),
+			merrors.ErrCmdErr, "command[commit] return code 1, stderr: fail to commit"},
+		{merrors.Errorf(merrors.ErrSegmentNotExist, "no nic"), merrors.ErrSegmentNotExist, "no nic"},
+		{errors.New("broken"), merrors.ErrSystemErr, "broken"},
+		{"out of range", merrors.ErrSystemErr, "out of range"},
+	}
+
+	for _, c := range cases {
+		service := &Service{
+			Handler: func(paras *Paras) *Response {
+				panic(c.panic)
+			},
+		}
+		paras := &Paras{
+			Proto:   &Proto{},
+			InParas: &inputParas{API: "test.APIPanic"},
+		}
+
+		resp := callHandler(service, paras)
+		if resp.Error != c.code || !strings.Contains(resp.ErrorLog, c.log) {
+			t.Fatalf("panic %v should be %d %s, %d %s got", c.panic, c.code, c.log, resp.Error, resp.ErrorLog)
+		}
+	}
+}
+
+func TestShellCommitFailure(t *testing.T) {
+
+	initTestLog()
+	vyos.InitLog(0)
+
+	backend := vyos.GBackend
+	vyos.GBackend = vyos.NewShellBackend()
+	defer func() { vyos.GBackend = backend }()
+
+	// no vyos cli here, the commit of the script fails
+	service := &Service{
+		Handler: func(paras *Paras) *Response {
+			tree := &vyos.ConfigTree{}
+			tree.Set("service ssh port 2222")
+			tree.Apply(false)
+			return nil
+		},
+	}
+	paras := &Paras{
+		Proto:   &Proto{},
+		InParas: &inputParas{API: "test.APICommit"},
+	}
+
+	resp := callHandler(service, paras)
+	if resp.Error != merrors.ErrCmdErr || !strings.Contains(resp.ErrorLog, "return code") {
+		t.Fatalf("failed shell commit should be command error, %d %s got", resp.Error, resp.ErrorLog)
+	}
+}
+
+func TestRecoveryMiddleware(t *testing.T) {
+
+	initTestLog()
+
+	router := gin.New()
+	router.Use(Recovery())
+	router.GET("/panic", func(c *gin.Context) {
+		panic("handler crashed")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	code := fmt.Sprintf(`"errorNo":%d`, merrors.ErrSystemErr)
+	if !strings.Contains(w.Body.String(), code) || !strings.Contains(w.Body.String(), "handler crashed") {
+		t.Fatalf("panic should be an error response, %d %s got", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"octlink/ovs/utils/httpresponse"
+	"octlink/ovs/utils/merrors"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// RESTPrefix for restful routes
+	RESTPrefix = "/v1"
+
+	restParaAsync  = "async"
+	restParaDryRun = "dryRun"
+
+	restParaRequestID      = "requestId"
+	restParaConfirmTimeout = "confirmTimeout"
+)
+
+// restProtos of current version with restful route, ordered by path
+func restProtos() []*Proto {
+
+	protos := make([]*Proto, 0)
+	for _, proto := range GProtos {
+		if proto.Version == APIVersionCurrent && proto.Path != "" {
+			protos = append(protos, proto)
+		}
+	}
+
+	sort.Slice(protos, func(i, j int) bool {
+		if protos[i].Path != protos[j].Path {
+			return protos[i].Path < protos[j].Path
+		}
+		return protos[i].Method < protos[j].Method
+	})
+
+	return protos
+}
+
+// restRoutes add restful routes of protos to router
+func (api *API) restRoutes(router *gin.Engine) {
+
+	group := router.Group(RESTPrefix, AuthRequired())
+
+	for _, proto := range restProtos() {
+		group.Handle(proto.Method, proto.Path, api.restHandler(proto.Key))
+	}
+}
+
+// restInParas collect paras from json body, query string and path
+func restInParas(c *gin.Context) (map[string]interface{}, int, string) {
+
+	values := make(map[string]interface{})
+
+	if c.Request.Body != nil {
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			return nil, merrors.ErrBadParas, "read request body error " + err.Error()
+		}
+		if len(body) > 0 {
+			if err := json.Unmarshal(body, &values); err != nil {
+				return nil, merrors.ErrBadParas, "request body must be a json object, " + err.Error()
+			}
+		}
+	}
+
+	for name, list := range c.Request.URL.Query() {
+		if len(list) == 1 {
+			values[name] = list[0]
+		} else {
+			items := make([]interface{}, len(list))
+			for i, item := range list {
+				items[i] = item
+			}
+			values[name] = items
+		}
+	}
+
+	for _, param := range c.Params {
+		values[param.Key] = param.Value
+	}
+
+	return values, merrors.ErrSuccess, ""
+}
+
+// restFlag take boolean flag out of paras
+func restFlag(values map[string]interface{}, name string) (bool, error) {
+
+	value, ok := values[name]
+	if !ok {
+		return false, nil
+	}
+
+	delete(values, name)
+
+	return coerceBoolean(value)
+}
+
+// restHandler serve api of key by restful route
+func (api *API) restHandler(key string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+
+		values, ret, msg := restInParas(c)
+		if ret != merrors.ErrSuccess {
+			restReply(c, &Response{Error: ret, ErrorLog: msg})
+			return
+		}
+
+		async, err := restFlag(values, restParaAsync)
+		if err != nil {
+			restReply(c, &Response{Error: merrors.ErrBadParas, ErrorLog: "paras \"async\" " + err.Error()})
+			return
+		}
+
+		dryRun, err := restFlag(values, restParaDryRun)
+		if err != nil {
+			restReply(c, &Response{Error: merrors.ErrBadParas, ErrorLog: "paras \"dryRun\" " + err.Error()})
+			return
+		}
+
+		requestID := c.Request.Header.Get(HeaderIdempotencyKey)
+		if value, ok := values[restParaRequestID]; ok {
+			delete(values, restParaRequestID)
+			if requestID == "" {
+				requestID = fmt.Sprint(value)
+			}
+		}
+
+		var confirmTimeout int
+		if value, ok := values[restParaConfirmTimeout]; ok {
+			delete(values, restParaConfirmTimeout)
+			if confirmTimeout, err = coerceInt(value); err != nil || confirmTimeout < 0 {
+				restReply(c, &Response{Error: merrors.ErrBadParas, ErrorLog: "paras \"confirmTimeout\" must be seconds"})
+				return
+			}
+		}
+
+		proto := FindProto(key)
+		proto.adaptParas(values)
+
+		paras := &Paras{
+			Proto: proto,
+			InParas: &inputParas{
+				Module:    key,
+				API:       key,
+				Paras:     values,
+				Async:     async,
+				DryRun:    dryRun,
+				RequestID: requestID,
+
+				ConfirmTimeout: confirmTimeout,
+			},
+		}
+
+		restReply(c, serve(c, paras))
+	}
+}
+
+// restReply reply response with http status of error
+func restReply(c *gin.Context, resp *Response) {
+
+	retryHeader(c, resp)
+	replayedHeader(c, resp)
+
+	status := merrors.HTTPStatus(resp.Error)
+	if resp.Error == merrors.ErrSuccess {
+		if resp.submitted {
+			status = http.StatusAccepted
+		} else if c.Request.Method == http.MethodPost {
+			status = http.StatusCreated
+		}
+	}
+
+	var errlog interface{}
+	if resp.Error != merrors.ErrSuccess {
+		errlog = resp.errorLog()
+	}
+
+	obj := httpresponse.BuildErrorObj(c, resp.Error, errlog, resp.Data)
+	if resp.paged {
+		obj["total"] = resp.Total
+		obj["count"] = resp.Count
+	}
+
+	c.JSON(status, obj)
+}
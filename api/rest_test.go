@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"octlink/ovs/utils/merrors"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRESTRoutes(t *testing.T) {
+
+	initTestLog()
+
+	router := gin.New()
+	api := &API{}
+	api.restRoutes(router)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		status int
+	}{
+		{http.MethodGet, "/v1/jobs?limit=10", "", http.StatusOK},
+		{http.MethodGet, "/v1/jobs?limit=abc", "", http.StatusBadRequest},
+		{http.MethodDelete, "/v1/jobs/abc", "", http.StatusNotImplemented},
+		{http.MethodPost, "/v1/vips", `{"ip":"192.168.1"}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/vips", `[1,2]`, http.StatusBadRequest},
+		{http.MethodDelete, "/v1/eips/192.168.1.10", "", http.StatusBadRequest},
+	}
+
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		router.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Fatalf("%s %s should be %d, %d got, %s", c.method, c.path, c.status, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestRESTPaths(t *testing.T) {
+
+	paths := OpenAPI(merrors.LangEN)["paths"].(Schema)
+
+	item, ok := paths[RESTPrefix+"/eips/{vip}"].(Schema)
+	if !ok {
+		t.Fatalf("path of eip not in openapi document")
+	}
+
+	if _, ok := item["delete"]; !ok {
+		t.Fatalf("delete operation of eip not in openapi document")
+	}
+
+	if _, ok := paths["/api/"]; !ok {
+		t.Fatalf("dispatcher path not in openapi document")
+	}
+}
@@ -12,6 +12,7 @@ func (api *API) Router() *gin.Engine {
 	var baseDir = ""
 
 	router := gin.New()
+	router.Use(Recovery())
 
 	gin.SetMode(gin.ReleaseMode)
 
@@ -22,10 +23,16 @@ func (api *API) Router() *gin.Engine {
 	router.LoadHTMLGlob(baseDir + "frontend/apitest/templates/*.html")
 	router.Static("/static", baseDir+"frontend/static")
 
-	router.GET("/api/test/", api.LoadTestPage)
+	router.GET("/api/test/", AuthRequired(), api.LoadTestPage)
 
-	router.GET("/api/", api.Test)
+	router.GET("/api/", AuthRequired(), api.Test)
+	router.GET("/api/openapi/", AuthRequired(), api.ShowOpenAPI)
+	router.GET("/api/schema/", AuthRequired(), api.ShowSchemas)
+	router.GET("/api/schema/:key", AuthRequired(), api.ShowSchemas)
+	router.GET("/api/events/", AuthRequired(), api.ShowEvents)
 	router.POST("/api/", api.Dispatch)
 
+	api.restRoutes(router)
+
 	return router
 }
@@ -0,0 +1,491 @@
+package api
+
+import (
+	"net/http"
+	"octlink/ovs/utils/httpresponse"
+	"octlink/ovs/utils/merrors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// OpenAPIVersion of generated api document
+	OpenAPIVersion = "3.0.0"
+
+	// SchemaDraft of generated json schema
+	SchemaDraft = "http://json-schema.org/draft-07/schema#"
+)
+
+// Schema of json schema object
+type Schema map[string]interface{}
+
+// patterns of string params have no json schema format
+var paraPatterns = map[string]string{
+	ParamTypeCIDR:      `^(\d{1,3}\.){3}\d{1,3}/\d{1,2}$`,
+	ParamTypeMac:       `^([0-9a-fA-F]{2}[:-]){5}[0-9a-fA-F]{2}$`,
+	ParamTypePortRange: `^\d{1,5}(-\d{1,5})?$`,
+}
+
+// ParaSchema build json schema of a param
+func ParaSchema(para *ProtoPara) Schema {
+
+	var schema Schema
+
+	switch para.Type {
+	case ParamTypeInt:
+		schema = Schema{"type": "integer"}
+
+	case ParamTypeBoolean:
+		schema = Schema{"type": "boolean"}
+
+	case ParamTypeListInt:
+		schema = Schema{"type": "array", "items": Schema{"type": "integer"}}
+
+	case ParamTypeListString:
+		schema = Schema{"type": "array", "items": Schema{"type": "string"}}
+
+	case ParamTypeIPv4, ParamTypeNetmask:
+		schema = Schema{"type": "string", "format": "ipv4"}
+
+	case ParamTypePort:
+		schema = Schema{"type": "integer", "minimum": 1, "maximum": 65535}
+
+	case ParamTypeEnum:
+		schema = Schema{"type": "string", "enum": para.Values}
+
+	case ParamTypeListObject:
+		schema = Schema{"type": "array", "items": objectSchema(para.Fields)}
+
+	case ParamTypeObject:
+		schema = Schema{"type": "object"}
+
+	default:
+		schema = Schema{"type": "string"}
+		if pattern, ok := paraPatterns[para.Type]; ok {
+			schema["pattern"] = pattern
+		}
+	}
+
+	if para.Desc != "" {
+		schema["description"] = para.Desc
+	}
+
+	if para.Default != ParamNotNull {
+		schema["default"] = para.Default
+	}
+
+	schema["x-ovs-type"] = para.Type
+
+	return schema
+}
+
+// objectSchema build json schema of an object with paras as properties
+func objectSchema(paras []ProtoPara) Schema {
+
+	properties := make(map[string]interface{}, len(paras))
+	required := make([]string, 0)
+
+	for i := range paras {
+		para := &paras[i]
+		properties[para.Name] = ParaSchema(para)
+		if para.Default == ParamNotNull {
+			required = append(required, para.Name)
+		}
+	}
+
+	schema := Schema{
+		"type":       "object",
+		"properties": properties,
+	}
+
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+
+	return schema
+}
+
+// typeSchema build json schema of a go type by its json tags
+func typeSchema(t reflect.Type) Schema {
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return Schema{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+
+	case reflect.String:
+		return Schema{"type": "string"}
+
+	case reflect.Slice, reflect.Array:
+		return Schema{"type": "array", "items": typeSchema(t.Elem())}
+
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+
+	case reflect.Struct:
+		properties := make(map[string]interface{}, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" {
+				continue
+			}
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			properties[name] = typeSchema(f.Type)
+		}
+		return Schema{"type": "object", "properties": properties}
+	}
+
+	return Schema{}
+}
+
+// errorNoSchema list all error codes with their messages
+func errorNoSchema() Schema {
+
+	codes := make([]int, 0, len(merrors.GErrors))
+	for code := range merrors.GErrors {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	msgs := make([]string, len(codes))
+	for i, code := range codes {
+		msgs[i] = merrors.GetMsg(code)
+	}
+
+	return Schema{
+		"type":                "integer",
+		"description":         "0 for success",
+		"enum":                codes,
+		"x-enum-descriptions": msgs,
+	}
+}
+
+// requestSchema of api request envelope posted to /api/
+func requestSchema(proto *Proto) Schema {
+
+	properties := map[string]interface{}{
+		"module": Schema{"type": "string", "enum": []string{proto.Key}},
+		"paras":  objectSchema(proto.Paras),
+		"async":  Schema{"type": "boolean", "default": false},
+	}
+
+	if proto.Mutating {
+		properties["dryRun"] = Schema{
+			"type":        "boolean",
+			"default":     false,
+			"description": "return planned vyos commands without applying",
+		}
+		properties["requestId"] = Schema{
+			"type":        "string",
+			"description": "executed at most once, retries get the stored response",
+		}
+		properties["confirmTimeout"] = Schema{
+			"type":        "integer",
+			"minimum":     0,
+			"default":     0,
+			"description": "seconds to revert the changes unless confirmed by APIConfirmCommit",
+		}
+	}
+
+	schema := Schema{
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{"module", "paras"},
+	}
+
+	if proto.Deprecated != "" {
+		schema["deprecated"] = true
+		schema["description"] = proto.Deprecated
+	}
+
+	return schema
+}
+
+// errorObjSchema of errorObj in response envelope
+func errorObjSchema() Schema {
+	return Schema{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"errorNo":  errorNoSchema(),
+			"errorLog": Schema{},
+			"errorMsg": Schema{"type": "string", "description": "in language of Accept-Language, en or zh"},
+			"detail":   Schema{"type": "string"},
+			"cause":    Schema{"type": "string"},
+		},
+	}
+}
+
+// responseSchema of api response envelope
+func responseSchema(proto *Proto, errorObj Schema) Schema {
+
+	data := Schema{}
+	if proto.result != nil {
+		data = typeSchema(reflect.TypeOf(proto.result))
+	}
+
+	properties := map[string]interface{}{
+		"errorObj": errorObj,
+		"apiId":    Schema{"type": "string"},
+		"data":     data,
+	}
+
+	for _, para := range proto.Paras {
+		if para.Name == "limit" {
+			properties["total"] = Schema{"type": "integer", "description": "count of all matched items"}
+			properties["count"] = Schema{"type": "integer", "description": "count of items in data"}
+		}
+	}
+
+	return Schema{
+		"type":       "object",
+		"properties": properties,
+	}
+}
+
+// ProtoSchema build json schema of request and response of a proto, with
+// title in language
+func ProtoSchema(proto *Proto, lang string) Schema {
+
+	schema := requestSchema(proto)
+	schema["$schema"] = SchemaDraft
+	schema["$id"] = "/api/schema/" + proto.Key
+	schema["title"] = proto.LocalName(lang)
+	schema["definitions"] = map[string]interface{}{
+		"response": responseSchema(proto, errorObjSchema()),
+	}
+
+	return schema
+}
+
+// sortedProtos of all versions ordered by key
+func sortedProtos() []*Proto {
+
+	protos := make([]*Proto, 0, len(GProtos))
+	for _, proto := range GProtos {
+		protos = append(protos, proto)
+	}
+
+	sort.Slice(protos, func(i, j int) bool {
+		return protos[i].Key < protos[j].Key
+	})
+
+	return protos
+}
+
+// schemaName of proto in openapi components
+func schemaName(proto *Proto) string {
+	return strings.Replace(strings.TrimPrefix(proto.Key, APIPrefix+"."), ".", "_", -1)
+}
+
+// OpenAPI build openapi document of all apis, all apis share POST /api/ and
+// are distinguished by the module field of request, titles in language
+func OpenAPI(lang string) Schema {
+
+	schemas := make(map[string]interface{}, 100)
+	schemas["ErrorObj"] = errorObjSchema()
+	errorObj := Schema{"$ref": "#/components/schemas/ErrorObj"}
+
+	requests := make([]interface{}, 0)
+	responses := make([]interface{}, 0)
+	mapping := make(map[string]string, 100)
+
+	for _, proto := range sortedProtos() {
+		name := schemaName(proto)
+
+		request := requestSchema(proto)
+		request["title"] = proto.LocalName(lang)
+		schemas[name+"_Request"] = request
+		schemas[name+"_Response"] = responseSchema(proto, errorObj)
+
+		ref := "#/components/schemas/" + name + "_Request"
+		requests = append(requests, Schema{"$ref": ref})
+		responses = append(responses, Schema{"$ref": "#/components/schemas/" + name + "_Response"})
+		mapping[proto.Key] = ref
+	}
+
+	paths := restPaths(lang)
+	paths["/api/"] = Schema{
+		"post": Schema{
+			"operationId": "dispatch",
+			"requestBody": Schema{
+				"required": true,
+				"content": Schema{
+					"application/json": Schema{
+						"schema": Schema{
+							"oneOf": requests,
+							"discriminator": Schema{
+								"propertyName": "module",
+								"mapping":      mapping,
+							},
+						},
+					},
+				},
+			},
+			"responses": Schema{
+				"200": Schema{
+					"description": "errorObj.errorNo is 0 for success",
+					"content": Schema{
+						"application/json": Schema{
+							"schema": Schema{"oneOf": responses},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return Schema{
+		"openapi": OpenAPIVersion,
+		"info": Schema{
+			"title":   "OVS API",
+			"version": APIVersionCurrent,
+		},
+		"paths": paths,
+		"components": Schema{
+			"schemas": schemas,
+		},
+	}
+}
+
+// restPaths of restful routes in openapi document
+func restPaths(lang string) Schema {
+
+	paths := Schema{}
+
+	for _, proto := range restProtos() {
+
+		path := RESTPrefix
+		inPath := make(map[string]bool)
+		parameters := make([]interface{}, 0)
+
+		for _, seg := range strings.Split(strings.Trim(proto.Path, "/"), "/") {
+			if strings.HasPrefix(seg, ":") {
+				seg = seg[1:]
+				inPath[seg] = true
+				parameters = append(parameters, Schema{
+					"name":     seg,
+					"in":       "path",
+					"required": true,
+					"schema":   Schema{"type": "string"},
+				})
+				seg = "{" + seg + "}"
+			}
+			path += "/" + seg
+		}
+
+		paras := make([]ProtoPara, 0, len(proto.Paras))
+		for _, para := range proto.Paras {
+			if !inPath[para.Name] {
+				paras = append(paras, para)
+			}
+		}
+
+		status := "200"
+		if proto.Method == http.MethodPost {
+			status = "201"
+		}
+
+		operation := Schema{
+			"operationId": schemaName(proto),
+			"summary":     proto.LocalName(lang),
+			"responses": Schema{
+				status: Schema{
+					"description": proto.LocalName(lang),
+					"content": Schema{
+						"application/json": Schema{
+							"schema": Schema{"$ref": "#/components/schemas/" + schemaName(proto) + "_Response"},
+						},
+					},
+				},
+				"default": Schema{
+					"description": "error with http status of errorObj.errorNo",
+				},
+			},
+		}
+
+		if proto.Method == http.MethodGet {
+			for i := range paras {
+				parameters = append(parameters, Schema{
+					"name":     paras[i].Name,
+					"in":       "query",
+					"required": paras[i].Default == ParamNotNull,
+					"schema":   ParaSchema(&paras[i]),
+				})
+			}
+		} else if len(paras) > 0 {
+			operation["requestBody"] = Schema{
+				"required": true,
+				"content": Schema{
+					"application/json": Schema{
+						"schema": objectSchema(paras),
+					},
+				},
+			}
+		}
+
+		operation["parameters"] = parameters
+
+		item, ok := paths[path].(Schema)
+		if !ok {
+			item = Schema{}
+			paths[path] = item
+		}
+		item[strings.ToLower(proto.Method)] = operation
+	}
+
+	return paths
+}
+
+// ShowOpenAPI to show openapi document
+func (api *API) ShowOpenAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, OpenAPI(httpresponse.Lang(c)))
+}
+
+// ShowSchemas to show json schema of all protos, or of the proto by key
+func (api *API) ShowSchemas(c *gin.Context) {
+
+	key := c.Param("key")
+	if key == "" {
+		schemas := make(map[string]interface{}, 100)
+		for _, proto := range sortedProtos() {
+			schemas[proto.Key] = ProtoSchema(proto, httpresponse.Lang(c))
+		}
+		c.JSON(http.StatusOK, schemas)
+		return
+	}
+
+	var proto *Proto
+	if GetService(key) != nil {
+		proto = FindProto(key)
+	}
+
+	if proto == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no api " + key})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProtoSchema(proto, httpresponse.Lang(c)))
+}
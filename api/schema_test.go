@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"octlink/ovs/utils/merrors"
+	"testing"
+)
+
+func TestProtoSchema(t *testing.T) {
+
+	proto := FindProto(APIPrefixCenter + ".dnat.APISyncDnats")
+
+	data, err := json.Marshal(ProtoSchema(proto, merrors.LangEN))
+	if err != nil {
+		t.Fatalf("marshal schema error %s", err)
+	}
+
+	var schema struct {
+		Properties struct {
+			Paras struct {
+				Required   []string `json:"required"`
+				Properties map[string]struct {
+					Type  string `json:"type"`
+					Items struct {
+						Required []string `json:"required"`
+					} `json:"items"`
+				} `json:"properties"`
+			} `json:"paras"`
+		} `json:"properties"`
+	}
+	json.Unmarshal(data, &schema)
+
+	paras := schema.Properties.Paras
+	if len(paras.Required) != 1 || paras.Required[0] != "dnats" {
+		t.Fatalf("dnats should be required, %v got", paras.Required)
+	}
+
+	dnats := paras.Properties["dnats"]
+	if dnats.Type != "array" || len(dnats.Items.Required) != 8 {
+		t.Fatalf("bad schema of dnats %s", data)
+	}
+
+	if _, err := json.Marshal(OpenAPI(merrors.LangZH)); err != nil {
+		t.Fatalf("marshal openapi error %s", err)
+	}
+}
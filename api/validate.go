@@ -0,0 +1,402 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"octlink/ovs/utils/merrors"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+func coerceString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	}
+	return "", fmt.Errorf("must be a string")
+}
+
+func coerceInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("must be an integer, %v got", v)
+		}
+		return int(v), nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return 0, fmt.Errorf("must be an integer, \"%s\" got", v)
+		}
+		return i, nil
+	}
+	return 0, fmt.Errorf("must be an integer")
+}
+
+func coerceBoolean(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case bool:
+		return v, nil
+	case float64:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case int:
+		if v == 0 || v == 1 {
+			return v == 1, nil
+		}
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true", "1":
+			return true, nil
+		case "false", "0":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("must be a boolean, true,false,0,1 accepted")
+}
+
+// coerceList accept json array or comma separated string
+func coerceList(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case []string:
+		list := make([]interface{}, len(v))
+		for i, s := range v {
+			list[i] = s
+		}
+		return list, nil
+	case []int:
+		list := make([]interface{}, len(v))
+		for i, n := range v {
+			list[i] = n
+		}
+		return list, nil
+	case string:
+		list := make([]interface{}, 0)
+		if strings.TrimSpace(v) == "" {
+			return list, nil
+		}
+		for _, s := range strings.Split(v, ",") {
+			list = append(list, strings.TrimSpace(s))
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf("must be a list")
+}
+
+func coerceIPv4(value interface{}) (string, error) {
+	s, err := coerceString(value)
+	if err != nil {
+		return "", err
+	}
+	s = strings.TrimSpace(s)
+	if ip := net.ParseIP(s); ip == nil || ip.To4() == nil {
+		return "", fmt.Errorf("must be an ipv4 address, \"%s\" got", s)
+	}
+	return s, nil
+}
+
+func coerceCIDR(value interface{}) (string, error) {
+	s, err := coerceString(value)
+	if err != nil {
+		return "", err
+	}
+	s = strings.TrimSpace(s)
+	ip, _, err := net.ParseCIDR(s)
+	if err != nil || ip.To4() == nil {
+		return "", fmt.Errorf("must be an ipv4 cidr like 10.0.0.0/24, \"%s\" got", s)
+	}
+	return s, nil
+}
+
+func coerceNetmask(value interface{}) (string, error) {
+	s, err := coerceIPv4(value)
+	if err != nil {
+		return "", fmt.Errorf("must be a netmask like 255.255.255.0")
+	}
+	if _, bits := net.IPMask(net.ParseIP(s).To4()).Size(); bits == 0 {
+		return "", fmt.Errorf("must be a netmask like 255.255.255.0, \"%s\" got", s)
+	}
+	return s, nil
+}
+
+func coerceMac(value interface{}) (string, error) {
+	s, err := coerceString(value)
+	if err != nil {
+		return "", err
+	}
+	s = strings.TrimSpace(s)
+	hw, err := net.ParseMAC(s)
+	if err != nil || len(hw) != 6 {
+		return "", fmt.Errorf("must be a mac address like fa:16:3e:00:00:01, \"%s\" got", s)
+	}
+	return hw.String(), nil
+}
+
+func coercePort(value interface{}) (int, error) {
+	port, err := coerceInt(value)
+	if err != nil {
+		return 0, err
+	}
+	if port < 1 || port > 65535 {
+		return 0, fmt.Errorf("must be a port in 1-65535, %d got", port)
+	}
+	return port, nil
+}
+
+func coercePortRange(value interface{}) (string, error) {
+	s, err := coerceString(value)
+	if err != nil {
+		return "", err
+	}
+
+	segs := strings.Split(strings.TrimSpace(s), "-")
+	if len(segs) > 2 {
+		return "", fmt.Errorf("must be a port or port range like 80-90, \"%s\" got", s)
+	}
+
+	start, err := coercePort(segs[0])
+	if err != nil {
+		return "", err
+	}
+	if len(segs) == 1 {
+		return strconv.Itoa(start), nil
+	}
+
+	end, err := coercePort(segs[1])
+	if err != nil {
+		return "", err
+	}
+	if start > end {
+		return "", fmt.Errorf("port range start %d is greater than end %d", start, end)
+	}
+
+	return fmt.Sprintf("%d-%d", start, end), nil
+}
+
+func coerceEnum(para *ProtoPara, value interface{}) (string, error) {
+	s, err := coerceString(value)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range para.Values {
+		if strings.EqualFold(v, strings.TrimSpace(s)) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("must be one of [%s], \"%s\" got", strings.Join(para.Values, ","), s)
+}
+
+// coerceParam convert value to the declared type of para
+func coerceParam(para *ProtoPara, value interface{}) (interface{}, error) {
+
+	switch para.Type {
+	case ParamTypeInt:
+		return coerceInt(value)
+
+	case ParamTypeBoolean:
+		return coerceBoolean(value)
+
+	case ParamTypeListInt:
+		list, err := coerceList(value)
+		if err != nil {
+			return nil, err
+		}
+		ints := make([]int, len(list))
+		for i, v := range list {
+			if ints[i], err = coerceInt(v); err != nil {
+				return nil, fmt.Errorf("[%d] %s", i, err)
+			}
+		}
+		return ints, nil
+
+	case ParamTypeListString:
+		list, err := coerceList(value)
+		if err != nil {
+			return nil, err
+		}
+		strs := make([]string, len(list))
+		for i, v := range list {
+			if strs[i], err = coerceString(v); err != nil {
+				return nil, fmt.Errorf("[%d] %s", i, err)
+			}
+		}
+		return strs, nil
+
+	case ParamTypeIPv4:
+		return coerceIPv4(value)
+
+	case ParamTypeCIDR:
+		return coerceCIDR(value)
+
+	case ParamTypeNetmask:
+		return coerceNetmask(value)
+
+	case ParamTypeMac:
+		return coerceMac(value)
+
+	case ParamTypePort:
+		return coercePort(value)
+
+	case ParamTypePortRange:
+		return coercePortRange(value)
+
+	case ParamTypeEnum:
+		return coerceEnum(para, value)
+
+	case ParamTypeObject:
+		if obj, ok := value.(map[string]interface{}); ok {
+			return obj, nil
+		}
+		return nil, fmt.Errorf("must be an object")
+	}
+
+	return coerceString(value)
+}
+
+// isEmptyParam for null value or blank string
+func isEmptyParam(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	s, ok := value.(string)
+	return ok && strings.TrimSpace(s) == ""
+}
+
+// checkPara coerce value of para in values, the default value is set if not
+// specified, prefix is prepended to the param name in error message.
+func checkPara(para *ProtoPara, values map[string]interface{}, prefix string) (int, string) {
+
+	name := prefix + para.Name
+	value := values[para.Name]
+
+	if isEmptyParam(value) {
+		if para.Default == ParamNotNull {
+			return merrors.ErrNotEnoughParas, "paras \"" + name + "\" must be specified"
+		}
+
+		// if paras have default value and no input sepecified, set a default value
+		values[para.Name] = para.Default
+		return merrors.ErrSuccess, ""
+	}
+
+	if para.Type == ParamTypeListObject {
+		list, ret, msg := coerceObjectList(para, value, name)
+		if ret != merrors.ErrSuccess {
+			return ret, msg
+		}
+		values[para.Name] = list
+		return merrors.ErrSuccess, ""
+	}
+
+	v, err := coerceParam(para, value)
+	if err != nil {
+		return merrors.ErrBadParas, "paras \"" + name + "\" " + err.Error()
+	}
+
+	values[para.Name] = v
+
+	return merrors.ErrSuccess, ""
+}
+
+// coerceObjectList check every object of list against Fields of para,
+// a json string of the list is accepted too.
+func coerceObjectList(para *ProtoPara, value interface{}, name string) ([]interface{}, int, string) {
+
+	if s, ok := value.(string); ok {
+		var decoded interface{}
+		if err := json.Unmarshal([]byte(s), &decoded); err != nil {
+			return nil, merrors.ErrBadParas, "paras \"" + name + "\" must be a json list, " + err.Error()
+		}
+		value = decoded
+	}
+
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, merrors.ErrBadParas, "paras \"" + name + "\" must be a list of objects"
+	}
+
+	for i, item := range list {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, merrors.ErrBadParas, fmt.Sprintf("paras \"%s[%d]\" must be an object", name, i)
+		}
+
+		for j := range para.Fields {
+			ret, msg := checkPara(&para.Fields[j], obj, fmt.Sprintf("%s[%d].", name, i))
+			if ret != merrors.ErrSuccess {
+				return nil, ret, msg
+			}
+		}
+	}
+
+	return list, merrors.ErrSuccess, ""
+}
+
+// objectFields derive fields of object list param from the json, param and
+// values tags of struct, like `json:"vipIp" param:"ipv4"`, fields are
+// required unless tagged with `param:",optional"`.
+func objectFields(obj interface{}) []ProtoPara {
+
+	t := reflect.TypeOf(obj)
+	fields := make([]ProtoPara, 0, t.NumField())
+
+	for i := 0; i < t.NumField(); i++ {
+
+		f := t.Field(i)
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		para := ProtoPara{
+			Name:    name,
+			Default: ParamNotNull,
+		}
+
+		opts := strings.Split(f.Tag.Get("param"), ",")
+		para.Type = opts[0]
+		if para.Type == "" {
+			switch f.Type.Kind() {
+			case reflect.Int, reflect.Int64:
+				para.Type = ParamTypeInt
+			case reflect.Bool:
+				para.Type = ParamTypeBoolean
+			case reflect.Map:
+				para.Type = ParamTypeObject
+			default:
+				para.Type = ParamTypeString
+			}
+		}
+
+		for _, opt := range opts[1:] {
+			if opt == "optional" {
+				para.Default = reflect.Zero(f.Type).Interface()
+			}
+		}
+
+		if values := f.Tag.Get("values"); values != "" {
+			para.Values = strings.Split(values, ",")
+		}
+
+		fields = append(fields, para)
+	}
+
+	return fields
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"octlink/ovs/utils/merrors"
+	"strings"
+	"testing"
+)
+
+func TestCoerceParam(t *testing.T) {
+
+	goods := []struct {
+		para  ProtoPara
+		value interface{}
+		want  interface{}
+	}{
+		{ProtoPara{Type: ParamTypeInt}, float64(22), 22},
+		{ProtoPara{Type: ParamTypeInt}, "80", 80},
+		{ProtoPara{Type: ParamTypeBoolean}, "true", true},
+		{ProtoPara{Type: ParamTypeBoolean}, float64(0), false},
+		{ProtoPara{Type: ParamTypeIPv4}, "10.0.0.1", "10.0.0.1"},
+		{ProtoPara{Type: ParamTypeCIDR}, "10.0.0.0/24", "10.0.0.0/24"},
+		{ProtoPara{Type: ParamTypeNetmask}, "255.255.255.0", "255.255.255.0"},
+		{ProtoPara{Type: ParamTypeMac}, "FA:16:3E:00:00:01", "fa:16:3e:00:00:01"},
+		{ProtoPara{Type: ParamTypePort}, float64(443), 443},
+		{ProtoPara{Type: ParamTypePortRange}, "80-90", "80-90"},
+		{ProtoPara{Type: ParamTypeEnum, Values: []string{"TCP", "UDP"}}, "tcp", "TCP"},
+	}
+
+	for _, c := range goods {
+		v, err := coerceParam(&c.para, c.value)
+		if err != nil {
+			t.Fatalf("%s of %v should be accepted, %s", c.para.Type, c.value, err)
+		}
+		if v != c.want {
+			t.Fatalf("%s of %v should be %v, %v got", c.para.Type, c.value, c.want, v)
+		}
+	}
+
+	bads := []struct {
+		para  ProtoPara
+		value interface{}
+	}{
+		{ProtoPara{Type: ParamTypeInt}, float64(1.5)},
+		{ProtoPara{Type: ParamTypeInt}, "abc"},
+		{ProtoPara{Type: ParamTypeBoolean}, "yes"},
+		{ProtoPara{Type: ParamTypeIPv4}, "10.0.0.256"},
+		{ProtoPara{Type: ParamTypeCIDR}, "10.0.0.1"},
+		{ProtoPara{Type: ParamTypeNetmask}, "255.0.255.0"},
+		{ProtoPara{Type: ParamTypeMac}, "fa:16:3e"},
+		{ProtoPara{Type: ParamTypePort}, float64(70000)},
+		{ProtoPara{Type: ParamTypePortRange}, "90-80"},
+		{ProtoPara{Type: ParamTypeEnum, Values: []string{"TCP", "UDP"}}, "ICMP"},
+	}
+
+	for _, c := range bads {
+		if _, err := coerceParam(&c.para, c.value); err == nil {
+			t.Fatalf("%s of %v should be rejected", c.para.Type, c.value)
+		}
+	}
+
+	list, err := coerceParam(&ProtoPara{Type: ParamTypeListInt}, []interface{}{float64(1), "2"})
+	if err != nil || len(list.([]int)) != 2 {
+		t.Fatalf("listint should be accepted, %v", err)
+	}
+}
+
+func TestCheckParas(t *testing.T) {
+
+	initTestLog()
+
+	proto := FindProto(APIPrefixCenter + ".dnat.APIAddDnat")
+
+	paras := &Paras{
+		Proto: proto,
+		InParas: &inputParas{
+			Paras: map[string]interface{}{
+				"vipPortStart":  float64(8080),
+				"protocolType":  "tcp",
+				"vipIp":         "192.168.1.10",
+				"privateIp":     "10.0.0.10",
+				"privateNicMac": "fa:16:3e:00:00:01",
+			},
+		},
+	}
+
+	if ret, msg := checkParas(paras); ret != merrors.ErrSuccess {
+		t.Fatalf("paras should be accepted, %s", msg)
+	}
+
+	if paras.GetInt("vipPortStart") != 8080 || paras.GetInt("vipPortEnd") != 22 {
+		t.Fatalf("bad ports %v", paras.InParas.Paras)
+	}
+
+	if paras.Get("protocolType") != "TCP" {
+		t.Fatalf("protocol not normalized, %s got", paras.Get("protocolType"))
+	}
+
+	paras.InParas.Paras["vipIp"] = "192.168.1"
+	ret, msg := checkParas(paras)
+	if ret != merrors.ErrBadParas || !strings.Contains(msg, "vipIp") {
+		t.Fatalf("bad vipIp should be rejected with its name, %d %s got", ret, msg)
+	}
+
+	delete(paras.InParas.Paras, "vipIp")
+	ret, msg = checkParas(paras)
+	if ret != merrors.ErrNotEnoughParas || !strings.Contains(msg, "vipIp") {
+		t.Fatalf("missing vipIp should be rejected with its name, %d %s got", ret, msg)
+	}
+}
+
+func TestCheckObjectList(t *testing.T) {
+
+	initTestLog()
+
+	proto := FindProto(APIPrefixCenter + ".vip.APISyncVips")
+
+	vip := map[string]interface{}{
+		"ip":               "192.168.1.10",
+		"netmask":          "255.255.255.0",
+		"ownerEthernetMac": "FA:16:3E:00:00:01",
+	}
+
+	paras := &Paras{
+		Proto: proto,
+		InParas: &inputParas{
+			Paras: map[string]interface{}{
+				"vips": []interface{}{vip},
+			},
+		},
+	}
+
+	if ret, msg := checkParas(paras); ret != merrors.ErrSuccess {
+		t.Fatalf("vips should be accepted, %s", msg)
+	}
+
+	if vip["ownerEthernetMac"] != "fa:16:3e:00:00:01" {
+		t.Fatalf("mac of vips[0] not normalized, %v got", vip["ownerEthernetMac"])
+	}
+
+	paras.InParas.Paras["vips"] = `[{"ip":"192.168.1.10","netmask":"255.255.255.0"}]`
+	ret, msg := checkParas(paras)
+	if ret != merrors.ErrNotEnoughParas || !strings.Contains(msg, "vips[0].ownerEthernetMac") {
+		t.Fatalf("missing field should be rejected with its path, %d %s got", ret, msg)
+	}
+
+	paras.InParas.Paras["vips"] = []interface{}{}
+	if ret, msg := checkParas(paras); ret != merrors.ErrSuccess {
+		t.Fatalf("empty vips should be accepted, %s", msg)
+	}
+}
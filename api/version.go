@@ -0,0 +1,57 @@
+package api
+
+// APIVersion served by this agent
+type APIVersion struct {
+	Name string
+
+	// Deprecated message returned as warning, empty for supported version
+	Deprecated string
+}
+
+// GAPIVersions served by this agent, the current version first. Versions
+// of old center releases are added with their deprecation message, and
+// Renames of protos map their old param names.
+var GAPIVersions = []APIVersion{
+	{
+		Name: APIVersionV5,
+	},
+}
+
+// GProtos of all versions by api key
+var GProtos map[string]*Proto
+
+// serves for proto of module served on version
+func (p *Proto) serves(module *Module, version string) bool {
+
+	versions := p.Versions
+	if len(versions) == 0 {
+		versions = module.Versions
+	}
+
+	if len(versions) == 0 {
+		return true
+	}
+
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+
+	return false
+}
+
+// adaptParas rename params of old api version to current names
+func (p *Proto) adaptParas(paras map[string]interface{}) {
+
+	for old, name := range p.Renames[p.Version] {
+		value, ok := paras[old]
+		if !ok {
+			continue
+		}
+		if _, ok := paras[name]; !ok {
+			paras[name] = value
+		}
+		delete(paras, old)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+)
+
+func TestFindProto(t *testing.T) {
+
+	initTestLog()
+
+	for _, key := range []string{"", "octlink", "a.b.c.d", "a.b.c.d.e.f", APIPrefixCenter + ".dnat"} {
+		if FindProto(key) != nil {
+			t.Fatalf("malformed key %s should not be found", key)
+		}
+	}
+
+	proto := FindProto(APIPrefixCenter + ".vip.APIAddVip")
+	if proto == nil || proto.Deprecated != "" || proto.Version != APIVersionCurrent {
+		t.Fatalf("current vip api should be served")
+	}
+}
+
+func TestDeprecatedVersion(t *testing.T) {
+
+	initTestLog()
+
+	versions := GAPIVersions
+	GAPIVersions = append([]APIVersion{}, versions...)
+	GAPIVersions = append(GAPIVersions, APIVersion{Name: "v4", Deprecated: "v4 is deprecated"})
+	loadDescriptors()
+	defer func() {
+		GAPIVersions = versions
+		loadDescriptors()
+	}()
+
+	proto := FindProto(APIPrefix + ".v4.dnat.APIShowDnats")
+	if proto == nil || proto.Deprecated != "v4 is deprecated" || proto.Version != "v4" {
+		t.Fatalf("v4 dnat api should be served as deprecated")
+	}
+
+	if FindProto(APIPrefix+".v4.batch.APIBatch") != nil {
+		t.Fatalf("batch api should not be served on v4")
+	}
+
+	proto.Renames = map[string]map[string]string{
+		"v4": {"vip": "ip"},
+	}
+
+	paras := map[string]interface{}{"vip": "192.168.1.10"}
+	proto.adaptParas(paras)
+	if paras["ip"] != "192.168.1.10" || paras["vip"] != nil {
+		t.Fatalf("vip should be renamed to ip, %v got", paras)
+	}
+}
@@ -3,7 +3,6 @@ package main
 import (
 	"flag"
 	"fmt"
-	"net/http"
 	"octlink/ovs/api"
 	"octlink/ovs/plugins"
 	"octlink/ovs/utils"
@@ -56,17 +55,11 @@ func runAPIThread() {
 		Name: "OVS API Server",
 	}
 
-	server := &http.Server{
-		Addr:           fmt.Sprintf("%s", conf.HTTP.Addr),
-		Handler:        api.Router(),
-		MaxHeaderBytes: 1 << 20,
-	}
-
-	octlog.Warn("OVS API Engine Started ON %s\n", conf.HTTP.Addr)
+	octlog.Warn("OVS API Engine Started\n")
 
-	err := server.ListenAndServe()
+	err := api.ListenAndServe(conf.HTTP.Addr, conf.HTTP.Listeners)
 	if err != nil {
-		octlog.Error("error to listen at %s\n", conf.HTTP.Addr)
+		octlog.Error("api server stopped, %s\n", err)
 	}
 }
 
@@ -84,5 +77,29 @@ func main() {
 
 	initDebugAndLog()
 
+	if err := vyos.InitBackend(conf.Backend.Type, conf.Backend.Config); err != nil {
+		fmt.Printf("Init Backend Error[%s]\n", err)
+		return
+	}
+
+	api.InitJobManager(conf.Job.Workers, conf.Job.Retention)
+
+	api.InitIdempotency(conf.Idempotency.Retention)
+
+	if err := api.InitAuth(&conf.Auth); err != nil {
+		fmt.Printf("Init Auth Error[%s]\n", err)
+		return
+	}
+
+	api.InitRateLimit(&conf.RateLimit)
+
+	api.InitAudit(conf.Audit.File, conf.Audit.MaxSize, conf.Audit.MaxFiles)
+
+	api.InitEvents(conf.Events.Buffer, conf.Events.NicPoll)
+
+	vyos.InitSnapshots(conf.Snapshots.Dir, conf.Snapshots.MaxAuto, conf.Snapshots.MaxNamed)
+
+	plugins.InitResources(conf.Resources.File)
+
 	runAPIThread()
 }
@@ -18,8 +18,6 @@ const (
 	sshport              = 22
 	VIRTIO_PORT_PATH     = "/dev/virtio-ports/applianceVm.vport"
 	BOOTSTRAP_INFO_CACHE = "/home/vyos/rvm/bootstrap-info.json"
-	// use this rule number to set a rule which confirm route entry work issue ZSTAC-6170
-	ROUTE_STATE_NEW_ENABLE_FIREWALL_RULE_NUMBER = 9999
 )
 
 type nic struct {
@@ -87,13 +85,15 @@ func resetVyos() {
 	// clear all configuration in case someone runs 'save' command manually before,
 	// to keep the vyos must be stateless
 
-	// delete all interfaces
-	tree := vyos.NewParserFromShowConfiguration().Tree
-	tree.Delete("interfaces ethernet")
-	tree.Apply(true)
+	vyos.GCommitManager.Exclusive(func() {
+		// delete all interfaces
+		tree := vyos.NewParserFromShowConfiguration().Tree
+		tree.Delete("interfaces ethernet")
+		tree.Apply(true)
 
-	// reload default configuration
-	vyos.RunVyosScriptAsUserVyos("load /opt/vyatta/etc/config.boot.default\nsave")
+		// reload default configuration
+		vyos.RunVyosScriptAsUserVyos("load /opt/vyatta/etc/config.boot.default\nsave")
+	})
 }
 
 func configureVyos() {
@@ -194,117 +194,121 @@ func configureVyos() {
 		b.PanicIfError()
 	}
 
-	vyos := vyos.NewParserFromShowConfiguration()
-	tree := vyos.Tree
-
-	/*
-		sshkey := bootstrapInfo["publicKey"].(string)
-		utils.Assert(sshkey != "", "cannot find 'publicKey' in bootstrap info")
-		sshkeyparts := strings.Split(sshkey, " ")
-		sshtype := sshkeyparts[0]
-		key := sshkeyparts[1]
-		id := sshkeyparts[2]
-
-		tree.Setf("system login user vyos authentication public-keys %s key %s", id, key)
-		tree.Setf("system login user vyos authentication public-keys %s type %s", id, sshtype)
-	*/
-
-	setNic := func(nic *nic) {
-		cidr := utils.NetmaskToCIDR(nic.netmask)
-		if cidr == -1 {
-			panic(errors.New("netmask to cidr failed."))
+	// commit under the commit lock, in case ovs is committing at the same time
+	_, err := vyos.GCommitManager.Commit(true, func(tree *vyos.ConfigTree) bool {
+		/*
+			sshkey := bootstrapInfo["publicKey"].(string)
+			utils.Assert(sshkey != "", "cannot find 'publicKey' in bootstrap info")
+			sshkeyparts := strings.Split(sshkey, " ")
+			sshtype := sshkeyparts[0]
+			key := sshkeyparts[1]
+			id := sshkeyparts[2]
+
+			tree.Setf("system login user vyos authentication public-keys %s key %s", id, key)
+			tree.Setf("system login user vyos authentication public-keys %s type %s", id, sshtype)
+		*/
+
+		setNic := func(nic *nic) {
+			cidr := utils.NetmaskToCIDR(nic.netmask)
+			if cidr == -1 {
+				panic(errors.New("netmask to cidr failed."))
+			}
+
+			//tree.Setf("interfaces ethernet %s hw-id %s", nic.name, nic.mac)
+			tree.Setf("interfaces ethernet %s address %s", nic.name, fmt.Sprintf("%v/%v", nic.ip, cidr))
+			tree.Setf("interfaces ethernet %s duplex auto", nic.name)
+			tree.Setf("interfaces ethernet %s smp_affinity auto", nic.name)
+			tree.Setf("interfaces ethernet %s speed auto", nic.name)
+			if nic.isDefaultRoute {
+				tree.Setf("system gateway-address %v", nic.gateway)
+			}
 		}
 
-		//tree.Setf("interfaces ethernet %s hw-id %s", nic.name, nic.mac)
-		tree.Setf("interfaces ethernet %s address %s", nic.name, fmt.Sprintf("%v/%v", nic.ip, cidr))
-		tree.Setf("interfaces ethernet %s duplex auto", nic.name)
-		tree.Setf("interfaces ethernet %s smp_affinity auto", nic.name)
-		tree.Setf("interfaces ethernet %s speed auto", nic.name)
-		if nic.isDefaultRoute {
-			tree.Setf("system gateway-address %v", nic.gateway)
-		}
-	}
+		/*
+			sshport := bootstrapInfo["sshPort"].(float64)
+			utils.Assert(sshport != 0, "sshport not found in bootstrap info")
+		*/
 
-	/*
-		sshport := bootstrapInfo["sshPort"].(float64)
-		utils.Assert(sshport != 0, "sshport not found in bootstrap info")
-	*/
+		tree.Setf("service ssh port %v", int(sshport))
+		tree.Setf("service ssh listen-address %v", eth0.ip)
 
-	tree.Setf("service ssh port %v", int(sshport))
-	tree.Setf("service ssh listen-address %v", eth0.ip)
+		// configure firewall
+		for _, nic := range nics {
+			setNic(nic)
 
-	// configure firewall
-	for _, nic := range nics {
-		setNic(nic)
-
-		tree.SetFirewallOnInterface(nic.name, "local",
-			"action accept",
-			"state established enable",
-			"state related enable",
-			fmt.Sprintf("destination address %v", nic.ip),
-		)
-		tree.SetFirewallOnInterface(nic.name, "local",
-			"action accept",
-			"protocol icmp",
-			fmt.Sprintf("destination address %v", nic.ip),
-		)
-
-		tree.SetFirewallOnInterface(nic.name, "in",
-			"action accept",
-			"state established enable",
-			"state related enable",
-		)
-
-		tree.SetFirewallWithRuleNumber(nic.name, "in", ROUTE_STATE_NEW_ENABLE_FIREWALL_RULE_NUMBER,
-			"action accept",
-			"state new enable",
-		)
-
-		tree.SetFirewallOnInterface(nic.name, "in",
-			"action accept",
-			"protocol icmp",
-		)
-
-		// only allow ssh traffic and service on eth0, disable on others
-		if nic.name == "eth0" {
 			tree.SetFirewallOnInterface(nic.name, "local",
-				fmt.Sprintf("destination port %v", int(sshport)),
-				fmt.Sprintf("destination address %v", nic.ip),
-				"protocol tcp",
 				"action accept",
+				"state established enable",
+				"state related enable",
+				fmt.Sprintf("destination address %v", nic.ip),
 			)
-
 			tree.SetFirewallOnInterface(nic.name, "local",
-				fmt.Sprintf("destination port %v", int(serviceport)),
+				"action accept",
+				"protocol icmp",
 				fmt.Sprintf("destination address %v", nic.ip),
-				"protocol tcp",
+			)
+
+			tree.SetFirewallOnInterface(nic.name, "in",
 				"action accept",
+				"state established enable",
+				"state related enable",
 			)
-		} else {
-			tree.SetFirewallOnInterface(nic.name, "local",
-				fmt.Sprintf("destination port %v", int(sshport)),
-				fmt.Sprintf("destination address %v", nic.ip),
-				"protocol tcp",
-				"action reject",
+
+			// the last rule, which confirms route entry work issue ZSTAC-6170
+			tree.SetFirewallRule(nic.name, "in", vyos.RuleRouteState,
+				"action accept",
+				"state new enable",
+			)
+
+			tree.SetFirewallOnInterface(nic.name, "in",
+				"action accept",
+				"protocol icmp",
 			)
-		}
 
-		tree.SetFirewallDefaultAction(nic.name, "local", "reject")
-		tree.SetFirewallDefaultAction(nic.name, "in", "reject")
+			// only allow ssh traffic and service on eth0, disable on others
+			if nic.name == "eth0" {
+				tree.SetFirewallOnInterface(nic.name, "local",
+					fmt.Sprintf("destination port %v", int(sshport)),
+					fmt.Sprintf("destination address %v", nic.ip),
+					"protocol tcp",
+					"action accept",
+				)
+
+				tree.SetFirewallOnInterface(nic.name, "local",
+					fmt.Sprintf("destination port %v", int(serviceport)),
+					fmt.Sprintf("destination address %v", nic.ip),
+					"protocol tcp",
+					"action accept",
+				)
+			} else {
+				tree.SetFirewallOnInterface(nic.name, "local",
+					fmt.Sprintf("destination port %v", int(sshport)),
+					fmt.Sprintf("destination address %v", nic.ip),
+					"protocol tcp",
+					"action reject",
+				)
+			}
+
+			tree.SetFirewallDefaultAction(nic.name, "local", "reject")
+			tree.SetFirewallDefaultAction(nic.name, "in", "reject")
+
+			tree.AttachFirewallToInterface(nic.name, "local")
+			tree.AttachFirewallToInterface(nic.name, "in")
+		}
 
-		tree.AttachFirewallToInterface(nic.name, "local")
-		tree.AttachFirewallToInterface(nic.name, "in")
-	}
+		tree.Set("system time-zone Asia/Shanghai")
 
-	tree.Set("system time-zone Asia/Shanghai")
+		/*
+			password, found := bootstrapInfo["vyosPassword"]
+			utils.Assert(found && password != "", "vyosPassword cannot be empty")
+			tree.Setf("system login user vyos authentication plaintext-password %v", password)
+		*/
 
-	/*
-		password, found := bootstrapInfo["vyosPassword"]
-		utils.Assert(found && password != "", "vyosPassword cannot be empty")
-		tree.Setf("system login user vyos authentication plaintext-password %v", password)
-	*/
+		return true
+	})
 
-	tree.Apply(true)
+	// a failed or rolled back commit fails the stage
+	utils.PanicOnError(err)
 
 	arping := func(nicname, ip, gateway string) {
 		b := utils.Bash{Command: fmt.Sprintf("arping -A -U -c 1 -I %s -s %s %s", nicname, ip, gateway)}
@@ -318,6 +322,27 @@ func configureVyos() {
 	}
 }
 
+// recordStage for ovs to replay as boot events
+func recordStage(stage string, err error) {
+	if e := utils.RecordBootStage(stage, err); e != nil {
+		octlog.Warn("record bootstrap stage %s error %v", stage, e)
+	}
+}
+
+// runStage run fn as bootstrap stage, recorded when done or failed
+func runStage(stage string, fn func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			recordStage(stage, fmt.Errorf("%v", r))
+			panic(r)
+		}
+	}()
+
+	fn()
+
+	recordStage(stage, nil)
+}
+
 func startAgent() {
 	b := utils.Bash{
 		Command: "bash -x /home/vyos/rvm/restart.sh >> /tmp/agentRestart.log 2>&1",
@@ -344,13 +369,19 @@ func main() {
 
 	initDebugAndLog()
 
-	waitIptablesServiceOnline()
+	if err := utils.ResetBootStages(); err != nil {
+		octlog.Warn("reset bootstrap stages error %v", err)
+	}
+
+	runStage("iptables-online", waitIptablesServiceOnline)
 
-	waitVirtioPortOnline()
-	parseKvmBootInfo()
+	runStage("virtio-port-online", waitVirtioPortOnline)
+	runStage("bootstrap-info", parseKvmBootInfo)
 
-	configureVyos()
+	runStage("configure-vyos", configureVyos)
 
+	// recorded before starting, ovs replays stages when started
+	recordStage("start-agent", nil)
 	startAgent()
 	octlog.Debug("successfully configured the sysmtem and bootstrap the octopuslink virtual router agents")
 }
@@ -2,7 +2,6 @@ package plugins
 
 import (
 	"fmt"
-	"octlink/ovs/utils"
 	"octlink/ovs/utils/merrors"
 	"octlink/ovs/utils/vyos"
 	"strings"
@@ -10,22 +9,23 @@ import (
 
 // Dnat for dnat sturcture
 type Dnat struct {
-	VipPortStart     int    `json:"vipPortStart"`
-	VipPortEnd       int    `json:"vipPortEnd"`
-	PrivatePortStart int    `json:"privatePortStart"`
-	PrivatePortEnd   int    `json:"privatePortEnd"`
-	ProtocolType     string `json:"protocolType"`
-	VipIp            string `json:"vipIp"`
-	PrivateIp        string `json:"privateIp"`
-	PrivateNicMac    string `json:"privateNicMac"`
-	AllowedCidr      string `json:"allowedCidr"`
+	VipPortStart     int    `json:"vipPortStart" param:"port"`
+	VipPortEnd       int    `json:"vipPortEnd" param:"port"`
+	PrivatePortStart int    `json:"privatePortStart" param:"port"`
+	PrivatePortEnd   int    `json:"privatePortEnd" param:"port"`
+	ProtocolType     string `json:"protocolType" param:"enum" values:"TCP,UDP"`
+	VipIp            string `json:"vipIp" param:"ipv4"`
+	PrivateIp        string `json:"privateIp" param:"ipv4"`
+	PrivateNicMac    string `json:"privateNicMac" param:"mac"`
+	AllowedCidr      string `json:"allowedCidr" param:"cidr,optional"`
 }
 
 func makeDnatDescription(dnat *Dnat) string {
 	return fmt.Sprintf("%v-%v-%v-%v-%v-%v-%v", dnat.VipIp, dnat.VipPortStart, dnat.VipPortEnd, dnat.PrivateNicMac, dnat.PrivatePortStart, dnat.PrivatePortEnd, dnat.ProtocolType)
 }
 
-func setDnat(tree *vyos.ConfigTree, dnat *Dnat) {
+// setDnat of dnat, nodes owned by dnat returned
+func setDnat(tree *vyos.ConfigTree, dnat *Dnat) ([]string, error) {
 
 	var sport string
 	if dnat.VipPortStart == dnat.VipPortEnd {
@@ -40,12 +40,16 @@ func setDnat(tree *vyos.ConfigTree, dnat *Dnat) {
 		dport = fmt.Sprintf("%v-%v", dnat.PrivatePortStart, dnat.PrivatePortEnd)
 	}
 
-	pubNicName, err := utils.GetNicNameByIP(dnat.VipIp)
-	utils.PanicOnError(err)
+	pubNicName, err := nicNameByIP(dnat.VipIp)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0)
 
 	des := makeDnatDescription(dnat)
 	if r := tree.FindDnatRuleDescription(des); r == nil {
-		tree.SetDnat(
+		n := tree.SetDnat(
 			fmt.Sprintf("description %v", des),
 			fmt.Sprintf("destination address %v", dnat.VipIp),
 			fmt.Sprintf("destination port %v", sport),
@@ -54,11 +58,15 @@ func setDnat(tree *vyos.ConfigTree, dnat *Dnat) {
 			fmt.Sprintf("translation address %v", dnat.PrivateIp),
 			fmt.Sprintf("translation port %v", dport),
 		)
+		nodes = append(nodes, vyos.RulePath(vyos.DnatRules, n))
+	} else {
+		nodes = append(nodes, r.String())
 	}
 
 	if fr := tree.FindFirewallRuleByDescription(pubNicName, "in", des); fr == nil {
+		var n int
 		if dnat.AllowedCidr != "" && dnat.AllowedCidr != "0.0.0.0/0" {
-			tree.SetFirewallOnInterface(pubNicName, "in",
+			n = tree.SetFirewallRule(pubNicName, "in", vyos.RuleDnat,
 				"action reject",
 				fmt.Sprintf("source address !%v", dnat.AllowedCidr),
 				fmt.Sprintf("description %v", des),
@@ -70,7 +78,7 @@ func setDnat(tree *vyos.ConfigTree, dnat *Dnat) {
 				"state new enable",
 			)
 		} else {
-			tree.SetFirewallOnInterface(pubNicName, "in",
+			n = tree.SetFirewallRule(pubNicName, "in", vyos.RuleDnat,
 				"action accept",
 				fmt.Sprintf("description %v", des),
 				fmt.Sprintf("destination address %v", dnat.PrivateIp),
@@ -79,155 +87,131 @@ func setDnat(tree *vyos.ConfigTree, dnat *Dnat) {
 				"state new enable",
 			)
 		}
+		nodes = append(nodes, vyos.RulePath(vyos.FirewallRules(pubNicName, "in"), n))
+	} else {
+		nodes = append(nodes, fr.String())
 	}
 
 	tree.AttachFirewallToInterface(pubNicName, "in")
 
+	return nodes, nil
 }
 
 // AddDnat for add dnat
-func (dnat *Dnat) AddDnat() int {
+func (dnat *Dnat) AddDnat(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
-	setDnat(tree, dnat)
-	tree.Apply(false)
+	nodes, err := setDnat(tree, dnat)
+	if err != nil {
+		return err
+	}
+
+	own(tree, ResourceDnat, makeDnatDescription(dnat), dnat, nodes)
 
-	return 0
+	return nil
 }
 
-func deleteDnat(tree *vyos.ConfigTree, dnat *Dnat) {
+// deleteDnat of dnat, nodes owned by dnat in store deleted, or rules found
+// by description if dnat not in store
+func deleteDnat(tree *vyos.ConfigTree, dnat *Dnat) error {
 
 	des := makeDnatDescription(dnat)
+	if release(tree, ResourceDnat, des) {
+		return nil
+	}
+
 	if r := tree.FindDnatRuleDescription(des); r != nil {
 		r.Delete()
 	}
 
-	pubNicName, err := utils.GetNicNameByIP(dnat.VipIp)
-	utils.PanicOnError(err)
+	pubNicName, err := nicNameByIP(dnat.VipIp)
+	if err != nil {
+		return err
+	}
 
 	if fr := tree.FindFirewallRuleByDescription(pubNicName, "in", des); fr != nil {
 		fr.Delete()
 	}
+
+	return nil
 }
 
 // RemoveDnat for remove dnat
-func (dnat *Dnat) RemoveDnat() int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
-
-	deleteDnat(tree, dnat)
-	tree.Apply(false)
-
-	return 0
+func (dnat *Dnat) RemoveDnat(tree *vyos.ConfigTree) error {
+	return deleteDnat(tree, dnat)
 }
 
 // RemoveDnats to remove eips from VR
-func RemoveDnats(dnats []*Dnat) int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
+func RemoveDnats(tree *vyos.ConfigTree, dnats []*Dnat) error {
 
 	for _, dnat := range dnats {
-		deleteDnat(tree, dnat)
+		if err := deleteDnat(tree, dnat); err != nil {
+			return err
+		}
 	}
 
-	tree.Apply(false)
-
-	return merrors.ErrSuccess
+	return nil
 }
 
-// SyncDnats to sync all dnats
-func SyncDnats(dnats []*Dnat) int {
+// isDnatRule if nat or firewall rule is owned by dnat
+func isDnatRule(rule *vyos.ConfigNode) bool {
+	d := rule.Get("description")
+	return d != nil && (strings.HasSuffix(d.Value(), "TCP") || strings.HasSuffix(d.Value(), "UDP"))
+}
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+// SyncDnats to sync all dnats, only rules changed are touched
+func SyncDnats(tree *vyos.ConfigTree, dnats []*Dnat) error {
 
-	// delete all DNAT related rules
-	if rs := tree.Get("nat destination rule"); rs != nil {
-		for _, r := range rs.Children() {
-			if d := r.Get("description"); d != nil && (strings.HasSuffix(d.Value(), "TCP") || strings.HasSuffix(d.Value(), "UDP")) {
-				r.Delete()
-				logger.Debugf("Dnat related dnat rule has been deleted\n")
-			}
+	desired := &vyos.ConfigTree{}
+	for _, dnat := range dnats {
+		if _, err := setDnat(desired, dnat); err != nil {
+			return err
 		}
 	}
 
-	if rs := tree.Getf("firewall name"); rs != nil {
-		for _, r := range rs.Children() {
-			if rss := r.Get("rule"); rss != nil {
-				for _, rr := range rss.Children() {
-					if d := rr.Get("description"); d != nil && (strings.HasSuffix(d.Value(), "TCP") || strings.HasSuffix(d.Value(), "UDP")) {
-						rr.Delete()
-						logger.Debugf("Dnat related firewall rule has been deleted\n")
-					}
-				}
-			}
-		}
-	}
+	tree.Sync(desired, isDnatRule)
 
+	// rules of dnats all in tree now, found by setDnat as nodes owned
+	owned := make([]*ownedResource, 0, len(dnats))
 	for _, dnat := range dnats {
-		setDnat(tree, dnat)
+		nodes, err := setDnat(tree, dnat)
+		if err != nil {
+			return err
+		}
+		owned = append(owned, &ownedResource{key: makeDnatDescription(dnat), params: dnat, nodes: nodes})
 	}
+	ownAll(tree, ResourceDnat, owned)
 
-	tree.Apply(false)
-
-	return 0
+	return nil
 }
 
-// GetAllDnats get all dnats config
+// GetAllDnats of store configured in running configuration
 func GetAllDnats() []*Dnat {
 
-	var dnats []*Dnat
-	tree := vyos.NewParserFromShowConfiguration().Tree
-
-	if rs := tree.Get("nat destination rule"); rs != nil {
-		for _, r := range rs.Children() {
-			if d := r.Get("description"); d != nil && (strings.HasSuffix(d.Value(), "TCP") || strings.HasSuffix(d.Value(), "UDP")) {
-
-				dnat := new(Dnat)
-
-				descList := strings.Split(d.Value(), "-")
-				if len(descList) != 7 {
-					continue
-				}
-
-				dnat.VipIp = descList[0]
-				dnat.VipPortStart = utils.StringToInt(descList[1])
-				dnat.VipPortEnd = utils.StringToInt(descList[2])
-				dnat.PrivateNicMac = descList[3]
-				dnat.PrivatePortStart = utils.StringToInt(descList[4])
-				dnat.PrivatePortEnd = utils.StringToInt(descList[5])
-				dnat.ProtocolType = descList[6]
+	dnats := make([]*Dnat, 0)
 
-				dnat.PrivateIp = r.Get("translation address").Value()
-
-				pubNicName, err := utils.GetNicNameByIP(dnat.VipIp)
-				utils.PanicOnError(err)
-				if fr := tree.FindFirewallRuleByDescription(pubNicName, "in", d.Value()); fr != nil {
-					if a := fr.Get("action"); a != nil && a.Value() == "reject" {
-						if addr := fr.Get("source address"); addr != nil && strings.HasPrefix(addr.Value(), "!") {
-
-							dnat.AllowedCidr = strings.Trim(addr.Value(), "!")
-						}
-					}
-				}
-
-				dnats = append(dnats, dnat)
-			}
+	tree := vyos.NewParserFromShowConfiguration().Tree
+	for _, r := range resourcesOf(ResourceDnat, tree) {
+		dnat := new(Dnat)
+		if err := r.Decode(dnat); err != nil {
+			logger.Errorf("decode dnat %s error %s\n", r.Key, err)
+			continue
 		}
+		dnats = append(dnats, dnat)
 	}
 
 	return dnats
 }
 
 // GetDnat to get dnat by privateMac
-func GetDnat(privateNicMac string) (*Dnat, int) {
+func GetDnat(privateNicMac string) (*Dnat, error) {
 
 	dnats := GetAllDnats()
 	for _, dnat := range dnats {
 		if dnat.PrivateNicMac == privateNicMac {
-			return dnat, merrors.ErrSuccess
+			return dnat, nil
 		}
 	}
-	return nil, merrors.ErrSegmentNotExist
+	return nil, merrors.Errorf(merrors.ErrSegmentNotExist, "no dnat of private nic mac %s", privateNicMac)
 }
 
 /*
@@ -1,8 +1,7 @@
 package plugins
 
 import (
-	"octlink/ovs/utils"
-	"octlink/ovs/utils/merrors"
+	"fmt"
 	"octlink/ovs/utils/vyos"
 )
 
@@ -12,52 +11,55 @@ type Dns struct {
 	PublicNicMac string `json:"publicNicMac"`
 }
 
-// AddDns to add dns
-func (d *Dns) AddDns() int {
+// AddDns to add dns, other dns servers kept
+func (d *Dns) AddDns(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
-
-	eth, err := utils.GetNicNameByMac(d.PublicNicMac)
+	eth, err := nicNameByMac(d.PublicNicMac)
 	if err != nil {
-		logger.Panicf("get nic name by mac %s error %s\n", d.PublicNicMac, err)
-		return merrors.ErrBadParas
+		return err
 	}
 
-	tree.Setf("service dns forwarding listen-on %s", eth)
+	tree.Addf("service dns forwarding listen-on %s", eth)
 
-	tree.Setf("service dns forwarding name-server %s", d.DnsAddress)
+	tree.Addf("service dns forwarding name-server %s", d.DnsAddress)
 
-	tree.Apply(false)
+	// listen-on shared by dns servers of the same nic
+	own(tree, ResourceDNS, d.DnsAddress, d, []string{
+		fmt.Sprintf("service dns forwarding listen-on %s", eth),
+		fmt.Sprintf("service dns forwarding name-server %s", d.DnsAddress),
+	})
 
-	return 0
+	return nil
 }
 
 // DeleteDns to delete dns
-func (d *Dns) DeleteDns() int {
+func (d *Dns) DeleteDns(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+	if release(tree, ResourceDNS, d.DnsAddress) {
+		return nil
+	}
 
 	tree.Deletef("service dns forwarding name-server %s", d.DnsAddress)
 
-	tree.Apply(false)
-
-	return merrors.ErrSuccess
+	return nil
 }
 
-// ShowDns to show dns
+// ShowDns of store configured in running configuration
 func ShowDns() []*Dns {
-	tree := vyos.NewParserFromShowConfiguration().Tree
 
-	dns := new(Dns)
+	dns := make([]*Dns, 0)
 
-	rs := tree.Get("service dns forwarding name-server")
-	if rs != nil {
-		dns.DnsAddress = rs.Value()
+	tree := vyos.NewParserFromShowConfiguration().Tree
+	for _, r := range resourcesOf(ResourceDNS, tree) {
+		d := new(Dns)
+		if err := r.Decode(d); err != nil {
+			logger.Errorf("decode dns %s error %s\n", r.Key, err)
+			continue
+		}
+		dns = append(dns, d)
 	}
 
-	return []*Dns{
-		dns,
-	}
+	return dns
 }
 
 /*
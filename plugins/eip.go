@@ -2,7 +2,6 @@ package plugins
 
 import (
 	"fmt"
-	"octlink/ovs/utils"
 	"octlink/ovs/utils/merrors"
 	"octlink/ovs/utils/vyos"
 	"strings"
@@ -10,10 +9,10 @@ import (
 
 // EipInfo base structure
 type EipInfo struct {
-	VipIP      string `json:"vip"`
-	PrivateMac string `json:"privateMac"`
-	GuestIP    string `json:"guestIp"`
-	PublicMac  string `json:"publicMac"`
+	VipIP      string `json:"vip" param:"ipv4"`
+	PrivateMac string `json:"privateMac" param:"mac"`
+	GuestIP    string `json:"guestIp" param:"ipv4"`
+	PublicMac  string `json:"publicMac" param:"mac,optional"`
 }
 
 func makeEipDescription(info *EipInfo) string {
@@ -24,47 +23,69 @@ func makeEipDescriptionForPrivateMac(info *EipInfo) string {
 	return fmt.Sprintf("EIP-%v-%v-%v-private", info.VipIP, info.GuestIP, info.PrivateMac)
 }
 
-func setEip(tree *vyos.ConfigTree, eip *EipInfo) {
+// publicNic of eip, by vip or by public mac if vip not on any nic
+func (eip *EipInfo) publicNic() (string, error) {
+	nicname, err := nicNameByIP(eip.VipIP)
+	if err != nil && eip.PublicMac != "" {
+		nicname, err = nicNameByMac(eip.PublicMac)
+	}
+	return nicname, err
+}
+
+// setEip of eip, nodes owned by eip returned
+func setEip(tree *vyos.ConfigTree, eip *EipInfo) ([]string, error) {
 	des := makeEipDescription(eip)
 	priDes := makeEipDescriptionForPrivateMac(eip)
-	nicname, err := utils.GetNicNameByIP(eip.VipIP)
-	if err != nil && eip.PublicMac != "" {
-		nicname, err = utils.GetNicNameByMac(eip.PublicMac)
+	nicname, err := eip.publicNic()
+	if err != nil {
+		return nil, err
 	}
-	utils.PanicOnError(err)
 
-	prinicname, err := utils.GetNicNameByMac(eip.PrivateMac)
-	utils.PanicOnError(err)
+	prinicname, err := nicNameByMac(eip.PrivateMac)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0)
 
 	if r := tree.FindSnatRuleDescription(des); r == nil {
-		tree.SetSnat(
+		n := tree.SetRule(vyos.SnatRules, vyos.RuleEip,
 			fmt.Sprintf("description %v", des),
 			fmt.Sprintf("outbound-interface %v", nicname),
 			fmt.Sprintf("source address %v", eip.GuestIP),
 			fmt.Sprintf("translation address %v", eip.VipIP),
 		)
+		nodes = append(nodes, vyos.RulePath(vyos.SnatRules, n))
+	} else {
+		nodes = append(nodes, r.String())
 	}
 
 	if r := tree.FindSnatRuleDescription(priDes); r == nil {
-		tree.SetSnat(
+		n := tree.SetRule(vyos.SnatRules, vyos.RuleEip,
 			fmt.Sprintf("description %v", priDes),
 			fmt.Sprintf("outbound-interface %v", prinicname),
 			fmt.Sprintf("source address %v", eip.GuestIP),
 			fmt.Sprintf("translation address %v", eip.VipIP),
 		)
+		nodes = append(nodes, vyos.RulePath(vyos.SnatRules, n))
+	} else {
+		nodes = append(nodes, r.String())
 	}
 
 	if r := tree.FindDnatRuleDescription(des); r == nil {
-		tree.SetDnat(
+		n := tree.SetRule(vyos.DnatRules, vyos.RuleEip,
 			fmt.Sprintf("description %v", des),
 			fmt.Sprintf("inbound-interface any"),
 			fmt.Sprintf("destination address %v", eip.VipIP),
 			fmt.Sprintf("translation address %v", eip.GuestIP),
 		)
+		nodes = append(nodes, vyos.RulePath(vyos.DnatRules, n))
+	} else {
+		nodes = append(nodes, r.String())
 	}
 
 	if r := tree.FindFirewallRuleByDescription(nicname, "in", des); r == nil {
-		tree.SetFirewallOnInterface(nicname, "in",
+		n := tree.SetFirewallRule(nicname, "in", vyos.RuleEip,
 			fmt.Sprintf("description %v", des),
 			fmt.Sprintf("destination address %v", eip.GuestIP),
 			"state new enable",
@@ -72,12 +93,15 @@ func setEip(tree *vyos.ConfigTree, eip *EipInfo) {
 			"state related enable",
 			"action accept",
 		)
+		nodes = append(nodes, vyos.RulePath(vyos.FirewallRules(nicname, "in"), n))
 
 		tree.AttachFirewallToInterface(nicname, "in")
+	} else {
+		nodes = append(nodes, r.String())
 	}
 
 	if r := tree.FindFirewallRuleByDescription(prinicname, "in", des); r == nil {
-		tree.SetFirewallOnInterface(prinicname, "in",
+		n := tree.SetFirewallRule(prinicname, "in", vyos.RuleEip,
 			fmt.Sprintf("description %v", des),
 			fmt.Sprintf("source address %v", eip.GuestIP),
 			"state new enable",
@@ -85,19 +109,29 @@ func setEip(tree *vyos.ConfigTree, eip *EipInfo) {
 			"state related enable",
 			"action accept",
 		)
+		nodes = append(nodes, vyos.RulePath(vyos.FirewallRules(prinicname, "in"), n))
 
 		tree.AttachFirewallToInterface(prinicname, "in")
+	} else {
+		nodes = append(nodes, r.String())
 	}
+
+	return nodes, nil
 }
 
-func deleteEip(tree *vyos.ConfigTree, eip *EipInfo) {
+// deleteEip of eip, nodes owned by eip in store deleted, or rules found by
+// description if eip not in store
+func deleteEip(tree *vyos.ConfigTree, eip *EipInfo) error {
 	des := makeEipDescription(eip)
+	if release(tree, ResourceEip, des) {
+		return nil
+	}
+
 	priDes := makeEipDescriptionForPrivateMac(eip)
-	nicname, err := utils.GetNicNameByIP(eip.VipIP)
-	if err != nil && eip.PublicMac != "" {
-		nicname, err = utils.GetNicNameByMac(eip.PublicMac)
+	nicname, err := eip.publicNic()
+	if err != nil {
+		return err
 	}
-	utils.PanicOnError(err)
 
 	if r := tree.FindSnatRuleDescription(des); r != nil {
 		r.Delete()
@@ -115,132 +149,105 @@ func deleteEip(tree *vyos.ConfigTree, eip *EipInfo) {
 		r.Delete()
 	}
 
-	prinicname, err := utils.GetNicNameByMac(eip.PrivateMac)
-	utils.PanicOnError(err)
+	prinicname, err := nicNameByMac(eip.PrivateMac)
+	if err != nil {
+		return err
+	}
 	if r := tree.FindFirewallRuleByDescription(prinicname, "in", des); r != nil {
 		r.Delete()
 	}
+
+	return nil
 }
 
-// CreateEip to remove eip
-func (eip *EipInfo) CreateEip() int {
+// CreateEip to create eip
+func (eip *EipInfo) CreateEip(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
-	setEip(tree, eip)
-	tree.Apply(false)
+	nodes, err := setEip(tree, eip)
+	if err != nil {
+		return err
+	}
+
+	own(tree, ResourceEip, makeEipDescription(eip), eip, nodes)
 
-	return 0
+	return nil
 }
 
 // RemoveEips to remove eips from VR
-func RemoveEips(eips []*EipInfo) int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
+func RemoveEips(tree *vyos.ConfigTree, eips []*EipInfo) error {
 
 	for _, eip := range eips {
-		deleteEip(tree, eip)
+		if err := deleteEip(tree, eip); err != nil {
+			return err
+		}
 	}
 
-	tree.Apply(false)
-
-	return merrors.ErrSuccess
+	return nil
 }
 
 // RemoveEip to remove eips from VR
-func (eip *EipInfo) RemoveEip() int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
-
-	deleteEip(tree, eip)
-
-	tree.Apply(false)
-
-	return merrors.ErrSuccess
+func (eip *EipInfo) RemoveEip(tree *vyos.ConfigTree) error {
+	return deleteEip(tree, eip)
 }
 
-// SyncEips to sync all eips
-func SyncEips(eips []*EipInfo) int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
+// isEipRule if nat or firewall rule is owned by eip
+func isEipRule(rule *vyos.ConfigNode) bool {
+	d := rule.Get("description")
+	return d != nil && strings.HasPrefix(d.Value(), "EIP")
+}
 
-	// delete all EIP related rules
-	if rs := tree.Get("nat destination rule"); rs != nil {
-		for _, r := range rs.Children() {
-			if d := r.Get("description"); d != nil && strings.HasPrefix(d.Value(), "EIP") {
-				r.Delete()
-			}
-		}
-	}
+// SyncEips to sync all eips, only rules changed are touched
+func SyncEips(tree *vyos.ConfigTree, eips []*EipInfo) error {
 
-	if rs := tree.Getf("nat source rule"); rs != nil {
-		for _, r := range rs.Children() {
-			if d := r.Get("description"); d != nil && strings.HasPrefix(d.Value(), "EIP") {
-				r.Delete()
-			}
+	desired := &vyos.ConfigTree{}
+	for _, eip := range eips {
+		if _, err := setEip(desired, eip); err != nil {
+			return err
 		}
 	}
 
-	if rs := tree.Getf("firewall name"); rs != nil {
-		for _, r := range rs.Children() {
-			if rss := r.Get("rule"); rss != nil {
-				for _, rr := range rss.Children() {
-					if d := rr.Get("description"); d != nil && strings.HasPrefix(d.Value(), "EIP") {
-						rr.Delete()
-					}
-				}
-			}
-		}
-	}
+	tree.Sync(desired, isEipRule)
 
+	// rules of eips all in tree now, found by setEip as nodes owned
+	owned := make([]*ownedResource, 0, len(eips))
 	for _, eip := range eips {
-		setEip(tree, eip)
+		nodes, err := setEip(tree, eip)
+		if err != nil {
+			return err
+		}
+		owned = append(owned, &ownedResource{key: makeEipDescription(eip), params: eip, nodes: nodes})
 	}
+	ownAll(tree, ResourceEip, owned)
 
-	tree.Apply(false)
-
-	return 0
+	return nil
 }
 
-// GetAllEips by condition
+// GetAllEips of store configured in running configuration
 func GetAllEips() []*EipInfo {
 
-	var eips []*EipInfo
+	eips := make([]*EipInfo, 0)
 
 	tree := vyos.NewParserFromShowConfiguration().Tree
-
-	if rs := tree.Get("nat destination rule"); rs != nil {
-		for _, r := range rs.Children() {
-			if d := r.Get("description"); d != nil && strings.HasPrefix(d.Value(), "EIP") {
-
-				eip := new(EipInfo)
-
-				desclist := strings.Split(d.Value(), "-")
-				eip.PrivateMac = desclist[len(desclist)-1]
-
-				eip.VipIP = r.Get("destination address").Value()
-				eip.GuestIP = r.Get("translation address").Value()
-
-				publicmac, err := utils.GetNicMacByIP(eip.VipIP)
-				if err == nil {
-					eip.PublicMac = publicmac
-				}
-
-				eips = append(eips, eip)
-			}
+	for _, r := range resourcesOf(ResourceEip, tree) {
+		eip := new(EipInfo)
+		if err := r.Decode(eip); err != nil {
+			logger.Errorf("decode eip %s error %s\n", r.Key, err)
+			continue
 		}
+		eips = append(eips, eip)
 	}
 
 	return eips
 }
 
 // GetEip to get eip by privateMac
-func GetEip(privateMac string) (*EipInfo, int) {
+func GetEip(privateMac string) (*EipInfo, error) {
 
 	eips := GetAllEips()
 	for _, eip := range eips {
 		if eip.PrivateMac == privateMac {
-			return eip, merrors.ErrSuccess
+			return eip, nil
 		}
 	}
-	return nil, merrors.ErrSegmentNotExist
+	return nil, merrors.Errorf(merrors.ErrSegmentNotExist, "no eip of private mac %s", privateMac)
 }
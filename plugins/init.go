@@ -1,15 +1,18 @@
 package plugins
 
-import "octlink/ovs/utils/octlog"
+import (
+	"octlink/ovs/utils/octlog"
+	"octlink/ovs/utils/vyos"
+)
 
 var logger *octlog.LogConfig
 
 const (
-	// EipSnatStartRuleNum for snat rule
-	EipSnatStartRuleNum = 5000
+	// EipSnatStartRuleNum for snat rule, eip rules stay ahead of SnatRuleNumber
+	EipSnatStartRuleNum = vyos.EipSnatFirstRule
 
-	// SnatRuleNumber for max snat rule number
-	SnatRuleNumber = 8888
+	// SnatRuleNumber for the first snat rule number
+	SnatRuleNumber = vyos.SnatRuleNumber
 )
 
 // InitLog to init log config
@@ -0,0 +1,293 @@
+package plugins
+
+import (
+	"fmt"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/vyos"
+	"strings"
+)
+
+// importResources of running configuration set by ovs before resources
+// stored, recovered by rule descriptions and nic addresses into store. Only
+// nodes present in running configuration are recorded, nothing is set.
+func importResources(store *ResourceStore) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("import resources of running configuration error %v\n", r)
+		}
+	}()
+
+	tree := vyos.NewParserFromShowConfiguration().Tree
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	for _, eip := range legacyEips(tree) {
+		if nodes := eipNodes(tree, eip); len(nodes) > 0 {
+			store.put(ResourceEip, &ownedResource{makeEipDescription(eip), eip, nodes, ruleDescriptions(tree, nodes)})
+		}
+	}
+
+	for _, dnat := range legacyDnats(tree) {
+		if nodes := dnatNodes(tree, dnat); len(nodes) > 0 {
+			store.put(ResourceDnat, &ownedResource{makeDnatDescription(dnat), dnat, nodes, ruleDescriptions(tree, nodes)})
+		}
+	}
+
+	if rs := tree.Get(vyos.SnatRules); rs != nil {
+		for _, rule := range rs.Children() {
+			if !isSnatRule(rule) {
+				continue
+			}
+			if sn := legacySnat(rule); sn.PrivateNicMac != "" {
+				nodes := []string{rule.String()}
+				store.put(ResourceSnat, &ownedResource{sn.PrivateNicMac, sn, nodes, ruleDescriptions(tree, nodes)})
+			}
+		}
+	}
+
+	for _, vip := range legacyVips(tree) {
+		store.put(ResourceVip, vip)
+	}
+
+	for _, dns := range legacyDNS(tree) {
+		store.put(ResourceDNS, dns)
+	}
+
+	logger.Infof("%d resources imported of running configuration\n", len(store.resources))
+
+	if err := store.save(); err != nil {
+		logger.Errorf("save resources imported error %s\n", err)
+	}
+}
+
+// presentNodes paths of rules found, those not found left out
+func presentNodes(rules ...*vyos.ConfigNode) []string {
+
+	nodes := make([]string, 0, len(rules))
+	for _, r := range rules {
+		if r != nil {
+			nodes = append(nodes, r.String())
+		}
+	}
+
+	return nodes
+}
+
+// eipNodes of rules of eip present in tree, found by descriptions
+func eipNodes(tree *vyos.ConfigTree, eip *EipInfo) []string {
+
+	des := makeEipDescription(eip)
+	rules := []*vyos.ConfigNode{
+		tree.FindSnatRuleDescription(des),
+		tree.FindSnatRuleDescription(makeEipDescriptionForPrivateMac(eip)),
+		tree.FindDnatRuleDescription(des),
+	}
+
+	if nicname, err := eip.publicNic(); err == nil {
+		rules = append(rules, tree.FindFirewallRuleByDescription(nicname, "in", des))
+	}
+	if nicname, err := nicNameByMac(eip.PrivateMac); err == nil {
+		rules = append(rules, tree.FindFirewallRuleByDescription(nicname, "in", des))
+	}
+
+	return presentNodes(rules...)
+}
+
+// dnatNodes of rules of dnat present in tree, found by description
+func dnatNodes(tree *vyos.ConfigTree, dnat *Dnat) []string {
+
+	des := makeDnatDescription(dnat)
+	rules := []*vyos.ConfigNode{
+		tree.FindDnatRuleDescription(des),
+	}
+
+	if nicname, err := nicNameByIP(dnat.VipIp); err == nil {
+		rules = append(rules, tree.FindFirewallRuleByDescription(nicname, "in", des))
+	}
+
+	return presentNodes(rules...)
+}
+
+// legacyVips of addresses of nics other than their own
+func legacyVips(tree *vyos.ConfigTree) []*ownedResource {
+
+	var vips []*ownedResource
+
+	nics, err := utils.GetAllNics()
+	if err != nil {
+		return vips
+	}
+
+	for _, nic := range nics {
+		addresses := tree.Getf("interfaces ethernet %s address", nic.Name)
+		if addresses == nil {
+			continue
+		}
+
+		own, _, _, _ := utils.GetNicInfo(nic.Name)
+		for _, address := range addresses.Values() {
+			ip, netmask := utils.ParseCIDR(address)
+			if ip == "" || ip == own {
+				continue
+			}
+
+			vips = append(vips, &ownedResource{
+				key: ip,
+				params: &Vip{
+					Ip:               ip,
+					Netmask:          netmask,
+					OwnerEthernetMac: nic.Mac,
+				},
+				nodes: []string{fmt.Sprintf("interfaces ethernet %s address %s", nic.Name, address)},
+			})
+		}
+	}
+
+	return vips
+}
+
+// legacyDNS of name servers, each owning all listen-on nics as the dns
+// plugin, shared by them
+func legacyDNS(tree *vyos.ConfigTree) []*ownedResource {
+
+	var dns []*ownedResource
+
+	ns := tree.Get("service dns forwarding name-server")
+	if ns == nil {
+		return dns
+	}
+
+	var listenOn []string
+	if l := tree.Get("service dns forwarding listen-on"); l != nil {
+		listenOn = l.Values()
+	}
+
+	for _, address := range ns.Values() {
+		d := &Dns{DnsAddress: address}
+		if len(listenOn) == 1 {
+			d.PublicNicMac = utils.GetNicMacByName(listenOn[0])
+		}
+
+		nodes := make([]string, 0, len(listenOn)+1)
+		for _, eth := range listenOn {
+			nodes = append(nodes, fmt.Sprintf("service dns forwarding listen-on %s", eth))
+		}
+		nodes = append(nodes, fmt.Sprintf("service dns forwarding name-server %s", address))
+
+		dns = append(dns, &ownedResource{key: address, params: d, nodes: nodes})
+	}
+
+	return dns
+}
+
+// legacyEips of rules of descriptions like EIP-vip-guestIp-privateMac
+func legacyEips(tree *vyos.ConfigTree) []*EipInfo {
+
+	var eips []*EipInfo
+
+	if rs := tree.Get("nat destination rule"); rs != nil {
+		for _, r := range rs.Children() {
+			if !isEipRule(r) {
+				continue
+			}
+
+			desclist := strings.Split(r.Get("description").Value(), "-")
+			eip := &EipInfo{
+				PrivateMac: desclist[len(desclist)-1],
+				VipIP:      r.Get("destination address").Value(),
+				GuestIP:    r.Get("translation address").Value(),
+			}
+
+			if publicmac, err := utils.GetNicMacByIP(eip.VipIP); err == nil {
+				eip.PublicMac = publicmac
+			}
+
+			eips = append(eips, eip)
+		}
+	}
+
+	return eips
+}
+
+// legacyDnats of rules of descriptions by makeDnatDescription
+func legacyDnats(tree *vyos.ConfigTree) []*Dnat {
+
+	var dnats []*Dnat
+
+	if rs := tree.Get("nat destination rule"); rs != nil {
+		for _, r := range rs.Children() {
+			if !isDnatRule(r) {
+				continue
+			}
+
+			d := r.Get("description")
+			descList := strings.Split(d.Value(), "-")
+			if len(descList) != 7 {
+				continue
+			}
+
+			dnat := &Dnat{
+				VipIp:            descList[0],
+				VipPortStart:     utils.StringToInt(descList[1]),
+				VipPortEnd:       utils.StringToInt(descList[2]),
+				PrivateNicMac:    descList[3],
+				PrivatePortStart: utils.StringToInt(descList[4]),
+				PrivatePortEnd:   utils.StringToInt(descList[5]),
+				ProtocolType:     descList[6],
+				PrivateIp:        r.Get("translation address").Value(),
+			}
+
+			pubNicName, err := utils.GetNicNameByIP(dnat.VipIp)
+			if err != nil {
+				logger.Errorf("get nic of dnat vip %s error %s\n", dnat.VipIp, err)
+			} else if fr := tree.FindFirewallRuleByDescription(pubNicName, "in", d.Value()); fr != nil {
+				if a := fr.Get("action"); a != nil && a.Value() == "reject" {
+					if addr := fr.Get("source address"); addr != nil && strings.HasPrefix(addr.Value(), "!") {
+						dnat.AllowedCidr = strings.Trim(addr.Value(), "!")
+					}
+				}
+			}
+
+			dnats = append(dnats, dnat)
+		}
+	}
+
+	return dnats
+}
+
+// legacySnat of the source nat rule, private nic found by its network
+func legacySnat(rule *vyos.ConfigNode) *Snat {
+
+	sn := new(Snat)
+
+	if n := rule.Get("outbound-interface"); n != nil {
+		sn.PublicNicMac = utils.GetNicMacByName(n.Value())
+	}
+
+	if n := rule.Get("translation address"); n != nil {
+		sn.PublicIP = n.Value()
+	}
+
+	n := rule.Get("source address")
+	if n == nil {
+		return sn
+	}
+
+	nics, err := utils.GetAllNics()
+	if err != nil {
+		return sn
+	}
+
+	for _, nic := range nics {
+		if network, ip, netmask, err := nicNetwork(nic.Mac); err == nil && network == n.Value() {
+			sn.PrivateNicMac = nic.Mac
+			sn.PrivateNicIP = ip
+			sn.SnatNetmask = netmask
+			break
+		}
+	}
+
+	return sn
+}
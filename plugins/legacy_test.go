@@ -0,0 +1,150 @@
+package plugins
+
+import (
+	"octlink/ovs/utils/vyos"
+	"sort"
+	"testing"
+)
+
+const legacyConfig = `
+firewall {
+    name eth0.in {
+        rule 2000 {
+            action accept
+            description 172.20.0.100-80-80-fa:16:3e:00:00:02-8080-8080-TCP
+            destination {
+                address 192.168.0.20
+                port 8080
+            }
+        }
+        rule 5000 {
+            action accept
+            description EIP-172.20.0.100-192.168.0.10-fa:16:3e:00:00:02
+            destination {
+                address 192.168.0.10
+            }
+        }
+    }
+}
+interfaces {
+    ethernet eth0 {
+        address 172.20.0.10/16
+        address 172.20.0.100/16
+    }
+    ethernet eth1 {
+        address 192.168.0.1/16
+    }
+}
+nat {
+    destination {
+        rule 1 {
+            description 172.20.0.100-80-80-fa:16:3e:00:00:02-8080-8080-TCP
+            destination {
+                address 172.20.0.100
+                port 80
+            }
+            translation {
+                address 192.168.0.20
+                port 8080
+            }
+        }
+        rule 5000 {
+            description EIP-172.20.0.100-192.168.0.10-fa:16:3e:00:00:02
+            destination {
+                address 172.20.0.100
+            }
+            translation {
+                address 192.168.0.10
+            }
+        }
+    }
+    source {
+        rule 5000 {
+            description EIP-172.20.0.100-192.168.0.10-fa:16:3e:00:00:02
+            source {
+                address 192.168.0.10
+            }
+            translation {
+                address 172.20.0.100
+            }
+        }
+        rule 8888 {
+            outbound-interface eth0
+            source {
+                address 192.168.0.0/16
+            }
+            translation {
+                address 172.20.0.10
+            }
+        }
+    }
+}
+service {
+    dns {
+        forwarding {
+            listen-on eth1
+            name-server 8.8.8.8
+        }
+    }
+}
+`
+
+func TestImportResources(t *testing.T) {
+
+	file, clean := initTest(t)
+	defer clean()
+
+	backend := vyos.GBackend
+	vyos.GBackend = vyos.NewMemoryBackend(legacyConfig)
+	defer func() { vyos.GBackend = backend }()
+
+	InitResources(file)
+	store := GResources
+
+	running := vyos.NewParserFromConfiguration(legacyConfig).Tree
+
+	kinds := map[string]int{ResourceEip: 1, ResourceDnat: 1, ResourceSnat: 1, ResourceVip: 1, ResourceDNS: 1}
+	for kind, count := range kinds {
+		resources := resourcesOf(kind, running)
+		if len(resources) != count {
+			t.Fatalf("%d %s should be imported and shown, %v got", count, kind, store.List(kind))
+		}
+	}
+
+	// nodes present only, the private snat rule of eip not configured
+	eip := store.List(ResourceEip)[0]
+	sort.Strings(eip.Nodes)
+	want := []string{"firewall name eth0.in rule 5000", "nat destination rule 5000", "nat source rule 5000"}
+	if len(eip.Nodes) != len(want) {
+		t.Fatalf("eip should own rules present, %v got", eip.Nodes)
+	}
+	for i := range want {
+		if eip.Nodes[i] != want[i] {
+			t.Fatalf("eip should own rules present, %v got", eip.Nodes)
+		}
+	}
+
+	sn := new(Snat)
+	store.List(ResourceSnat)[0].Decode(sn)
+	if sn.PrivateNicMac != "fa:16:3e:00:00:02" || sn.PublicNicMac != "fa:16:3e:00:00:01" {
+		t.Fatalf("snat should be imported with its nics, %+v got", sn)
+	}
+
+	vip := new(Vip)
+	store.List(ResourceVip)[0].Decode(vip)
+	if vip.Ip != "172.20.0.100" || vip.Netmask != "255.255.0.0" || vip.OwnerEthernetMac != "fa:16:3e:00:00:01" {
+		t.Fatalf("vip should be imported of nic address, %+v got", vip)
+	}
+
+	dns := store.List(ResourceDNS)[0]
+	if len(dns.Nodes) != 2 || dns.Nodes[0] != "service dns forwarding listen-on eth1" {
+		t.Fatalf("dns should own its listen-on as the dns plugin, %v got", dns.Nodes)
+	}
+
+	// imported once, not again when store file exists
+	vyos.GBackend = vyos.NewMemoryBackend("")
+	InitResources(file)
+	if len(GResources.List("")) != 5 {
+		t.Fatalf("resources should be loaded of file, %v got", GResources.List(""))
+	}
+}
@@ -30,15 +30,54 @@ type IfInfo struct {
 	Mac     string `json:"mac"`
 }
 
+// nicNameByMac of nic with mac, error carries the mac
+func nicNameByMac(mac string) (string, error) {
+	nicname, err := utils.GetNicNameByMac(mac)
+	if err != nil {
+		return "", merrors.Wrap(err, merrors.ErrBadParas, "no nic with mac %s", mac)
+	}
+	return nicname, nil
+}
+
+// nicNameByIP of nic with ip, error carries the ip
+func nicNameByIP(ip string) (string, error) {
+	nicname, err := utils.GetNicNameByIP(ip)
+	if err != nil {
+		return "", merrors.Wrap(err, merrors.ErrBadParas, "no nic with ip %s", ip)
+	}
+	return nicname, nil
+}
+
+// nicNetwork of nic with mac, like 10.0.0.0 for 10.0.0.5/255.255.255.0,
+// with ip and netmask of the nic
+func nicNetwork(mac string) (string, string, string, error) {
+
+	ip, netmask, _, err := utils.GetNicInfoByMac(mac)
+	if err != nil {
+		return "", "", "", merrors.Wrap(err, merrors.ErrBadParas, "no address of nic with mac %s", mac)
+	}
+
+	network, err := utils.GetNetworkNumber(ip, netmask)
+	if err != nil {
+		return "", "", "", merrors.Wrap(err, merrors.ErrBadParas, "bad address %s/%s of nic with mac %s",
+			ip, netmask, mac)
+	}
+
+	return network, ip, netmask, nil
+}
+
 // ConfigureNic by ifinfo
-func (nic *IfInfo) ConfigureNic() int {
+func (nic *IfInfo) ConfigureNic(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+	nicname, err := nicNameByMac(nic.Mac)
+	if err != nil {
+		return err
+	}
 
-	nicname, err := utils.GetNicNameByMac(nic.Mac)
-	utils.PanicOnError(err)
 	cidr := utils.NetmaskToCIDR(nic.Netmask)
-	utils.PanicOnError(err)
+	if cidr == -1 {
+		return merrors.Errorf(merrors.ErrBadParas, "bad netmask %s of nic with mac %s", nic.Netmask, nic.Mac)
+	}
 
 	addr := fmt.Sprintf("%v/%v", nic.IP, cidr)
 	tree.SetfWithoutCheckExisting("interfaces ethernet %s address %v", nicname, addr)
@@ -90,41 +129,42 @@ func (nic *IfInfo) ConfigureNic() int {
 	tree.AttachFirewallToInterface(nicname, "local")
 	tree.AttachFirewallToInterface(nicname, "in")
 
-	tree.Apply(false)
-
-	return 0
+	return nil
 }
 
 // ConfigureNics for nic infos config
-func ConfigureNics(nics []*IfInfo) int {
+func ConfigureNics(tree *vyos.ConfigTree, nics []*IfInfo) error {
 	for _, nic := range nics {
-		nic.ConfigureNic()
+		if err := nic.ConfigureNic(tree); err != nil {
+			return err
+		}
 	}
-	return merrors.ErrSuccess
+	return nil
 }
 
 // RemoveNic by ifinfo
-func (nic *IfInfo) RemoveNic() int {
+func (nic *IfInfo) RemoveNic(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+	nicname, err := nicNameByMac(nic.Mac)
+	if err != nil {
+		return err
+	}
 
-	nicname, err := utils.GetNicNameByMac(nic.Mac)
-	utils.PanicOnError(err)
 	tree.Deletef("interfaces ethernet %s", nicname)
 	tree.Deletef("firewall name %s.in", nicname)
 	tree.Deletef("firewall name %s.local", nicname)
 
-	tree.Apply(false)
-
-	return merrors.ErrSuccess
+	return nil
 }
 
 // RemoveNics for nics removing
-func RemoveNics(nics []*IfInfo) int {
+func RemoveNics(tree *vyos.ConfigTree, nics []*IfInfo) error {
 	for _, nic := range nics {
-		nic.RemoveNic()
+		if err := nic.RemoveNic(tree); err != nil {
+			return err
+		}
 	}
-	return merrors.ErrSuccess
+	return nil
 }
 
 // GetNics by condition
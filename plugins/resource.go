@@ -0,0 +1,390 @@
+package plugins
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/uuid"
+	"octlink/ovs/utils/vyos"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultResourceFile of resources owned by ovs
+const DefaultResourceFile = "/home/vyos/rvm/resources.json"
+
+const (
+	// ResourceEip for eips
+	ResourceEip = "eip"
+
+	// ResourceDnat for port forwardings
+	ResourceDnat = "dnat"
+
+	// ResourceSnat for source nat
+	ResourceSnat = "snat"
+
+	// ResourceVip for vips
+	ResourceVip = "vip"
+
+	// ResourceDNS for dns servers
+	ResourceDNS = "dns"
+)
+
+// Resource set by ovs, with its parameters and the vyos nodes it owns
+type Resource struct {
+	UUID   string          `json:"uuid"`
+	Kind   string          `json:"kind"`
+	Key    string          `json:"key"`
+	Params json.RawMessage `json:"params"`
+	Nodes  []string        `json:"nodes"`
+	Time   int64           `json:"time"`
+
+	// Descriptions of rules owned, by node, a rule deleted on release only
+	// if still carrying its description
+	Descriptions map[string]string `json:"descriptions,omitempty"`
+}
+
+// Decode parameters of resource into v
+func (r *Resource) Decode(v interface{}) error {
+	return json.Unmarshal(r.Params, v)
+}
+
+// ResourceStore of resources in a json file, by kind and key
+type ResourceStore struct {
+	lock      sync.Mutex
+	file      string
+	resources map[string]*Resource
+}
+
+// GResources for resources owned by ovs
+var GResources = &ResourceStore{resources: make(map[string]*Resource)}
+
+// InitResources of file, resources of the running configuration imported
+// if the file not exist yet
+func InitResources(file string) {
+
+	if file == "" {
+		file = DefaultResourceFile
+	}
+
+	store, err := NewResourceStore(file)
+	if err != nil {
+		logger.Errorf("load resources of %s error %s\n", file, err)
+		return
+	}
+	GResources = store
+
+	if _, err := os.Stat(file); os.IsNotExist(err) {
+		importResources(store)
+	}
+}
+
+// NewResourceStore of file, empty if file not exist
+func NewResourceStore(file string) (*ResourceStore, error) {
+
+	s := &ResourceStore{
+		file:      file,
+		resources: make(map[string]*Resource),
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var resources []*Resource
+	if err := json.Unmarshal(data, &resources); err != nil {
+		return nil, err
+	}
+
+	for _, r := range resources {
+		s.resources[resourceID(r.Kind, r.Key)] = r
+	}
+
+	return s, nil
+}
+
+func resourceID(kind, key string) string {
+	return kind + "/" + key
+}
+
+// save resources, must be called with lock held
+func (s *ResourceStore) save() error {
+
+	if s.file == "" {
+		return nil
+	}
+
+	if err := utils.MkdirForFile(s.file, 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(s.list(""), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// written aside and renamed, never a half store
+	tmp := s.file + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// list resources of kind, all if kind empty, oldest first, must be called
+// with lock held
+func (s *ResourceStore) list(kind string) []*Resource {
+
+	resources := make([]*Resource, 0)
+	for _, r := range s.resources {
+		if kind == "" || r.Kind == kind {
+			resources = append(resources, r)
+		}
+	}
+
+	sort.Slice(resources, func(i, j int) bool {
+		if resources[i].Time != resources[j].Time {
+			return resources[i].Time < resources[j].Time
+		}
+		return resourceID(resources[i].Kind, resources[i].Key) < resourceID(resources[j].Kind, resources[j].Key)
+	})
+
+	return resources
+}
+
+// put resource, must be called with lock held
+func (s *ResourceStore) put(kind string, o *ownedResource) (*Resource, error) {
+
+	data, err := json.Marshal(o.params)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resource{
+		UUID:         uuid.Generate().String(),
+		Kind:         kind,
+		Key:          o.key,
+		Params:       data,
+		Nodes:        o.nodes,
+		Time:         time.Now().Unix(),
+		Descriptions: o.descriptions,
+	}
+
+	// the same resource set again keeps its uuid
+	if old := s.resources[resourceID(kind, o.key)]; old != nil {
+		r.UUID = old.UUID
+		r.Time = old.Time
+	}
+
+	s.resources[resourceID(kind, o.key)] = r
+
+	return r, nil
+}
+
+// putOwned resource of kind
+func (s *ResourceStore) putOwned(kind string, o *ownedResource) (*Resource, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	r, err := s.put(kind, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return r, s.save()
+}
+
+// Put resource of kind and key, with parameters and nodes owned
+func (s *ResourceStore) Put(kind, key string, params interface{}, nodes []string) (*Resource, error) {
+	return s.putOwned(kind, &ownedResource{key: key, params: params, nodes: nodes})
+}
+
+// Get resource of kind and key, nil if not exist
+func (s *ResourceStore) Get(kind, key string) *Resource {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.resources[resourceID(kind, key)]
+}
+
+// Remove resource of kind and key
+func (s *ResourceStore) Remove(kind, key string) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if _, ok := s.resources[resourceID(kind, key)]; !ok {
+		return nil
+	}
+	delete(s.resources, resourceID(kind, key))
+
+	return s.save()
+}
+
+// ownedResource to put into store
+type ownedResource struct {
+	key          string
+	params       interface{}
+	nodes        []string
+	descriptions map[string]string
+}
+
+// Replace all resources of kind
+func (s *ResourceStore) Replace(kind string, resources []*ownedResource) error {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	kept := make(map[string]bool)
+	for _, o := range resources {
+		if _, err := s.put(kind, o); err != nil {
+			return err
+		}
+		kept[resourceID(kind, o.key)] = true
+	}
+
+	for id, r := range s.resources {
+		if r.Kind == kind && !kept[id] {
+			delete(s.resources, id)
+		}
+	}
+
+	return s.save()
+}
+
+// List resources of kind, all if kind empty, oldest first
+func (s *ResourceStore) List(kind string) []*Resource {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.list(kind)
+}
+
+// shared if node owned by any resource other than r
+func (s *ResourceStore) shared(r *Resource, node string) bool {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, o := range s.resources {
+		if o.UUID == r.UUID {
+			continue
+		}
+		for _, n := range o.Nodes {
+			if n == node {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ruleDescriptions of nodes of tree carrying a description, by node
+func ruleDescriptions(tree *vyos.ConfigTree, nodes []string) map[string]string {
+
+	descriptions := make(map[string]string)
+	for _, node := range nodes {
+		if n := tree.Get(node); n != nil {
+			if d := n.Get("description"); d != nil && d.ValueSize() == 1 {
+				descriptions[node] = d.Value()
+			}
+		}
+	}
+
+	return descriptions
+}
+
+// own nodes by resource of kind and key, recorded once tree committed
+func own(tree *vyos.ConfigTree, kind, key string, params interface{}, nodes []string) {
+	tree.OnCommit(func() {
+		o := &ownedResource{key, params, nodes, ruleDescriptions(tree, nodes)}
+		if _, err := GResources.putOwned(kind, o); err != nil {
+			logger.Errorf("put %s resource %s error %s\n", kind, key, err)
+		}
+	})
+}
+
+// ownAll resources of kind, others of kind dropped once tree committed
+func ownAll(tree *vyos.ConfigTree, kind string, resources []*ownedResource) {
+	tree.OnCommit(func() {
+		for _, o := range resources {
+			o.descriptions = ruleDescriptions(tree, o.nodes)
+		}
+		if err := GResources.Replace(kind, resources); err != nil {
+			logger.Errorf("replace %s resources error %s\n", kind, err)
+		}
+	})
+}
+
+// release nodes owned by resource of kind and key, nodes shared with other
+// resources kept, and rules no longer carrying their descriptions, like
+// numbered again after a rollback, kept. False if resource not in store.
+func release(tree *vyos.ConfigTree, kind, key string) bool {
+
+	r := GResources.Get(kind, key)
+	if r == nil {
+		return false
+	}
+
+	for _, node := range r.Nodes {
+		if GResources.shared(r, node) {
+			continue
+		}
+
+		if des, ok := r.Descriptions[node]; ok {
+			if n := tree.Get(node); n != nil {
+				if d := n.Get("description"); d == nil || d.ValueSize() != 1 || d.Value() != des {
+					logger.Warnf("%s resource %s rule %s not of description %s, kept\n", kind, key, node, des)
+					continue
+				}
+			}
+		}
+
+		tree.Delete(node)
+	}
+
+	tree.OnCommit(func() {
+		if err := GResources.Remove(kind, key); err != nil {
+			logger.Errorf("remove %s resource %s error %s\n", kind, key, err)
+		}
+	})
+
+	return true
+}
+
+// resourcesOf kind configured in running configuration, those with any
+// node owned missing, like reverted by rollback, left out
+func resourcesOf(kind string, tree *vyos.ConfigTree) []*Resource {
+
+	resources := make([]*Resource, 0)
+	for _, r := range GResources.List(kind) {
+		configured := true
+		for _, node := range r.Nodes {
+			if tree.Get(node) == nil {
+				configured = false
+				break
+			}
+		}
+		if configured {
+			resources = append(resources, r)
+		} else {
+			logger.Warnf("%s resource %s not in running configuration\n", kind, r.Key)
+		}
+	}
+
+	return resources
+}
@@ -0,0 +1,254 @@
+package plugins
+
+import (
+	"fmt"
+	"io/ioutil"
+	"octlink/ovs/utils"
+	"octlink/ovs/utils/configuration"
+	"octlink/ovs/utils/vyos"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testConfig = `
+interfaces {
+    ethernet eth0 {
+        address 172.20.0.10/16
+        address 172.20.0.100/16
+    }
+    ethernet eth1 {
+        address 192.168.0.1/16
+    }
+}
+service {
+    dns {
+        forwarding {
+            listen-on eth1
+            name-server 8.8.8.8
+            name-server 8.8.4.4
+        }
+    }
+}
+`
+
+// testNics of the box, by name, with mac and address
+var testNics = map[string][]string{
+	"eth0": {"fa:16:3e:00:00:01", "172.20.0.10"},
+	"eth1": {"fa:16:3e:00:00:02", "192.168.0.1"},
+}
+
+// useTestNics for nic lookups, returns func to restore the system ones
+func useTestNics() func() {
+
+	nics, info, byIP := utils.NicsSourceFunc, utils.NicInfoSourceFunc, utils.NicNameByIPSourceFunc
+
+	utils.NicsSourceFunc = func() (map[string]utils.Nic, error) {
+		nics := make(map[string]utils.Nic)
+		for name, nic := range testNics {
+			nics[name] = utils.Nic{Name: name, Mac: nic[0]}
+		}
+		return nics, nil
+	}
+
+	utils.NicInfoSourceFunc = func(nicname string) (string, string, string, error) {
+		if nic, ok := testNics[nicname]; ok {
+			return nic[1], "255.255.0.0", "", nil
+		}
+		return "", "", "", fmt.Errorf("no nic %s", nicname)
+	}
+
+	utils.NicNameByIPSourceFunc = func(ip string) (string, error) {
+		for name, nic := range testNics {
+			if nic[1] == ip || (name == "eth0" && ip == "172.20.0.100") {
+				return name, nil
+			}
+		}
+		return "", fmt.Errorf("no nic with ip %s", ip)
+	}
+
+	return func() {
+		utils.NicsSourceFunc, utils.NicInfoSourceFunc, utils.NicNameByIPSourceFunc = nics, info, byIP
+	}
+}
+
+// initTest logs and a store of file in a temp dir, returns func to clean
+func initTest(t *testing.T) (string, func()) {
+
+	dir, err := ioutil.TempDir("", "ovs-plugins")
+	if err != nil {
+		t.Fatalf("create temp dir error %s", err)
+	}
+
+	configuration.Conf.LogDirectory = dir
+	InitLog(0)
+	vyos.InitLog(0)
+
+	resources := GResources
+	restoreNics := useTestNics()
+
+	return filepath.Join(dir, "resources.json"), func() {
+		GResources = resources
+		restoreNics()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestResourceStore(t *testing.T) {
+
+	file, clean := initTest(t)
+	defer clean()
+
+	store, err := NewResourceStore(file)
+	if err != nil || len(store.List("")) != 0 {
+		t.Fatalf("store of file not exist should be empty, %v", err)
+	}
+	GResources = store
+
+	tree := vyos.NewParserFromConfiguration(testConfig).Tree
+
+	// owned once committed, never if reverted
+	vip := &Vip{Ip: "172.20.0.100", Netmask: "255.255.0.0", OwnerEthernetMac: "fa:16:3e:00:00:01"}
+	own(tree, ResourceVip, vip.Ip, vip, []string{"interfaces ethernet eth0 address 172.20.0.100/16"})
+	tree.Reverted()
+	if store.Get(ResourceVip, vip.Ip) != nil {
+		t.Fatalf("resource of changes reverted should not be stored")
+	}
+
+	own(tree, ResourceVip, vip.Ip, vip, []string{"interfaces ethernet eth0 address 172.20.0.100/16"})
+	for _, address := range []string{"8.8.8.8", "8.8.4.4"} {
+		own(tree, ResourceDNS, address, &Dns{DnsAddress: address}, []string{
+			"service dns forwarding listen-on eth1",
+			"service dns forwarding name-server " + address,
+		})
+	}
+	tree.Committed()
+
+	r := store.Get(ResourceVip, vip.Ip)
+	if r == nil || len(store.List(ResourceDNS)) != 2 {
+		t.Fatalf("resources committed should be stored, %v got", store.List(""))
+	}
+
+	got := new(Vip)
+	if err := r.Decode(got); err != nil || *got != *vip {
+		t.Fatalf("parameters should be decoded, %+v %v got", got, err)
+	}
+
+	// persisted, and the same resource put again keeps its uuid
+	reloaded, err := NewResourceStore(file)
+	if err != nil || len(reloaded.List("")) != 3 || reloaded.Get(ResourceVip, vip.Ip).UUID != r.UUID {
+		t.Fatalf("resources should be reloaded of file, %v", err)
+	}
+	if again, _ := store.Put(ResourceVip, vip.Ip, vip, r.Nodes); again.UUID != r.UUID {
+		t.Fatalf("uuid should be kept, %s got", again.UUID)
+	}
+
+	// nodes shared kept until the last owner released
+	tree = vyos.NewParserFromConfiguration(testConfig).Tree
+	if !release(tree, ResourceDNS, "8.8.8.8") {
+		t.Fatalf("dns in store should be released")
+	}
+	if tree.Get("service dns forwarding listen-on eth1") == nil ||
+		tree.Get("service dns forwarding name-server").Value() != "8.8.4.4" {
+		t.Fatalf("only nodes not shared should be deleted, %v got", tree.Commands())
+	}
+	if store.Get(ResourceDNS, "8.8.8.8") == nil {
+		t.Fatalf("resource should be removed only once committed")
+	}
+	tree.Committed()
+
+	if release(tree, ResourceDNS, "8.8.8.8") {
+		t.Fatalf("dns removed should not be released again")
+	}
+	if !release(tree, ResourceDNS, "8.8.4.4") || tree.Get("service dns forwarding listen-on eth1") != nil {
+		t.Fatalf("nodes of the last owner should be deleted, %v got", tree.Commands())
+	}
+	tree.Committed()
+
+	// replaced, others of kind dropped
+	tree = vyos.NewParserFromConfiguration(testConfig).Tree
+	ownAll(tree, ResourceVip, []*ownedResource{
+		{key: "172.20.0.101", params: &Vip{Ip: "172.20.0.101"}, nodes: []string{"interfaces ethernet eth0 address 172.20.0.101/16"}},
+	})
+	tree.Committed()
+	if store.Get(ResourceVip, vip.Ip) != nil || store.Get(ResourceVip, "172.20.0.101") == nil {
+		t.Fatalf("resources of kind should be replaced, %v got", store.List(""))
+	}
+
+	// shown only if configured
+	running := vyos.NewParserFromConfiguration(testConfig).Tree
+	if resources := resourcesOf(ResourceVip, running); len(resources) != 0 {
+		t.Fatalf("resource with nodes not configured should be left out, %v got", resources)
+	}
+
+	if reloaded, _ := NewResourceStore(file); len(reloaded.List("")) != 1 {
+		t.Fatalf("store file should be saved on each change, %v got", reloaded.List(""))
+	}
+}
+
+func TestReleaseRuleDescribed(t *testing.T) {
+
+	file, clean := initTest(t)
+	defer clean()
+
+	GResources, _ = NewResourceStore(file)
+
+	rule := func(mac string) string {
+		return fmt.Sprintf(`
+nat {
+    source {
+        rule 8888 {
+            description SNAT-%s
+            outbound-interface eth0
+        }
+    }
+}
+`, mac)
+	}
+
+	tree := vyos.NewParserFromConfiguration(rule("fa:16:3e:00:00:02")).Tree
+	own(tree, ResourceSnat, "fa:16:3e:00:00:02", &Snat{}, []string{"nat source rule 8888"})
+	tree.Committed()
+
+	// the rule numbered again for another snat, like after a rollback
+	tree = vyos.NewParserFromConfiguration(rule("fa:16:3e:00:00:03")).Tree
+	if !release(tree, ResourceSnat, "fa:16:3e:00:00:02") || tree.Get("nat source rule 8888") == nil {
+		t.Fatalf("rule of another description should be kept, %v got", tree.Commands())
+	}
+
+	tree = vyos.NewParserFromConfiguration(rule("fa:16:3e:00:00:02")).Tree
+	if !release(tree, ResourceSnat, "fa:16:3e:00:00:02") || tree.Get("nat source rule 8888") != nil {
+		t.Fatalf("rule of its description should be deleted, %v got", tree.Commands())
+	}
+}
+
+func TestSyncVipsReleases(t *testing.T) {
+
+	file, clean := initTest(t)
+	defer clean()
+
+	GResources, _ = NewResourceStore(file)
+
+	old := &Vip{Ip: "172.20.0.100", Netmask: "255.255.0.0", OwnerEthernetMac: "fa:16:3e:00:00:01"}
+	tree := vyos.NewParserFromConfiguration(testConfig).Tree
+	if err := SyncVips(tree, []*Vip{old}); err != nil {
+		t.Fatalf("sync vips error %s", err)
+	}
+	tree.Committed()
+
+	// vip dropped from sync released, not left unowned
+	vip := &Vip{Ip: "172.20.0.101", Netmask: "255.255.0.0", OwnerEthernetMac: "fa:16:3e:00:00:01"}
+	tree = vyos.NewParserFromConfiguration(testConfig).Tree
+	if err := SyncVips(tree, []*Vip{vip}); err != nil {
+		t.Fatalf("sync vips error %s", err)
+	}
+	tree.Committed()
+
+	addresses := tree.Get("interfaces ethernet eth0 address").Values()
+	if len(addresses) != 2 || addresses[1] != "172.20.0.101/16" {
+		t.Fatalf("address of vip dropped should be deleted, %v got", addresses)
+	}
+	if GResources.Get(ResourceVip, old.Ip) != nil || GResources.Get(ResourceVip, vip.Ip) == nil {
+		t.Fatalf("only vips synced should be stored, %v got", GResources.List(""))
+	}
+}
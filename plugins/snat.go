@@ -2,7 +2,6 @@ package plugins
 
 import (
 	"fmt"
-	"octlink/ovs/utils"
 	"octlink/ovs/utils/merrors"
 	"octlink/ovs/utils/vyos"
 )
@@ -37,63 +36,61 @@ func hasRuleNumberForAddress(tree *vyos.ConfigTree, address string) bool {
 	return false
 }
 
+func makeSnatDescription(s *Snat) string {
+	return fmt.Sprintf("SNAT-%v", s.PrivateNicMac)
+}
+
 // AddSnat for image, after image added,
 // installpath, diskSize, virtualSize, Status, md5sum need update after manifest installed
-func (s *Snat) AddSnat() int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
+func (s *Snat) AddSnat(tree *vyos.ConfigTree) error {
 
-	outNic, err := utils.GetNicNameByMac(s.PublicNicMac)
+	outNic, err := nicNameByMac(s.PublicNicMac)
 	if err != nil {
-		logger.Panicf("get nic name by mac %s error %s\n", s.PublicNicMac, err)
-		return merrors.ErrBadParas
+		return err
 	}
 
-	privateIP, snatNetmask, _, err := utils.GetNicInfoByMac(s.PrivateNicMac)
+	address, privateNicIP, snatNetmask, err := nicNetwork(s.PrivateNicMac)
 	if err != nil {
-		logger.Panicf("get nic info by mac %s error, %s\n", s.PrivateNicMac, err)
-		return merrors.ErrBadParas
-	}
-
-	address, err := utils.GetNetworkNumber(privateIP, snatNetmask)
-	if err != nil {
-		logger.Panicf("get network number by %s:%s error, %s\n",
-			privateIP, snatNetmask, err)
-		return merrors.ErrBadParas
+		return err
 	}
 
 	if hasRuleNumberForAddress(tree, address) {
 		logger.Errorf("not enough rule number for snat, address[%s]\n", address)
-		return merrors.ErrSyscallErr
+		return merrors.Errorf(merrors.ErrSyscallErr, "snat rule of source address %s already exists", address)
 	}
 
-	// make source nat rule as the latest rule
-	// in case there are EIP rules
-	tree.SetSnatWithRuleNumber(SnatRuleNumber,
+	// numbered after rules of EIPs, each snat a rule
+	number := tree.SetRule(vyos.SnatRules, vyos.RuleSnat,
+		fmt.Sprintf("description %s", makeSnatDescription(s)),
 		fmt.Sprintf("outbound-interface %s", outNic),
 		fmt.Sprintf("source address %v", address),
 		fmt.Sprintf("translation address %s", s.PublicIP),
 	)
 
-	tree.Apply(false)
+	s.PrivateNicIP = privateNicIP
+	s.SnatNetmask = snatNetmask
+	own(tree, ResourceSnat, s.PrivateNicMac, s, []string{vyos.RulePath(vyos.SnatRules, number)})
 
-	return 0
+	return nil
 }
 
-// RemoveSnat Snat rule
-func (s *Snat) RemoveSnat() int {
+// RemoveSnat Snat rule, the rule owned by snat in store deleted, or the
+// source address of rules of the private network if snat not in store
+func (s *Snat) RemoveSnat(tree *vyos.ConfigTree) error {
+
+	if release(tree, ResourceSnat, s.PrivateNicMac) {
+		return nil
+	}
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
 	rs := tree.Get("nat source rule")
 	if rs == nil {
 		logger.Debugf("not nat source rule remove\n")
-		return merrors.ErrSuccess
+		return nil
 	}
 
-	privateNicIP, snatNetmask, _, err := utils.GetNicInfoByMac(s.PrivateNicMac)
+	address, privateNicIP, snatNetmask, err := nicNetwork(s.PrivateNicMac)
 	if err != nil {
-		logger.Errorf("get nic info of %s error\n", s.PrivateNicMac)
-		return merrors.ErrBadParas
+		return err
 	}
 
 	s.PrivateNicIP = privateNicIP
@@ -102,116 +99,99 @@ func (s *Snat) RemoveSnat() int {
 	logger.Debugf("get network of %s source rule of %s:%s\n",
 		s.PrivateNicMac, s.PrivateNicIP, s.SnatNetmask)
 
-	address, err := utils.GetNetworkNumber(s.PrivateNicIP, s.SnatNetmask)
-	if err != nil {
-		logger.Panicf("nat source rule of %s:%s not exist\n", s.PrivateNicIP, s.SnatNetmask)
-		return merrors.ErrBadParas
-	}
-
 	for _, r := range rs.Children() {
 		if addr := r.Get("source address"); addr != nil && addr.Value() == address {
 			addr.Delete()
 		}
 	}
 
-	tree.Apply(false)
-
-	return merrors.ErrSuccess
+	return nil
 }
 
-// SyncSnat Snat rule, delete it firstly and then add it back.
-func (s *Snat) SyncSnat() int {
+// snatRules range of source nat rules of snats
+var snatRules = vyos.FindRuleRange(vyos.ChainSnat, vyos.RuleSnat)
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+// isSnatRule if rule is a source nat rule of snat
+func isSnatRule(rule *vyos.ConfigNode) bool {
+	return snatRules.HasRule(rule)
+}
 
-	outNic, err := utils.GetNicNameByMac(s.PublicNicMac)
-	if err != nil {
-		logger.Panicf("get nic name by mac %s error %s\n", s.PublicNicMac, err)
-		return merrors.ErrBadParas
+// findSnatRule of source address, nil if not found
+func findSnatRule(tree *vyos.ConfigTree, address string) *vyos.ConfigNode {
+
+	rs := tree.Get(vyos.SnatRules)
+	if rs == nil {
+		return nil
 	}
 
-	privateIP, snatNetmask, _, err := utils.GetNicInfoByMac(s.PrivateNicMac)
-	if err != nil {
-		logger.Panicf("get nic info by mac %s error, %s\n", s.PrivateNicMac, err)
-		return merrors.ErrBadParas
+	for _, r := range rs.Children() {
+		if addr := r.Get("source address"); isSnatRule(r) && addr != nil && addr.Value() == address {
+			return r
+		}
 	}
 
-	address, err := utils.GetNetworkNumber(privateIP, snatNetmask)
+	return nil
+}
+
+// SyncSnat Snat rule as the only snat, only changed parts of the rule are
+// touched.
+func (s *Snat) SyncSnat(tree *vyos.ConfigTree) error {
+
+	outNic, err := nicNameByMac(s.PublicNicMac)
 	if err != nil {
-		logger.Panicf("get network number by %s:%s error, %s\n",
-			privateIP, snatNetmask, err)
-		return merrors.ErrBadParas
+		return err
 	}
 
-	if rs := tree.Getf("nat source rule %v", SnatRuleNumber); rs != nil {
-		rs.Delete()
+	address, privateNicIP, snatNetmask, err := nicNetwork(s.PrivateNicMac)
+	if err != nil {
+		return err
 	}
 
-	tree.SetSnatWithRuleNumber(SnatRuleNumber,
+	desired := &vyos.ConfigTree{}
+	desired.SetRule(vyos.SnatRules, vyos.RuleSnat,
+		fmt.Sprintf("description %s", makeSnatDescription(s)),
 		fmt.Sprintf("outbound-interface %s", outNic),
 		fmt.Sprintf("source address %s", address),
 		fmt.Sprintf("translation address %s", s.PublicIP),
 	)
 
-	tree.Apply(false)
+	tree.Sync(desired, isSnatRule)
 
-	return 0
+	s.PrivateNicIP = privateNicIP
+	s.SnatNetmask = snatNetmask
+	ownAll(tree, ResourceSnat, []*ownedResource{
+		{key: s.PrivateNicMac, params: s, nodes: []string{findSnatRule(tree, address).String()}},
+	})
+
+	return nil
 }
 
 // GetSnat get snat settings
-func GetSnat(privateNicMac string) (*Snat, int) {
+func GetSnat(privateNicMac string) (*Snat, error) {
 
-	privateIP, netmask, _, err := utils.GetNicInfoByMac(privateNicMac)
-	if err != nil {
-		logger.Errorf("not nic info got for private mac %s\n", privateNicMac)
-		return nil, merrors.ErrBadParas
-	}
-	network, _ := utils.GetNetworkNumber(privateIP, netmask)
-
-	rules := GetAllSnats()
-	for _, nat := range rules {
-		if n, _ := utils.GetNetworkNumber(nat.PrivateNicIP, nat.SnatNetmask); n == network {
-			logger.Debugf("found nat rule of %s\n", network)
-			return nat, merrors.ErrSuccess
+	for _, nat := range GetAllSnats() {
+		if nat.PrivateNicMac == privateNicMac {
+			return nat, nil
 		}
 	}
 
-	logger.Errorf("not found nat rule of %s\n", network)
-
-	return nil, merrors.ErrSegmentNotExist
+	return nil, merrors.Errorf(merrors.ErrSegmentNotExist, "no snat rule of nic with mac %s", privateNicMac)
 }
 
-// GetAllSnats by condition
+// GetAllSnats of store configured in running configuration
 func GetAllSnats() []*Snat {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+	snats := make([]*Snat, 0)
 
-	sn := new(Snat)
-
-	rule := tree.Getf("nat source rule %d", SnatRuleNumber)
-	if rule != nil {
-		outNic := rule.Get("outbound-interface").Value()
-		sn.PublicNicMac = utils.GetNicMacByName(outNic)
-		logger.Debugf("Got nat oubound-interface %s:%s\n", outNic, sn.PublicNicMac)
-	} else {
-		return []*Snat{
-			sn,
+	tree := vyos.NewParserFromShowConfiguration().Tree
+	for _, r := range resourcesOf(ResourceSnat, tree) {
+		sn := new(Snat)
+		if err := r.Decode(sn); err != nil {
+			logger.Errorf("decode snat %s error %s\n", r.Key, err)
+			continue
 		}
+		snats = append(snats, sn)
 	}
 
-	if rs := rule.Getf("source address"); rs != nil {
-		addr, netmask := utils.ParseCIDR(rs.Value())
-		sn.PrivateNicIP = addr
-		sn.SnatNetmask = netmask
-		logger.Debugf("Got nat private nic ip %s/%s\n", sn.PrivateNicIP, sn.SnatNetmask)
-	}
-
-	if rs := rule.Getf("translation address"); rs != nil {
-		sn.PublicIP = rs.Value()
-		logger.Debugf("Got nat public nic ip %s\n", sn.PrivateNicIP)
-	}
-
-	return []*Snat{
-		sn,
-	}
+	return snats
 }
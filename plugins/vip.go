@@ -9,78 +9,91 @@ import (
 
 // Vip for vip sturcture
 type Vip struct {
-	Ip               string `json:"ip"`
-	Netmask          string `json:"netmask"`
-	OwnerEthernetMac string `json:"ownerEthernetMac"`
+	Ip               string `json:"ip" param:"ipv4"`
+	Netmask          string `json:"netmask" param:"netmask"`
+	OwnerEthernetMac string `json:"ownerEthernetMac" param:"mac"`
 }
 
-func (vip *Vip) AddVip() int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
+// address of vip on its owner nic, like eth1 and 10.0.0.5/24
+func (vip *Vip) address() (string, string, error) {
 
-	nicname, err := utils.GetNicNameByMac(vip.OwnerEthernetMac)
+	nicname, err := nicNameByMac(vip.OwnerEthernetMac)
 	if err != nil {
-		logger.Errorf("get nic name of %s error\n", vip.OwnerEthernetMac)
-		return merrors.ErrBadParas
+		return "", "", err
 	}
 
 	cidr := utils.NetmaskToCIDR(vip.Netmask)
+	if cidr == -1 {
+		return "", "", merrors.Errorf(merrors.ErrBadParas, "bad netmask %s of vip %s", vip.Netmask, vip.Ip)
+	}
+
+	return nicname, fmt.Sprintf("%v/%v", vip.Ip, cidr), nil
+}
+
+// AddVip to add vip
+func (vip *Vip) AddVip(tree *vyos.ConfigTree) error {
 
-	addr := fmt.Sprintf("%v/%v", vip.Ip, cidr)
-	if n := tree.Getf("interfaces ethernet %s address %v", nicname, addr); n == nil {
-		tree.SetfWithoutCheckExisting("interfaces ethernet %s address %v", nicname, addr)
+	nicname, addr, err := vip.address()
+	if err != nil {
+		return err
 	}
 
-	tree.Apply(false)
+	tree.Addf("interfaces ethernet %s address %v", nicname, addr)
 
-	return 0
+	own(tree, ResourceVip, vip.Ip, vip, []string{fmt.Sprintf("interfaces ethernet %s address %v", nicname, addr)})
+
+	return nil
 }
 
-// DeleteVip to delete vip
-func (vip *Vip) DeleteVip() int {
+// DeleteVip to delete vip, the address owned by vip in store deleted, or
+// the address of vip parameters if vip not in store
+func (vip *Vip) DeleteVip(tree *vyos.ConfigTree) error {
 
-	tree := vyos.NewParserFromShowConfiguration().Tree
+	if release(tree, ResourceVip, vip.Ip) {
+		return nil
+	}
 
-	nicname, err := utils.GetNicNameByMac(vip.OwnerEthernetMac)
+	nicname, addr, err := vip.address()
 	if err != nil {
-		logger.Errorf("get nic name of %s error\n", vip.OwnerEthernetMac)
-		return merrors.ErrBadParas
+		return err
 	}
 
-	cidr := utils.NetmaskToCIDR(vip.Netmask)
-
-	addr := fmt.Sprintf("%v/%v", vip.Ip, cidr)
 	tree.Deletef("interfaces ethernet %s address %v", nicname, addr)
 
-	tree.Apply(false)
-
-	return 0
+	return nil
 }
 
-// SyncVips to sync all vip
-func SyncVips(vips []*Vip) int {
-
-	tree := vyos.NewParserFromShowConfiguration().Tree
+// SyncVips to sync all vip, addresses of vips in store but not in vips
+// released, other addresses kept
+func SyncVips(tree *vyos.ConfigTree, vips []*Vip) error {
 
+	wanted := make(map[string]bool, len(vips))
 	for _, vip := range vips {
+		wanted[vip.Ip] = true
+	}
 
-		nicname, err := utils.GetNicNameByMac(vip.OwnerEthernetMac)
+	// never left in running configuration out of store
+	for _, r := range GResources.List(ResourceVip) {
+		if !wanted[r.Key] {
+			release(tree, ResourceVip, r.Key)
+		}
+	}
+
+	owned := make([]*ownedResource, 0, len(vips))
+	for _, vip := range vips {
+		nicname, addr, err := vip.address()
 		if err != nil {
-			logger.Errorf("get nic name of %s error\n", vip.OwnerEthernetMac)
-			return merrors.ErrBadParas
+			return err
 		}
 
-		cidr := utils.NetmaskToCIDR(vip.Netmask)
+		tree.Addf("interfaces ethernet %s address %v", nicname, addr)
 
-		addr := fmt.Sprintf("%v/%v", vip.Ip, cidr)
-		if n := tree.Getf("interfaces ethernet %s address %v", nicname, addr); n == nil {
-			tree.SetfWithoutCheckExisting("interfaces ethernet %s address %v", nicname, addr)
-		}
+		owned = append(owned, &ownedResource{key: vip.Ip, params: vip,
+			nodes: []string{fmt.Sprintf("interfaces ethernet %s address %v", nicname, addr)}})
 	}
+	ownAll(tree, ResourceVip, owned)
 
-	tree.Apply(false)
-
-	return 0
+	return nil
 }
 
 /*
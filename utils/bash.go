@@ -142,16 +142,42 @@ func (b *Bash) RunWithReturn() (retCode int, stdout, stderr string, err error) {
 	return
 }
 
+// BashError for shell failure, carries the failing command and its output
+type BashError struct {
+	Command string
+	RetCode int
+	Stdout  string
+	Stderr  string
+	Err     error
+}
+
+// Error for BashError
+func (e *BashError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("shell failure[command: %v], internal error: %v",
+			e.Command, e.Err)
+	}
+
+	return fmt.Sprintf("shell failure[command: %v, return code: %v, stdout: %v, stderr: %v",
+		e.Command, e.RetCode, e.Stdout, e.Stderr)
+}
+
 // PanicIfError for bashing
 func (b *Bash) PanicIfError() {
 	if b.err != nil {
-		panic(fmt.Errorf("shell failure[command: %v], internal error: %v",
-			b.Command, b.err))
+		panic(&BashError{
+			Command: b.Command,
+			Err:     b.err,
+		})
 	}
 
 	if b.retCode != 0 {
-		panic(fmt.Errorf("shell failure[command: %v, return code: %v, stdout: %v, stderr: %v",
-			b.Command, b.retCode, b.stdout, b.stderr))
+		panic(&BashError{
+			Command: b.Command,
+			RetCode: b.retCode,
+			Stdout:  b.stdout,
+			Stderr:  b.stderr,
+		})
 	}
 }
 
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+)
+
+// BootStageFile for bootstrap stages recorded by ovsboot, replayed by ovs
+var BootStageFile = "/home/vyos/rvm/bootstrap-stages.json"
+
+// BootStage of ovsboot, Error empty for success
+type BootStage struct {
+	Stage string `json:"stage"`
+	Time  int64  `json:"time"`
+	Error string `json:"error,omitempty"`
+}
+
+// ResetBootStages to clear stages of previous bootstrap
+func ResetBootStages() error {
+	if err := MkdirForFile(BootStageFile, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(BootStageFile, nil, 0644)
+}
+
+// RecordBootStage append stage to boot stage file
+func RecordBootStage(stage string, err error) error {
+
+	record := &BootStage{
+		Stage: stage,
+		Time:  CurrentTime(),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	data, _ := json.Marshal(record)
+
+	fd, e := os.OpenFile(BootStageFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if e != nil {
+		return e
+	}
+	defer fd.Close()
+
+	_, e = fd.Write(append(data, '\n'))
+
+	return e
+}
+
+// LoadBootStages recorded by the latest bootstrap, in order
+func LoadBootStages() ([]*BootStage, error) {
+
+	fd, err := os.Open(BootStageFile)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	stages := make([]*BootStage, 0)
+
+	scanner := bufio.NewScanner(fd)
+	for scanner.Scan() {
+		stage := new(BootStage)
+		if err := json.Unmarshal(scanner.Bytes(), stage); err != nil {
+			continue
+		}
+		stages = append(stages, stage)
+	}
+
+	return stages, scanner.Err()
+}
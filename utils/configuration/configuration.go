@@ -25,8 +25,149 @@ type Configuration struct {
 	LogDirectory string `yaml:"logdirectory,omitempty"`
 
 	HTTP struct {
+		// Addr of plain http listener, used only if no listeners configured
 		Addr string `yaml:"addr,omitempty"`
+
+		// Listeners of api server, served concurrently
+		Listeners []ListenerConfig `yaml:"listeners,omitempty"`
+	}
+
+	// Job for async api execution
+	Job struct {
+		// Workers running async jobs concurrently
+		Workers int `yaml:"workers,omitempty"`
+
+		// Retention in seconds of finished jobs
+		Retention int `yaml:"retention,omitempty"`
+	}
+
+	// Idempotency for mutating requests with request id
+	Idempotency struct {
+		// Retention in seconds of stored responses
+		Retention int `yaml:"retention,omitempty"`
 	}
+
+	// Audit for api audit journal
+	Audit struct {
+		// File of audit journal, audit.log under log directory if not set
+		File string `yaml:"file,omitempty"`
+
+		// MaxSize in bytes of audit journal before rotated
+		MaxSize int64 `yaml:"maxsize,omitempty"`
+
+		// MaxFiles of rotated audit journals kept
+		MaxFiles int `yaml:"maxfiles,omitempty"`
+	}
+
+	// Events for live event stream
+	Events struct {
+		// Buffer of latest events kept for resuming
+		Buffer int `yaml:"buffer,omitempty"`
+
+		// NicPoll interval in seconds to watch nics
+		NicPoll int `yaml:"nicpoll,omitempty"`
+	}
+
+	// Snapshots of running configuration
+	Snapshots struct {
+		// Dir of snapshots, /home/vyos/rvm/snapshots if not set
+		Dir string `yaml:"dir,omitempty"`
+
+		// MaxAuto of snapshots taken before commits kept
+		MaxAuto int `yaml:"maxauto,omitempty"`
+
+		// MaxNamed of snapshots taken on demand kept
+		MaxNamed int `yaml:"maxnamed,omitempty"`
+	}
+
+	// Resources set by ovs, with vyos nodes owned
+	Resources struct {
+		// File of resources, /home/vyos/rvm/resources.json if not set
+		File string `yaml:"file,omitempty"`
+	}
+
+	// Backend of vyos configuration
+	Backend struct {
+		// Type of backend, vyos for vyos cli, or memory to run off-box
+		Type string `yaml:"type,omitempty"`
+
+		// Config file in config.boot syntax, initial configuration of
+		// memory backend
+		Config string `yaml:"config,omitempty"`
+	}
+
+	// Auth for api request authentication
+	Auth AuthConfig `yaml:"auth,omitempty"`
+
+	// RateLimit for api request limiting
+	RateLimit RateLimitConfig `yaml:"ratelimit,omitempty"`
+}
+
+// LimitConfig of token bucket, Rate 0 for no limitation
+type LimitConfig struct {
+	// Rate of requests per second
+	Rate float64 `yaml:"rate,omitempty"`
+
+	// Burst of requests allowed at once, at least 1
+	Burst int `yaml:"burst,omitempty"`
+}
+
+// RateLimitConfig for api request limiting
+type RateLimitConfig struct {
+	// Remote limit for each remote address
+	Remote LimitConfig `yaml:"remote,omitempty"`
+
+	// Module limit for each api module, like dnat
+	Module LimitConfig `yaml:"module,omitempty"`
+
+	// Modules of module limits overriding Module
+	Modules map[string]LimitConfig `yaml:"modules,omitempty"`
+
+	// MaxMutating of concurrent mutating requests in flight, 0 for no limitation
+	MaxMutating int `yaml:"maxmutating,omitempty"`
+}
+
+// AuthConfig for signed api requests
+type AuthConfig struct {
+	// Enabled to reject requests without valid signature
+	Enabled bool `yaml:"enabled,omitempty"`
+
+	// Keys of key id to shared secret
+	Keys map[string]string `yaml:"keys,omitempty"`
+
+	// MaxSkew in seconds between request timestamp and local time
+	MaxSkew int `yaml:"maxskew,omitempty"`
+
+	// NonceTTL in seconds to remember used nonces
+	NonceTTL int `yaml:"noncettl,omitempty"`
+
+	// AllowList of "METHOD path" served without authentication
+	AllowList []string `yaml:"allowlist,omitempty"`
+}
+
+// ListenerConfig for one listener of api server
+type ListenerConfig struct {
+	// Network of tcp or unix, tcp if not set
+	Network string `yaml:"network,omitempty"`
+
+	// Addr like :3443 for tcp, or socket path for unix
+	Addr string `yaml:"addr,omitempty"`
+
+	// Mode of unix socket file like 0660, 0600 if not set
+	Mode string `yaml:"mode,omitempty"`
+
+	// CertFile and KeyFile in pem to serve tls, reloaded on SIGHUP
+	CertFile string `yaml:"certfile,omitempty"`
+	KeyFile  string `yaml:"keyfile,omitempty"`
+
+	// ClientCAFile in pem to require and verify client certificates
+	ClientCAFile string `yaml:"clientcafile,omitempty"`
+
+	// ReadTimeout, WriteTimeout and IdleTimeout in seconds, 0 for none.
+	// Event streams are cut by WriteTimeout.
+	ReadTimeout  int `yaml:"readtimeout,omitempty"`
+	WriteTimeout int `yaml:"writetimeout,omitempty"`
+	IdleTimeout  int `yaml:"idletimeout,omitempty"`
 }
 
 // Conf global configuration
@@ -8,18 +8,62 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	// ContextWarning for warning message of response in gin context
+	ContextWarning = "warning"
+
+	// HeaderAcceptLanguage for language of messages
+	HeaderAcceptLanguage = "Accept-Language"
+)
+
+// Lang of messages for request, by Accept-Language header
+func Lang(ctx *gin.Context) string {
+	if ctx.Request == nil {
+		return merrors.LangEN
+	}
+	return merrors.ParseLang(ctx.Request.Header.Get(HeaderAcceptLanguage))
+}
+
+// Warn add warning message to response, both in header and body
+func Warn(ctx *gin.Context, message string) {
+	ctx.Header("Warning", "299 - \""+message+"\"")
+	ctx.Set(ContextWarning, message)
+}
+
+// BuildErrorObj of response envelope, errlog of *merrors.MError rendered
+// with its detail and cause
 func BuildErrorObj(ctx *gin.Context, code int, errlog interface{},
 	data interface{}) map[string]interface{} {
 
-	return gin.H{
-		"errorObj": gin.H{
-			"errorNo":  code,
-			"errorLog": errlog,
-			"errorMsg": merrors.GetMsg(code),
-		},
-		"apiId": uuid.Generate().Simple(),
-		"data":  data,
+	lang := Lang(ctx)
+
+	errorObj := gin.H{
+		"errorNo":  code,
+		"errorLog": errlog,
+		"errorMsg": merrors.GetMsgLang(code, lang),
 	}
+
+	if e, ok := errlog.(*merrors.MError); ok {
+		errorObj["errorNo"] = e.ErrorNo
+		errorObj["errorLog"] = e.Log()
+		errorObj["errorMsg"] = merrors.GetMsgLang(e.ErrorNo, lang)
+		errorObj["detail"] = e.Detail
+		if e.Cause != nil {
+			errorObj["cause"] = e.Cause.Error()
+		}
+	}
+
+	obj := gin.H{
+		"errorObj": errorObj,
+		"apiId":    uuid.Generate().Simple(),
+		"data":     data,
+	}
+
+	if warning, ok := ctx.Get(ContextWarning); ok {
+		obj["warning"] = warning
+	}
+
+	return obj
 }
 
 // RHttprespnse retrun none error code 200
@@ -28,6 +72,15 @@ func Ok(ctx *gin.Context, data interface{}) {
 	return
 }
 
+// OkList retrun list data with total count of items, and count of this page
+func OkList(ctx *gin.Context, data interface{}, total int, count int) {
+	obj := BuildErrorObj(ctx, merrors.ErrSuccess, nil, data)
+	obj["total"] = total
+	obj["count"] = count
+	ctx.JSON(http.StatusOK, obj)
+	return
+}
+
 // RHttprespnse retrun none error code 200
 func Error(ctx *gin.Context, err int, errlog interface{}) {
 	ctx.JSON(http.StatusOK, BuildErrorObj(ctx, err, errlog, nil))
@@ -1,5 +1,13 @@
 package merrors
 
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
 const (
 	// ErrSuccess cmd successfully
 	ErrSuccess = iota
@@ -56,6 +64,26 @@ const (
 
 	// ErrUserNotLogin error for user not login
 	ErrUserNotLogin
+
+	// ErrUnauthorized error for request without valid signature
+	ErrUnauthorized
+
+	// ErrTooManyRequests error for request over rate or concurrency limit
+	ErrTooManyRequests
+
+	// ErrRequestConflict error for request id reused with different paras
+	ErrRequestConflict
+
+	// ErrCommitPending error for commit while a commit confirm is pending
+	ErrCommitPending
+)
+
+const (
+	// LangEN for english messages
+	LangEN = "en"
+
+	// LangZH for chinese messages
+	LangZH = "zh"
 )
 
 // GErrors for global errors mapping
@@ -80,6 +108,11 @@ var GErrors = map[int]string{
 	ErrUserAlreadyExist:  "User Already Exist",
 	ErrPasswordDontMatch: "User And Password Not Match",
 	ErrUserNotLogin:      "User Not Login",
+
+	ErrUnauthorized:    "Unauthorized Request",
+	ErrTooManyRequests: "Too Many Requests",
+	ErrRequestConflict: "Request Id Reused With Different Paras",
+	ErrCommitPending:   "Commit Confirm Pending",
 }
 
 // GErrorsCN Global error for Chinese
@@ -104,12 +137,49 @@ var GErrorsCN = map[int]string{
 	ErrUserAlreadyExist:  "用户已经存在",
 	ErrPasswordDontMatch: "用户和密码不匹配",
 	ErrUserNotLogin:      "用户未登录",
+
+	ErrUnauthorized:    "请求认证失败",
+	ErrTooManyRequests: "请求过于频繁",
+	ErrRequestConflict: "请求ID已被不同参数使用",
+	ErrCommitPending:   "配置提交等待确认",
+}
+
+// GHTTPStatus for http status of errors, 500 for errors not listed
+var GHTTPStatus = map[int]int{
+	ErrSuccess:             http.StatusOK,
+	ErrNotEnoughParas:      http.StatusBadRequest,
+	ErrTooManyParas:        http.StatusBadRequest,
+	ErrBadParas:            http.StatusBadRequest,
+	ErrSegmentNotExist:     http.StatusNotFound,
+	ErrSegmentAlreadyExist: http.StatusConflict,
+	ErrTimeout:             http.StatusGatewayTimeout,
+	ErrNoSuchAPI:           http.StatusNotFound,
+	ErrNotImplemented:      http.StatusNotImplemented,
+	ErrUserNotExist:        http.StatusNotFound,
+	ErrUserAlreadyExist:    http.StatusConflict,
+	ErrPasswordDontMatch:   http.StatusUnauthorized,
+	ErrUserNotLogin:        http.StatusUnauthorized,
+	ErrUnauthorized:        http.StatusUnauthorized,
+	ErrTooManyRequests:     http.StatusTooManyRequests,
+	ErrRequestConflict:     http.StatusUnprocessableEntity,
+	ErrCommitPending:       http.StatusConflict,
+}
+
+// HTTPStatus from errorNo
+func HTTPStatus(errorNo int) int {
+	if status, ok := GHTTPStatus[errorNo]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
 }
 
-// MError base error structure
+// MError base error structure, with detail of what went wrong and the
+// wrapped cause
 type MError struct {
 	ErrorNo  int    `json:"no"`
 	ErrorMsg string `json:"msg"`
+	Detail   string `json:"detail,omitempty"`
+	Cause    error  `json:"-"`
 }
 
 // NewError to new an error
@@ -120,6 +190,79 @@ func NewError(code int, message string) *MError {
 	}
 }
 
+// Errorf to new an error of code with formatted detail
+func Errorf(code int, format string, args ...interface{}) *MError {
+	return &MError{
+		ErrorNo:  code,
+		ErrorMsg: GetMsg(code),
+		Detail:   fmt.Sprintf(format, args...),
+	}
+}
+
+// Wrap cause into an error of code with formatted detail
+func Wrap(cause error, code int, format string, args ...interface{}) *MError {
+	err := Errorf(code, format, args...)
+	err.Cause = cause
+	return err
+}
+
+// Error message with detail and cause
+func (e *MError) Error() string {
+	if log := e.Log(); log != "" {
+		return e.ErrorMsg + ": " + log
+	}
+	return e.ErrorMsg
+}
+
+// Unwrap to get the cause
+func (e *MError) Unwrap() error {
+	return e.Cause
+}
+
+// Log of detail and cause, for errorLog of response
+func (e *MError) Log() string {
+
+	if e.Cause == nil {
+		return e.Detail
+	}
+
+	if e.Detail == "" {
+		return e.Cause.Error()
+	}
+
+	return e.Detail + ": " + e.Cause.Error()
+}
+
+// Code of err, ErrSuccess for nil, ErrCommonErr for err not MError
+func Code(err error) int {
+
+	if err == nil {
+		return ErrSuccess
+	}
+
+	var e *MError
+	if errors.As(err, &e) {
+		return e.ErrorNo
+	}
+
+	return ErrCommonErr
+}
+
+// Log of err for errorLog of response
+func Log(err error) string {
+
+	if err == nil {
+		return ""
+	}
+
+	var e *MError
+	if errors.As(err, &e) {
+		return e.Log()
+	}
+
+	return err.Error()
+}
+
 // GetMsg from errorNo
 func GetMsg(errorNo int) string {
 	return GErrors[errorNo]
@@ -129,3 +272,50 @@ func GetMsg(errorNo int) string {
 func GetMsgCN(errorNo int) string {
 	return GErrorsCN[errorNo]
 }
+
+// GetMsgLang from errorNo in language, english if not translated
+func GetMsgLang(errorNo int, lang string) string {
+	if lang == LangZH {
+		if msg, ok := GErrorsCN[errorNo]; ok {
+			return msg
+		}
+	}
+	return GetMsg(errorNo)
+}
+
+// ParseLang from Accept-Language header like "zh-CN,zh;q=0.9,en;q=0.8",
+// the supported language of highest quality, LangEN by default
+func ParseLang(header string) string {
+
+	lang := LangEN
+	best := -1.0
+
+	for _, item := range strings.Split(header, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+
+		tag := strings.ToLower(strings.TrimSpace(parts[0]))
+		if i := strings.IndexAny(tag, "-_"); i >= 0 {
+			tag = tag[:i]
+		}
+
+		if tag != LangEN && tag != LangZH {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+
+		if q > best {
+			lang, best = tag, q
+		}
+	}
+
+	return lang
+}
@@ -31,8 +31,22 @@ func (nic Nic) String() string {
 	return string(s)
 }
 
+// NicsSourceFunc for nics of the system, replaced to run off-box
+var NicsSourceFunc = readNics
+
+// NicInfoSourceFunc for ip address, netmask and broadcast of nic by name
+var NicInfoSourceFunc = showNicInfo
+
+// NicNameByIPSourceFunc for name of nic with ip address
+var NicNameByIPSourceFunc = showNicNameByIP
+
 // GetAllNics with name and mac address
 func GetAllNics() (map[string]Nic, error) {
+	return NicsSourceFunc()
+}
+
+// readNics of /sys/class/net
+func readNics() (map[string]Nic, error) {
 	const ROOT = "/sys/class/net"
 
 	files, err := ioutil.ReadDir(ROOT)
@@ -119,6 +133,11 @@ func GetNicInfoByMac(mac string) (string, string, string, error) {
 
 // GetNicInfo get ip address,netmask and network by nic name
 func GetNicInfo(nicname string) (string, string, string, error) {
+	return NicInfoSourceFunc(nicname)
+}
+
+// showNicInfo by ip addr show
+func showNicInfo(nicname string) (string, string, string, error) {
 	bash := Bash{
 		Command: fmt.Sprintf("ip addr show %s | grep -w inet", nicname),
 	}
@@ -140,6 +159,11 @@ func GetNicInfo(nicname string) (string, string, string, error) {
 
 // GetNicNameByIP get nic name by ip address
 func GetNicNameByIP(ip string) (string, error) {
+	return NicNameByIPSourceFunc(ip)
+}
+
+// showNicNameByIP by ip addr
+func showNicNameByIP(ip string) (string, error) {
 	bash := Bash{
 		Command: fmt.Sprintf("ip addr | grep -w %s", ip),
 	}
@@ -0,0 +1,141 @@
+package vyos
+
+import (
+	"fmt"
+	"io/ioutil"
+	"octlink/ovs/utils"
+	"strings"
+	"sync"
+)
+
+// Backend of vyos configuration, the running configuration read, and
+// batches of $SET and $DELETE commands applied and committed
+type Backend interface {
+	// ShowConfiguration of running configuration in config.boot syntax
+	ShowConfiguration() (string, error)
+
+	// Apply batch of commands to the candidate configuration
+	Apply(commands []string) error
+
+	// Commit candidate configuration, discarded if failed
+	Commit(asVyosUser bool) error
+}
+
+const (
+	// BackendVyos of vyos cli
+	BackendVyos = "vyos"
+
+	// BackendMemory of configuration in memory
+	BackendMemory = "memory"
+)
+
+// GBackend for global vyos configuration, vyos cli by default
+var GBackend Backend = NewShellBackend()
+
+// InitBackend of type, vyos cli if empty. The memory backend starts with
+// configuration of config file, or empty if not set.
+func InitBackend(backend string, config string) error {
+
+	switch backend {
+	case "", BackendVyos:
+		GBackend = NewShellBackend()
+
+	case BackendMemory:
+		var text []byte
+		if config != "" {
+			var err error
+			if text, err = ioutil.ReadFile(config); err != nil {
+				return err
+			}
+		}
+		GBackend = NewMemoryBackend(string(text))
+
+	default:
+		return fmt.Errorf("unknown backend %s", backend)
+	}
+
+	ResetRuleAllocator()
+
+	return nil
+}
+
+// ShellBackend of vyos cli, by cli-shell-api and the my_set and my_commit
+// scripts of vyatta
+type ShellBackend struct {
+	lock     sync.Mutex
+	commands []string
+}
+
+// NewShellBackend of vyos cli
+func NewShellBackend() *ShellBackend {
+	return &ShellBackend{}
+}
+
+// ShowConfiguration by cli-shell-api
+func (b *ShellBackend) ShowConfiguration() (string, error) {
+
+	bash := utils.Bash{
+		Command: "/bin/cli-shell-api showCfg",
+		NoLog:   true,
+	}
+
+	ret, o, e, err := bash.RunWithReturn()
+	if err != nil {
+		return "", err
+	}
+
+	if ret != 0 {
+		return "", &utils.BashError{
+			Command: bash.Command,
+			RetCode: ret,
+			Stdout:  o,
+			Stderr:  e,
+		}
+	}
+
+	return o, nil
+}
+
+// Apply commands, run with the commit in one vyos session
+func (b *ShellBackend) Apply(commands []string) error {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.commands = append(b.commands, commands...)
+
+	return nil
+}
+
+// Commit commands applied by vyos script
+func (b *ShellBackend) Commit(asVyosUser bool) (err error) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	commands := b.commands
+	b.commands = nil
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	// shell failures returned as they are, for the failing command
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	if asVyosUser {
+		RunVyosScriptAsUserVyos(strings.Join(commands, "\n"))
+	} else {
+		RunVyosScript(strings.Join(commands, "\n"), nil)
+	}
+
+	return nil
+}
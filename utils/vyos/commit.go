@@ -0,0 +1,261 @@
+package vyos
+
+import (
+	"errors"
+	"fmt"
+	"octlink/ovs/utils"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// CommitLockFile for cross process commit lock, shared by ovs and ovsboot
+var CommitLockFile = "/home/vyos/rvm/commit.lock"
+
+var (
+	// ErrConfirmPending for commit while changes of a commit confirm are
+	// neither confirmed nor reverted
+	ErrConfirmPending = errors.New("commit confirm pending")
+
+	// ErrNoConfirmPending for confirm without commit confirm pending
+	ErrNoConfirmPending = errors.New("no commit confirm pending")
+)
+
+// PendingConfirm of commit reverted at deadline unless confirmed
+type PendingConfirm struct {
+	Commands []string  `json:"commands"`
+	Deadline time.Time `json:"deadline"`
+
+	snapshot   string
+	asVyosUser bool
+	timer      *time.Timer
+	tree       *ConfigTree
+}
+
+// CommitManager serializes read-modify-apply cycles of vyos configuration
+type CommitManager struct {
+	lock     sync.Mutex
+	lockFile *os.File
+
+	// commit confirm waiting, guarded by lock
+	pending *PendingConfirm
+
+	// Snapshots to save running configuration before each commit, nil for
+	// no snapshots
+	Snapshots *SnapshotStore
+}
+
+// GCommitManager for global commit management
+var GCommitManager = &CommitManager{}
+
+// Lock commit manager, both in process and across processes
+func (m *CommitManager) Lock() {
+
+	m.lock.Lock()
+
+	if err := utils.MkdirForFile(CommitLockFile, 0755); err != nil {
+		logger.Warnf("create dir for commit lock file %s error %s\n", CommitLockFile, err)
+		return
+	}
+
+	fd, err := os.OpenFile(CommitLockFile, os.O_CREATE|os.O_RDWR, 0666)
+	if err != nil {
+		logger.Warnf("open commit lock file %s error %s\n", CommitLockFile, err)
+		return
+	}
+
+	if err := syscall.Flock(int(fd.Fd()), syscall.LOCK_EX); err != nil {
+		logger.Warnf("lock commit lock file %s error %s\n", CommitLockFile, err)
+		fd.Close()
+		return
+	}
+
+	m.lockFile = fd
+}
+
+// Unlock commit manager
+func (m *CommitManager) Unlock() {
+
+	if m.lockFile != nil {
+		syscall.Flock(int(m.lockFile.Fd()), syscall.LOCK_UN)
+		m.lockFile.Close()
+		m.lockFile = nil
+	}
+
+	m.lock.Unlock()
+}
+
+// Exclusive run fn with commit manager locked
+func (m *CommitManager) Exclusive(fn func()) {
+	m.Lock()
+	defer m.Unlock()
+	fn()
+}
+
+// Commit parse the running configuration, let fn modify the tree, and apply
+// the changes if fn returns true. The whole cycle holds the commit lock.
+// The running configuration is rolled back if the changes fail to commit.
+func (m *CommitManager) Commit(asVyosUser bool, fn func(tree *ConfigTree) bool) (*ConfigTree, error) {
+	return m.commit(asVyosUser, 0, fn)
+}
+
+// CommitConfirm as Commit, but the changes are reverted after timeout
+// unless confirmed by Confirm, as commit-confirm of vyos cli. Hooks of the
+// tree run once confirmed, and its revert hooks once reverted.
+func (m *CommitManager) CommitConfirm(asVyosUser bool, timeout time.Duration, fn func(tree *ConfigTree) bool) (*ConfigTree, error) {
+	return m.commit(asVyosUser, timeout, fn)
+}
+
+func (m *CommitManager) commit(asVyosUser bool, timeout time.Duration, fn func(tree *ConfigTree) bool) (*ConfigTree, error) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.pending != nil {
+		return nil, ErrConfirmPending
+	}
+
+	snapshot := ShowConfiguration()
+	tree := NewParserFromConfiguration(snapshot).Tree
+	if !fn(tree) {
+		logger.Debugf("[Vyos Configuration] changes dropped\n")
+		return tree, nil
+	}
+
+	if m.Snapshots != nil && tree.HasChanges() {
+		if saved, err := m.Snapshots.SaveAuto(snapshot); err != nil {
+			logger.Warnf("[Vyos Configuration] snapshot before commit error %s\n", err)
+		} else {
+			logger.Debugf("[Vyos Configuration] snapshot %s saved before commit\n", saved.Name)
+		}
+	}
+
+	m.apply(tree, asVyosUser, snapshot)
+
+	if timeout > 0 && tree.HasChanges() {
+		pending := &PendingConfirm{
+			Commands:   tree.Commands(),
+			Deadline:   time.Now().Add(timeout),
+			snapshot:   snapshot,
+			asVyosUser: asVyosUser,
+			tree:       tree,
+		}
+		pending.timer = time.AfterFunc(timeout, func() {
+			m.expire(pending)
+		})
+		m.pending = pending
+
+		logger.Infof("[Vyos Configuration] changes will be reverted at %s unless confirmed\n",
+			pending.Deadline.Format(time.RFC3339))
+	}
+
+	return tree, nil
+}
+
+// apply changes of tree, rolled back to snapshot if failed
+func (m *CommitManager) apply(tree *ConfigTree, asVyosUser bool, snapshot string) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("[Vyos Configuration] commit error %v, rolling back\n", r)
+			if err := Rollback(snapshot, asVyosUser); err != nil {
+				logger.Errorf("[Vyos Configuration] rollback error %s\n", err)
+				if e, ok := r.(error); ok {
+					panic(fmt.Errorf("%w, and rollback error %s", e, err))
+				}
+				panic(fmt.Errorf("%v, and rollback error %s", r, err))
+			}
+			panic(r)
+		}
+	}()
+
+	tree.Apply(asVyosUser)
+}
+
+// expire pending confirm by reverting its changes
+func (m *CommitManager) expire(pending *PendingConfirm) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.pending != pending {
+		return
+	}
+	m.pending = nil
+
+	logger.Warnf("[Vyos Configuration] commit not confirmed before %s, reverting\n",
+		pending.Deadline.Format(time.RFC3339))
+
+	if err := Rollback(pending.snapshot, pending.asVyosUser); err != nil {
+		logger.Errorf("[Vyos Configuration] revert unconfirmed commit error %s, changes kept\n", err)
+		pending.tree.Committed()
+		return
+	}
+
+	pending.tree.Reverted()
+}
+
+// Confirm changes of pending commit confirm, hooks of its tree run
+func (m *CommitManager) Confirm() (*PendingConfirm, error) {
+
+	m.Lock()
+	defer m.Unlock()
+
+	pending := m.pending
+	if pending == nil {
+		return nil, ErrNoConfirmPending
+	}
+
+	pending.timer.Stop()
+	m.pending = nil
+
+	logger.Infof("[Vyos Configuration] commit confirmed\n")
+
+	pending.tree.Committed()
+
+	return pending, nil
+}
+
+// PendingConfirm as Pending, but ErrNoConfirmPending if none
+func (m *CommitManager) PendingConfirm() (*PendingConfirm, error) {
+
+	if pending := m.Pending(); pending != nil {
+		return pending, nil
+	}
+
+	return nil, ErrNoConfirmPending
+}
+
+// Pending commit confirm, nil if none
+func (m *CommitManager) Pending() *PendingConfirm {
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.pending
+}
+
+// RollbackCommands to turn running configuration back to snapshot
+func RollbackCommands(running, snapshot string) []string {
+	return Diff(NewParserFromConfiguration(running).Tree.Root,
+		NewParserFromConfiguration(snapshot).Tree.Root)
+}
+
+// Rollback running configuration to snapshot
+func Rollback(snapshot string, asVyosUser bool) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	tree := &ConfigTree{changeCommands: RollbackCommands(ShowConfiguration(), snapshot)}
+	tree.Apply(asVyosUser)
+
+	// rules of the rolled back changes are free again
+	ResetRuleAllocator()
+
+	return nil
+}
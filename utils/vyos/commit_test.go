@@ -0,0 +1,88 @@
+package vyos
+
+import (
+	"io/ioutil"
+	"octlink/ovs/utils/configuration"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCommitConfirm(t *testing.T) {
+
+	UnitTest = true
+
+	dir, _ := ioutil.TempDir("", "ovs-commit")
+	defer os.RemoveAll(dir)
+
+	configuration.Conf.LogDirectory = dir
+	InitLog(0)
+	CommitLockFile = filepath.Join(dir, "commit.lock")
+
+	ConfigurationSourceFunc = func() string {
+		return "service {\n    ssh {\n        port 22\n    }\n}\n"
+	}
+
+	m := &CommitManager{}
+	hooks := make(chan string, 10)
+	setPort := func(tree *ConfigTree) bool {
+		tree.OnCommit(func() { hooks <- "committed" })
+		tree.OnRevert(func() { hooks <- "reverted" })
+		return tree.Set("service ssh port 2222")
+	}
+
+	if _, err := m.CommitConfirm(false, time.Minute, setPort); err != nil || m.Pending() == nil {
+		t.Fatalf("commit confirm should be pending, %v", err)
+	}
+	if len(hooks) != 0 {
+		t.Fatalf("hooks should not run before confirmed, %s got", <-hooks)
+	}
+
+	if _, err := m.Commit(false, setPort); err != ErrConfirmPending {
+		t.Fatalf("commit should be rejected while confirm pending, %v got", err)
+	}
+
+	if pending, err := m.Confirm(); err != nil || len(pending.Commands) != 2 {
+		t.Fatalf("pending commit should be confirmed, %v %v", pending, err)
+	}
+	if hook := <-hooks; hook != "committed" || len(hooks) != 0 {
+		t.Fatalf("commit hooks should run once confirmed, %s got", hook)
+	}
+
+	if _, err := m.Confirm(); err != ErrNoConfirmPending {
+		t.Fatalf("confirm without pending commit should fail, %v got", err)
+	}
+
+	if _, err := m.CommitConfirm(false, 10*time.Millisecond, setPort); err != nil {
+		t.Fatalf("commit confirm error %s", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if m.Pending() != nil {
+		t.Fatalf("unconfirmed commit should be reverted after timeout")
+	}
+	if hook := <-hooks; hook != "reverted" || len(hooks) != 0 {
+		t.Fatalf("revert hooks should run once reverted, %s got", hook)
+	}
+
+	if _, err := m.Commit(false, setPort); err != nil {
+		t.Fatalf("commit should be allowed after revert, %v got", err)
+	}
+}
+
+func TestRollbackCommands(t *testing.T) {
+
+	snapshot := "service {\n    ssh {\n        port 22\n    }\n}\n"
+	running := "service {\n    ssh {\n        port 2222\n    }\n    dns {\n        forwarding {\n            listen-on eth1\n        }\n    }\n}\n"
+
+	want := []string{
+		"$DELETE service ssh port 2222",
+		"$DELETE service dns",
+		"$SET service ssh port 22",
+	}
+	if commands := RollbackCommands(running, snapshot); !reflect.DeepEqual(commands, want) {
+		t.Fatalf("rollback should undo changes since snapshot, %v got", commands)
+	}
+}
@@ -0,0 +1,421 @@
+package vyos
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleContainer is the name of nodes holding numbered rules, like
+// "nat destination rule" or "firewall name eth0.in rule"
+const RuleContainer = "rule"
+
+// MaxRuleNumber of nat and firewall rules
+const MaxRuleNumber = 9999
+
+// Name of config node
+func (n *ConfigNode) Name() string {
+	return n.name
+}
+
+// isLeaf if node has no children, a value or a valueless key
+func (n *ConfigNode) isLeaf() bool {
+	return len(n.children) == 0
+}
+
+// isRule if node is a numbered rule
+func (n *ConfigNode) isRule() bool {
+	if n.parent == nil || n.parent.name != RuleContainer {
+		return false
+	}
+	_, err := strconv.Atoi(n.name)
+	return err == nil
+}
+
+// joinPath of prefix and name, name quoted if needed
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return quoteWord(name)
+	}
+	return prefix + " " + quoteWord(name)
+}
+
+// leaves of n as paths relative to n, in tree order
+func (n *ConfigNode) leaves() []string {
+
+	paths := make([]string, 0)
+
+	var walk func(node *ConfigNode, prefix string)
+	walk = func(node *ConfigNode, prefix string) {
+		for _, c := range node.children {
+			path := joinPath(prefix, c.name)
+			if c.isLeaf() {
+				paths = append(paths, path)
+			} else {
+				walk(c, path)
+			}
+		}
+	}
+
+	walk(n, "")
+
+	return paths
+}
+
+// diffNodes of running to desired as paths relative to them, a node not
+// desired is deleted as a whole, a desired node not running is set by its
+// leaves. Values of multi-value leaves are compared one by one.
+func diffNodes(running, desired *ConfigNode, prefix string) ([]string, []string) {
+
+	deletes := make([]string, 0)
+	sets := make([]string, 0)
+
+	if running != nil {
+		for _, rc := range running.children {
+			path := joinPath(prefix, rc.name)
+
+			var dc *ConfigNode
+			if desired != nil {
+				dc = desired.getNode(rc.name)
+			}
+
+			if dc == nil {
+				deletes = append(deletes, path)
+				continue
+			}
+
+			ds, ss := diffNodes(rc, dc, path)
+			deletes = append(deletes, ds...)
+			sets = append(sets, ss...)
+		}
+	}
+
+	if desired != nil {
+		for _, dc := range desired.children {
+			if running != nil && running.getNode(dc.name) != nil {
+				continue
+			}
+
+			path := joinPath(prefix, dc.name)
+			if dc.isLeaf() {
+				sets = append(sets, path)
+				continue
+			}
+
+			for _, leaf := range dc.leaves() {
+				sets = append(sets, path+" "+leaf)
+			}
+		}
+	}
+
+	return deletes, sets
+}
+
+// Diff of running node to desired node at the same path, the minimal
+// commands to converge, deletes first. Either node may be nil.
+func Diff(running, desired *ConfigNode) []string {
+
+	var prefix string
+	if running != nil {
+		prefix = running.String()
+	} else if desired != nil {
+		prefix = desired.String()
+	}
+
+	deletes, sets := diffNodes(running, desired, prefix)
+
+	commands := make([]string, 0, len(deletes)+len(sets))
+	for _, d := range deletes {
+		commands = append(commands, fmt.Sprintf("$DELETE %s", d))
+	}
+	for _, s := range sets {
+		commands = append(commands, fmt.Sprintf("$SET %s", s))
+	}
+
+	return commands
+}
+
+// setLeaf set config path, other values of a multi-value leaf kept
+func (t *ConfigTree) setLeaf(config string) bool {
+
+	t.init()
+
+	words := splitPath(config)
+	added := t.Root.addPath(words)
+	if added {
+		t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", formatPath(words)))
+	}
+
+	return added
+}
+
+// setValue of leaf at key, replacing the values of the leaf if replace, or
+// added to the values of the leaf
+func (t *ConfigTree) setValue(key []string, value string, replace bool) bool {
+
+	t.init()
+
+	words := append(append([]string{}, key...), value)
+
+	if n := t.Root.getPath(key); replace && n != nil && n.ValueSize() > 0 {
+		if n.ValueSize() == 1 && n.Value() == value {
+			return false
+		}
+		for _, old := range n.Values() {
+			n.deleteNode(old)
+		}
+		n.addNode(value)
+		t.changeCommands = append(t.changeCommands, fmt.Sprintf("$DELETE %s", formatPath(key)))
+		t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", formatPath(words)))
+		return true
+	}
+
+	if !t.Root.addPath(words) {
+		return false
+	}
+	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", formatPath(words)))
+
+	return true
+}
+
+// Addf set config path, other values of a multi-value leaf kept, unlike
+// Setf replacing the value
+func (t *ConfigTree) Addf(f string, args ...interface{}) bool {
+	if args != nil {
+		return t.setLeaf(fmt.Sprintf(f, args...))
+	}
+	return t.setLeaf(f)
+}
+
+// deleteAndPrune delete config path, and parents left empty up to stop
+// as vyos does
+func (t *ConfigTree) deleteAndPrune(config string, stop *ConfigNode) {
+
+	n := t.Get(config)
+	if n == nil {
+		return
+	}
+
+	parent := n.parent
+	t.Delete(config)
+
+	for parent != nil && parent != stop && parent != t.Root && len(parent.children) == 0 {
+		parent.deleteSelf()
+		parent = parent.parent
+	}
+}
+
+// converge rule to desired rule of the same content kind in place
+func (t *ConfigTree) converge(rule, desired *ConfigNode) {
+
+	prefix := rule.String()
+	deletes, sets := diffNodes(rule, desired, prefix)
+
+	for _, d := range deletes {
+		t.deleteAndPrune(d, rule)
+	}
+	for _, s := range sets {
+		t.setLeaf(s)
+	}
+}
+
+// ruleKey of rule to pair running and desired rules with changed content,
+// the description, or the rule number if no description
+func ruleKey(rule *ConfigNode) string {
+	if d := rule.getNode("description"); d != nil && len(d.Values()) == 1 {
+		return "description " + d.Values()[0]
+	}
+	return "number " + rule.name
+}
+
+// ruleContent of rule, leaves sorted
+func ruleContent(rule *ConfigNode) string {
+	leaves := rule.leaves()
+	sort.Strings(leaves)
+	return strings.Join(leaves, "\n")
+}
+
+// ruleContainers of both trees as paths, in tree order
+func ruleContainers(trees ...*ConfigTree) []string {
+
+	paths := make([]string, 0)
+	seen := make(map[string]bool)
+
+	var walk func(node *ConfigNode)
+	walk = func(node *ConfigNode) {
+		for _, c := range node.children {
+			if c.isRule() {
+				path := node.String()
+				if !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
+				return
+			}
+			walk(c)
+		}
+	}
+
+	for _, tree := range trees {
+		if tree.Root != nil {
+			walk(tree.Root)
+		}
+	}
+
+	return paths
+}
+
+// syncRules of container at path to desired rules. Running rules owned but
+// not desired are deleted, a running rule of same content keeps its number,
+// one of same description is converged in place, and the others are added
+// with the desired number if free, or the lowest free number.
+func (t *ConfigTree) syncRules(path string, desired *ConfigTree, owns func(rule *ConfigNode) bool) {
+
+	var wanted []*ConfigNode
+	if dc := desired.Get(path); dc != nil {
+		wanted = dc.children
+	}
+
+	owned := make([]*ConfigNode, 0)
+	if rc := t.Get(path); rc != nil {
+		for _, r := range rc.children {
+			if r.isRule() && (owns == nil || owns(r)) {
+				owned = append(owned, r)
+			}
+		}
+	}
+
+	kept := make(map[*ConfigNode]bool)
+	paired := make(map[*ConfigNode]*ConfigNode)
+
+	// same content, nothing to do
+	for _, w := range wanted {
+		content := ruleContent(w)
+		for _, r := range owned {
+			if !kept[r] && ruleContent(r) == content {
+				kept[r] = true
+				paired[w] = r
+				break
+			}
+		}
+	}
+
+	// same description, converged in place
+	changed := make(map[*ConfigNode]*ConfigNode)
+	for _, w := range wanted {
+		if paired[w] != nil {
+			continue
+		}
+		key := ruleKey(w)
+		for _, r := range owned {
+			if !kept[r] && ruleKey(r) == key {
+				kept[r] = true
+				paired[w] = r
+				changed[w] = r
+				break
+			}
+		}
+	}
+
+	for _, r := range owned {
+		if !kept[r] {
+			t.Delete(r.String())
+		}
+	}
+
+	for _, w := range wanted {
+		if r, ok := changed[w]; ok {
+			t.converge(r, w)
+		}
+	}
+
+	for _, w := range wanted {
+		if paired[w] != nil {
+			continue
+		}
+
+		number := t.freeRuleNumber(path, w.name)
+		for _, leaf := range w.leaves() {
+			t.setLeaf(fmt.Sprintf("%s %d %s", path, number, leaf))
+		}
+	}
+}
+
+// freeRuleNumber in container at path, preferred one if free, or one of the
+// rule range of preferred
+func (t *ConfigTree) freeRuleNumber(path string, preferred string) int {
+
+	n, err := strconv.Atoi(preferred)
+	if err == nil && t.Getf("%s %d", path, n) == nil {
+		return n
+	}
+
+	if r := ruleRangeOf(ruleChain(path), n); r != nil {
+		if number, err := t.AllocateRule(path, r.Feature); err == nil {
+			return number
+		}
+	}
+
+	for i := 1; i <= MaxRuleNumber; i++ {
+		if t.Getf("%s %d", path, i) == nil {
+			return i
+		}
+	}
+
+	panic(fmt.Sprintf("No rule number available for %s. You have set more than %d rules???", path, MaxRuleNumber))
+}
+
+// Sync running tree to desired tree. Numbered rules owned, by owns or all if
+// owns is nil, are converged to the desired rules of the same container
+// keeping rule numbers where possible, and other desired leaves are set.
+// Nothing is changed if the running tree already matches.
+func (t *ConfigTree) Sync(desired *ConfigTree, owns func(rule *ConfigNode) bool) {
+
+	t.init()
+	desired.init()
+
+	containers := ruleContainers(t, desired)
+	isContainer := make(map[string]bool)
+	for _, path := range containers {
+		isContainer[path] = true
+		t.syncRules(path, desired, owns)
+	}
+
+	var walk func(node *ConfigNode, prefix string)
+	walk = func(node *ConfigNode, prefix string) {
+		if isContainer[prefix] {
+			return
+		}
+		for _, c := range node.children {
+			path := joinPath(prefix, c.name)
+			if c.isLeaf() {
+				// replaced if single-value by schema, never decided by
+				// how many values are running
+				key := splitPath(prefix)
+				t.setValue(key, c.name, node.isKeyNode() && !isMultiValue(key))
+			} else {
+				walk(c, path)
+			}
+		}
+	}
+
+	walk(desired.Root, "")
+}
+
+// Converge running tree to desired tree as a whole, with the minimal
+// commands of Diff
+func (t *ConfigTree) Converge(desired *ConfigTree) {
+
+	t.init()
+	desired.init()
+
+	deletes, sets := diffNodes(t.Root, desired.Root, "")
+
+	for _, d := range deletes {
+		t.deleteAndPrune(d, t.Root)
+	}
+	for _, s := range sets {
+		t.setLeaf(s)
+	}
+}
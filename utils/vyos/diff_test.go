@@ -0,0 +1,194 @@
+package vyos
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const diffRunning = `
+nat {
+    destination {
+        rule 1 {
+            description user
+            destination {
+                address 10.0.0.1
+            }
+        }
+        rule 2 {
+            description 192.168.1.10-80-80-fa:16:3e:00:00:01-8080-8080-TCP
+            destination {
+                address 192.168.1.10
+                port 80
+            }
+            translation {
+                address 10.0.0.2
+            }
+        }
+        rule 3 {
+            description 192.168.1.10-22-22-fa:16:3e:00:00:01-22-22-TCP
+            destination {
+                address 192.168.1.10
+                port 22
+            }
+            translation {
+                address 10.0.0.2
+            }
+        }
+        rule 4 {
+            description 192.168.1.10-53-53-fa:16:3e:00:00:01-53-53-UDP
+            destination {
+                address 192.168.1.10
+                port 53
+            }
+            translation {
+                address 10.0.0.3
+            }
+        }
+    }
+}
+service {
+    dns {
+        forwarding {
+            listen-on eth1
+            listen-on eth2
+        }
+    }
+}
+`
+
+func isTestDnat(rule *ConfigNode) bool {
+	d := rule.Get("description")
+	return d != nil && (strings.HasSuffix(d.Value(), "TCP") || strings.HasSuffix(d.Value(), "UDP"))
+}
+
+func TestDiff(t *testing.T) {
+
+	running := NewParserFromConfiguration(diffRunning).Tree
+
+	desired := &ConfigTree{}
+	desired.Set("service dns forwarding listen-on eth1")
+	desired.setLeaf("service dns forwarding listen-on eth3")
+
+	commands := Diff(running.Get("service dns"), desired.Get("service dns"))
+	want := []string{
+		"$DELETE service dns forwarding listen-on eth2",
+		"$SET service dns forwarding listen-on eth3",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("multi-value leaf should be diffed by value, %v got", commands)
+	}
+
+	if commands := Diff(running.Get("service dns"), running.Get("service dns")); len(commands) != 0 {
+		t.Fatalf("same nodes should have no diff, %v got", commands)
+	}
+
+	commands = Diff(nil, running.Get("nat destination rule 1"))
+	want = []string{
+		"$SET nat destination rule 1 description user",
+		"$SET nat destination rule 1 destination address 10.0.0.1",
+	}
+	if !reflect.DeepEqual(commands, want) {
+		t.Fatalf("new node should be set by leaves, %v got", commands)
+	}
+}
+
+func TestSyncRules(t *testing.T) {
+
+	running := NewParserFromConfiguration(diffRunning).Tree
+
+	desired := &ConfigTree{}
+	// unchanged, numbers in desired tree are placeholders
+	desired.SetDnat(
+		"description 192.168.1.10-80-80-fa:16:3e:00:00:01-8080-8080-TCP",
+		"destination address 192.168.1.10",
+		"destination port 80",
+		"translation address 10.0.0.2",
+	)
+	// translation changed
+	desired.SetDnat(
+		"description 192.168.1.10-53-53-fa:16:3e:00:00:01-53-53-UDP",
+		"destination address 192.168.1.10",
+		"destination port 53",
+		"translation address 10.0.0.4",
+	)
+	// new
+	desired.SetDnat(
+		"description 192.168.1.10-443-443-fa:16:3e:00:00:01-443-443-TCP",
+		"destination address 192.168.1.10",
+		"destination port 443",
+		"translation address 10.0.0.2",
+	)
+
+	running.Sync(desired, isTestDnat)
+
+	want := []string{
+		"$DELETE nat destination rule 3",
+		"$DELETE nat destination rule 4 translation address 10.0.0.3",
+		"$SET nat destination rule 4 translation address 10.0.0.4",
+		"$SET nat destination rule 3 description 192.168.1.10-443-443-fa:16:3e:00:00:01-443-443-TCP",
+		"$SET nat destination rule 3 destination address 192.168.1.10",
+		"$SET nat destination rule 3 destination port 443",
+		"$SET nat destination rule 3 translation address 10.0.0.2",
+	}
+	if !reflect.DeepEqual(running.Commands(), want) {
+		t.Fatalf("rules should be converged keeping numbers, %v got", running.Commands())
+	}
+
+	if running.Get("nat destination rule 1") == nil {
+		t.Fatalf("rule not owned should be kept")
+	}
+
+	// converged, nothing more to do
+	again := NewParserFromConfiguration(diffRunning).Tree
+	again.Sync(desired, isTestDnat)
+	again.changeCommands = nil
+	again.Sync(desired, isTestDnat)
+	if again.HasChanges() {
+		t.Fatalf("converged tree should have no changes, %v got", again.Commands())
+	}
+}
+
+func TestSyncMultiValue(t *testing.T) {
+
+	running := NewParserFromConfiguration(`
+interfaces {
+    ethernet eth0 {
+        address 10.0.0.1/24
+        address 10.0.0.2/24
+    }
+    ethernet eth1 {
+        address 10.1.0.1/24
+    }
+}
+service {
+    ssh {
+        port 22
+    }
+}
+`).Tree
+
+	desired := &ConfigTree{}
+	desired.Set("interfaces ethernet eth0 address 10.0.0.3/24")
+	desired.Set("interfaces ethernet eth1 address 10.1.0.2/24")
+	desired.Set("service ssh port 2222")
+
+	running.Sync(desired, isTestDnat)
+
+	want := []string{
+		"$SET interfaces ethernet eth0 address 10.0.0.3/24",
+		"$SET interfaces ethernet eth1 address 10.1.0.2/24",
+		"$DELETE service ssh port",
+		"$SET service ssh port 2222",
+	}
+	if !reflect.DeepEqual(running.Commands(), want) {
+		t.Fatalf("value should be added to multi-value leaf, %v got", running.Commands())
+	}
+
+	if addresses := running.Get("interfaces ethernet eth0 address").Values(); len(addresses) != 3 {
+		t.Fatalf("addresses should be kept, %v got", addresses)
+	}
+	if addresses := running.Get("interfaces ethernet eth1 address").Values(); len(addresses) != 2 {
+		t.Fatalf("the only address should be kept, %v got", addresses)
+	}
+}
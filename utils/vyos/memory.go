@@ -0,0 +1,151 @@
+package vyos
+
+import (
+	"fmt"
+	"sync"
+)
+
+const (
+	setCommand    = "$SET"
+	deleteCommand = "$DELETE"
+)
+
+// MemoryBackend of vyos configuration in memory, for ovs to run off-box.
+// Commands are applied to a candidate copy of the running tree, which
+// replaces the running tree on commit.
+type MemoryBackend struct {
+	lock      sync.Mutex
+	running   *ConfigTree
+	candidate *ConfigTree
+
+	// CommitError to fail the next commit with, to simulate commit failures
+	CommitError error
+
+	// Commits succeeded
+	Commits int
+}
+
+// NewMemoryBackend with running configuration in config.boot syntax
+func NewMemoryBackend(config string) *MemoryBackend {
+	return &MemoryBackend{
+		running: NewParserFromConfiguration(config).Tree,
+	}
+}
+
+// clone of n with its children under parent of tree
+func (n *ConfigNode) clone(parent *ConfigNode, tree *ConfigTree) *ConfigNode {
+
+	c := &ConfigNode{
+		name:   n.name,
+		parent: parent,
+		tree:   tree,
+		tag:    n.tag,
+	}
+
+	if n.children != nil {
+		c.children = make([]*ConfigNode, 0, len(n.children))
+		c.childrenIndex = make(map[string]*ConfigNode, len(n.children))
+		for _, child := range n.children {
+			cc := child.clone(c, tree)
+			c.children = append(c.children, cc)
+			c.childrenIndex[cc.name] = cc
+		}
+	}
+
+	return c
+}
+
+// clone of tree without change commands
+func (t *ConfigTree) clone() *ConfigTree {
+	tree := &ConfigTree{}
+	tree.init()
+	tree.Root = t.Root.clone(nil, tree)
+	return tree
+}
+
+// ShowConfiguration of running tree
+func (b *MemoryBackend) ShowConfiguration() (string, error) {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return b.running.Config(), nil
+}
+
+// Apply commands to candidate tree, $SET adds the path, $DELETE removes the
+// node with parents left empty, and deleting a node not existing fails as
+// vyos does. The candidate is discarded if any command fails.
+func (b *MemoryBackend) Apply(commands []string) error {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.candidate == nil {
+		b.candidate = b.running.clone()
+	}
+
+	for _, command := range commands {
+		if err := b.candidate.applyCommand(command); err != nil {
+			b.candidate = nil
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyCommand of $SET or $DELETE to tree
+func (t *ConfigTree) applyCommand(command string) error {
+
+	words := splitPath(command)
+	if len(words) < 2 {
+		return fmt.Errorf("bad command [%s]", command)
+	}
+
+	switch words[0] {
+	case setCommand:
+		t.Root.addPath(words[1:])
+
+	case deleteCommand:
+		n := t.Root.getPath(words[1:])
+		if n == nil {
+			return fmt.Errorf("nothing to delete, the specified node [%s] does not exist", formatPath(words[1:]))
+		}
+
+		parent := n.parent
+		n.deleteSelf()
+		for parent != t.Root && len(parent.children) == 0 {
+			parent.deleteSelf()
+			parent = parent.parent
+		}
+
+	default:
+		return fmt.Errorf("unknown command [%s]", command)
+	}
+
+	return nil
+}
+
+// Commit candidate tree as running tree
+func (b *MemoryBackend) Commit(asVyosUser bool) error {
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	candidate := b.candidate
+	b.candidate = nil
+
+	if candidate == nil {
+		return nil
+	}
+
+	if err := b.CommitError; err != nil {
+		b.CommitError = nil
+		return err
+	}
+
+	b.running = candidate
+	b.Commits++
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package vyos
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMemoryBackend(t *testing.T) {
+
+	b := NewMemoryBackend(diffRunning)
+
+	err := b.Apply([]string{
+		"$DELETE nat destination rule 1",
+		"$DELETE nat destination rule 2",
+		"$DELETE nat destination rule 3",
+		"$DELETE nat destination rule 4",
+		"$SET service dns forwarding listen-on eth3",
+		"$SET system host-name \"my router\"",
+	})
+	if err != nil {
+		t.Fatalf("apply error %s", err)
+	}
+
+	if config, _ := b.ShowConfiguration(); config != NewParserFromConfiguration(diffRunning).Tree.Config() {
+		t.Fatalf("running configuration should not change before commit, %s got", config)
+	}
+
+	if err := b.Commit(false); err != nil {
+		t.Fatalf("commit error %s", err)
+	}
+
+	config, _ := b.ShowConfiguration()
+	running := NewParserFromConfiguration(config).Tree
+	if running.Get("nat") != nil {
+		t.Fatalf("parents left empty should be deleted, %s got", config)
+	}
+	if len(running.Get("service dns forwarding listen-on").Values()) != 3 {
+		t.Fatalf("set should add value of multi-value leaf, %s got", config)
+	}
+	if running.Get("system host-name").Value() != "my router" {
+		t.Fatalf("quoted value should be set, %s got", config)
+	}
+
+	if err := b.Apply([]string{"$DELETE nat"}); err == nil {
+		t.Fatalf("delete of node not existing should fail")
+	}
+
+	b.CommitError = errors.New("commit failed")
+	b.Apply([]string{"$SET service ssh port 22"})
+	if err := b.Commit(false); err == nil {
+		t.Fatalf("commit should fail")
+	}
+	if after, _ := b.ShowConfiguration(); after != config || b.Commits != 1 {
+		t.Fatalf("failed commit should change nothing, %s got", after)
+	}
+}
@@ -0,0 +1,331 @@
+package vyos
+
+import (
+	"fmt"
+	"octlink/ovs/utils"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// ChainFirewall of rules in "firewall name <name> rule"
+	ChainFirewall = "firewall"
+
+	// ChainDnat of rules in "nat destination rule"
+	ChainDnat = "nat destination"
+
+	// ChainSnat of rules in "nat source rule"
+	ChainSnat = "nat source"
+)
+
+const (
+	// DnatRules container of destination nat rules
+	DnatRules = ChainDnat + " " + RuleContainer
+
+	// SnatRules container of source nat rules
+	SnatRules = ChainSnat + " " + RuleContainer
+)
+
+const (
+	// RuleSystem for rules of the router itself, like default nic rules
+	RuleSystem = "system"
+
+	// RuleEip for rules of eips
+	RuleEip = "eip"
+
+	// RuleDnat for rules of port forwarding
+	RuleDnat = "dnat"
+
+	// RuleSnat for source nat rules of private networks, after those of eips
+	RuleSnat = "snat"
+
+	// RuleLb for rules of load balancers
+	RuleLb = "lb"
+
+	// RuleRouteState for the firewall rule accepting new connections after
+	// all others, to keep route entries working
+	RuleRouteState = "route-state"
+)
+
+const (
+	// EipSnatFirstRule of source nat rules of eips, ahead of SnatRuleNumber
+	EipSnatFirstRule = 5000
+
+	// SnatRuleNumber of the first source nat rule of private networks
+	SnatRuleNumber = 8888
+
+	// RouteStateRuleNumber of the route state firewall rule, the last one
+	RouteStateRuleNumber = MaxRuleNumber
+)
+
+// RuleRange of rule numbers reserved for a feature in a chain
+type RuleRange struct {
+	Chain   string
+	Feature string
+	First   int
+	Last    int
+}
+
+// String of rule range
+func (r *RuleRange) String() string {
+	return fmt.Sprintf("%s rules of %s [%d-%d]", r.Feature, r.Chain, r.First, r.Last)
+}
+
+// Has number in range
+func (r *RuleRange) Has(number int) bool {
+	return number >= r.First && number <= r.Last
+}
+
+// HasRule numbered in range
+func (r *RuleRange) HasRule(rule *ConfigNode) bool {
+	number, err := strconv.Atoi(rule.name)
+	return err == nil && rule.isRule() && r.Has(number)
+}
+
+// ruleRanges registered, by chain
+var ruleRanges = make(map[string][]*RuleRange)
+
+func init() {
+	for _, r := range []RuleRange{
+		{ChainFirewall, RuleSystem, 1, 999},
+		{ChainFirewall, RuleLb, 1000, 1999},
+		{ChainFirewall, RuleDnat, 2000, 4999},
+		{ChainFirewall, RuleEip, 5000, 8999},
+		{ChainFirewall, RuleRouteState, RouteStateRuleNumber, RouteStateRuleNumber},
+
+		{ChainDnat, RuleDnat, 1, 4999},
+		{ChainDnat, RuleEip, 5000, MaxRuleNumber},
+
+		{ChainSnat, RuleSystem, 1, EipSnatFirstRule - 1},
+		{ChainSnat, RuleEip, EipSnatFirstRule, SnatRuleNumber - 1},
+		{ChainSnat, RuleSnat, SnatRuleNumber, MaxRuleNumber},
+	} {
+		if err := RegisterRuleRange(r.Chain, r.Feature, r.First, r.Last); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterRuleRange of feature in chain, ranges of a chain must not overlap
+func RegisterRuleRange(chain, feature string, first, last int) error {
+
+	r := &RuleRange{
+		Chain:   chain,
+		Feature: feature,
+		First:   first,
+		Last:    last,
+	}
+
+	if first < 1 || last > MaxRuleNumber || first > last {
+		return fmt.Errorf("bad %s, rule numbers must be in [1-%d]", r, MaxRuleNumber)
+	}
+
+	for _, o := range ruleRanges[chain] {
+		if o.Feature == feature {
+			return fmt.Errorf("%s already registered as %s", r, o)
+		}
+		if first <= o.Last && o.First <= last {
+			return fmt.Errorf("%s collides with %s", r, o)
+		}
+	}
+
+	ruleRanges[chain] = append(ruleRanges[chain], r)
+
+	return nil
+}
+
+// FindRuleRange of feature in chain, nil if not registered
+func FindRuleRange(chain, feature string) *RuleRange {
+	for _, r := range ruleRanges[chain] {
+		if r.Feature == feature {
+			return r
+		}
+	}
+	return nil
+}
+
+// ruleRangeOf number in chain, nil if not reserved
+func ruleRangeOf(chain string, number int) *RuleRange {
+	for _, r := range ruleRanges[chain] {
+		if r.Has(number) {
+			return r
+		}
+	}
+	return nil
+}
+
+// ruleChain of rule container at path, like "firewall" of
+// "firewall name eth0.in rule"
+func ruleChain(path string) string {
+
+	words := splitPath(path)
+	if len(words) > 0 && words[0] == ChainFirewall {
+		return ChainFirewall
+	}
+
+	if len(words) > 0 && words[len(words)-1] == RuleContainer {
+		words = words[:len(words)-1]
+	}
+
+	return strings.Join(words, " ")
+}
+
+// ruleCursor of range in a rule container, numbers from next never
+// allocated, and numbers freed below next to be allocated again
+type ruleCursor struct {
+	next  int
+	freed []int
+}
+
+// newRuleCursor of range built from rules of container, next after the last
+// rule taken, numbers free below it freed, lowest allocated first
+func newRuleCursor(container *ConfigNode, r *RuleRange) *ruleCursor {
+
+	c := &ruleCursor{next: r.First}
+	if container == nil {
+		return c
+	}
+
+	taken := make(map[int]bool)
+	for _, rule := range container.children {
+		if number, err := strconv.Atoi(rule.name); err == nil && r.Has(number) {
+			taken[number] = true
+			if number >= c.next {
+				c.next = number + 1
+			}
+		}
+	}
+
+	for number := c.next - 1; number >= r.First; number-- {
+		if !taken[number] {
+			c.freed = append(c.freed, number)
+		}
+	}
+
+	return c
+}
+
+// ruleAllocator of rule numbers by rule container and feature, for all trees
+// of the process. Cursors are built once from the first tree allocating, and
+// rebuilt when exhausted, or reset when the running configuration is rolled
+// back. A number allocated is never allocated again unless freed, even if the
+// tree is never committed, so trees parsed by each request need no scan.
+type ruleAllocator struct {
+	lock    sync.Mutex
+	cursors map[string]*ruleCursor
+}
+
+// gRuleAllocator for rule numbers of all trees
+var gRuleAllocator = &ruleAllocator{cursors: make(map[string]*ruleCursor)}
+
+// ResetRuleAllocator to rebuild cursors from the next trees allocating, for
+// running configuration changed other than by trees, like rolled back
+func ResetRuleAllocator() {
+	gRuleAllocator.lock.Lock()
+	defer gRuleAllocator.lock.Unlock()
+
+	gRuleAllocator.cursors = make(map[string]*ruleCursor)
+}
+
+// allocate number of range in container at path of tree
+func (a *ruleAllocator) allocate(t *ConfigTree, path string, r *RuleRange) (int, error) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	// rules set by number outside allocator are never taken twice
+	container := t.Get(path)
+	free := func(number int) bool {
+		return container == nil || container.getNode(strconv.Itoa(number)) == nil
+	}
+
+	key := path + " " + r.Feature
+	c := a.cursors[key]
+	if c == nil {
+		c = newRuleCursor(container, r)
+		a.cursors[key] = c
+	}
+
+	for rebuilt := false; ; rebuilt = true {
+		for len(c.freed) > 0 {
+			number := c.freed[len(c.freed)-1]
+			c.freed = c.freed[:len(c.freed)-1]
+			if free(number) {
+				return number, nil
+			}
+		}
+
+		for c.next <= r.Last {
+			number := c.next
+			c.next++
+			if free(number) {
+				return number, nil
+			}
+		}
+
+		// numbers of trees never committed, or deleted by others, found
+		// again by the tree
+		if rebuilt {
+			break
+		}
+		c = newRuleCursor(container, r)
+		a.cursors[key] = c
+	}
+
+	return 0, fmt.Errorf("no rule number available for %s, all of %s taken", path, r)
+}
+
+// release number of range in container at path, to be allocated again
+func (a *ruleAllocator) release(path string, r *RuleRange, number int) {
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if c := a.cursors[path+" "+r.Feature]; c != nil && number < c.next {
+		c.freed = append(c.freed, number)
+	}
+}
+
+// AllocateRule number of feature in rule container at path of the tree.
+// Numbers freed are reused first, then numbers never allocated in range,
+// kept track of across trees, so allocation is O(1) amortized.
+func (t *ConfigTree) AllocateRule(path, feature string) (int, error) {
+
+	t.init()
+
+	chain := ruleChain(path)
+	r := FindRuleRange(chain, feature)
+	if r == nil {
+		return 0, fmt.Errorf("no rule range of %s in %s", feature, chain)
+	}
+
+	return gRuleAllocator.allocate(t, path, r)
+}
+
+// releaseRule number of rule deleted, to be allocated again
+func (t *ConfigTree) releaseRule(rule *ConfigNode) {
+
+	number, err := strconv.Atoi(rule.name)
+	if err != nil {
+		return
+	}
+
+	path := rule.parent.String()
+	if r := ruleRangeOf(ruleChain(path), number); r != nil {
+		gRuleAllocator.release(path, r, number)
+	}
+}
+
+// SetRule in rule container at path, numbered by range of feature
+func (t *ConfigTree) SetRule(path, feature string, rules ...string) int {
+
+	number, err := t.AllocateRule(path, feature)
+	utils.PanicOnError(err)
+
+	for _, rule := range rules {
+		t.Setf("%s %d %s", path, number, rule)
+	}
+
+	return number
+}
@@ -0,0 +1,110 @@
+package vyos
+
+import (
+	"testing"
+)
+
+func TestRuleRanges(t *testing.T) {
+
+	if err := RegisterRuleRange(ChainSnat, "test", 8000, 8100); err == nil {
+		t.Fatalf("range colliding with eip rules should be rejected")
+	}
+
+	if err := RegisterRuleRange(ChainSnat, RuleEip, 9000, 9100); err == nil {
+		t.Fatalf("feature registered twice should be rejected")
+	}
+
+	eip := FindRuleRange(ChainSnat, RuleEip)
+	if eip == nil || eip.Last >= SnatRuleNumber {
+		t.Fatalf("eip rules should stay ahead of snat rule, %v got", eip)
+	}
+}
+
+func TestAllocateRule(t *testing.T) {
+
+	ResetRuleAllocator()
+
+	tree := NewParserFromConfiguration(`
+nat {
+    source {
+        rule 5000 {
+            description EIP-old
+        }
+        rule 8888 {
+            outbound-interface eth0
+        }
+    }
+}
+`).Tree
+
+	if n := tree.SetRule(SnatRules, RuleEip, "description EIP-new"); n != 5001 {
+		t.Fatalf("eip rule should be numbered after the taken one, %d got", n)
+	}
+
+	tree.SetSnatWithRuleNumber(5002, "description EIP-fixed")
+	if n := tree.SetRule(SnatRules, RuleEip, "description EIP-next"); n != 5003 {
+		t.Fatalf("rule set by number should not be taken twice, %d got", n)
+	}
+
+	tree.Delete("nat source rule 5001")
+	if n := tree.SetRule(SnatRules, RuleEip, "description EIP-again"); n != 5001 {
+		t.Fatalf("rule number freed should be reused, %d got", n)
+	}
+
+	if n, err := tree.AllocateRule("firewall name eth0.in rule", RuleRouteState); err != nil || n != MaxRuleNumber {
+		t.Fatalf("route state rule should be the last one, %d %v got", n, err)
+	}
+	tree.SetFirewallRule("eth0", "in", RuleRouteState, "action accept")
+	if _, err := tree.AllocateRule("firewall name eth0.in rule", RuleRouteState); err == nil {
+		t.Fatalf("exhausted range should fail")
+	}
+
+	if _, err := tree.AllocateRule(SnatRules, "unknown"); err == nil {
+		t.Fatalf("unknown feature should fail")
+	}
+}
+
+func TestRuleAllocatorAcrossTrees(t *testing.T) {
+
+	ResetRuleAllocator()
+
+	config := `
+nat {
+    destination {
+        rule 1 {
+            description DNAT-old
+        }
+        rule 3 {
+            description DNAT-old
+        }
+    }
+}
+`
+	first := NewParserFromConfiguration(config).Tree
+	if n := first.SetRule(DnatRules, RuleDnat, "description DNAT-new"); n != 2 {
+		t.Fatalf("free rule number should be allocated first, %d got", n)
+	}
+
+	// numbers allocated by a tree never committed are not taken again
+	second := NewParserFromConfiguration(config).Tree
+	if n := second.SetRule(DnatRules, RuleDnat, "description DNAT-new"); n != 4 {
+		t.Fatalf("rule number allocated by another tree should not be taken, %d got", n)
+	}
+
+	second.Delete("nat destination rule 1")
+	if n := NewParserFromConfiguration(config).Tree.SetRule(DnatRules, RuleDnat); n != 5 {
+		t.Fatalf("rule number freed should be skipped by trees still having it, %d got", n)
+	}
+
+	// numbers never committed found again once exhausted
+	full := &ConfigTree{}
+	for i := 1; i < 4999; i++ {
+		full.Setf("nat destination rule %d description DNAT-full", i)
+	}
+	if n := full.SetRule(DnatRules, RuleDnat, "description DNAT-last"); n != 4999 {
+		t.Fatalf("the last free rule number should be allocated, %d got", n)
+	}
+	if _, err := full.AllocateRule(DnatRules, RuleDnat); err == nil {
+		t.Fatalf("exhausted range should fail")
+	}
+}
@@ -0,0 +1,292 @@
+package vyos
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultSnapshotDir for configuration snapshots
+	DefaultSnapshotDir = "/home/vyos/rvm/snapshots"
+
+	// DefaultMaxAutoSnapshots kept of snapshots taken before commits
+	DefaultMaxAutoSnapshots = 20
+
+	// DefaultMaxNamedSnapshots kept of snapshots taken on demand
+	DefaultMaxNamedSnapshots = 50
+
+	// AutoSnapshotPrefix of names of snapshots taken before commits
+	AutoSnapshotPrefix = "auto-"
+
+	snapshotSuffix = ".json"
+)
+
+var (
+	// ErrSnapshotNotExist for snapshot name not found
+	ErrSnapshotNotExist = errors.New("snapshot not exist")
+
+	// ErrSnapshotExist for snapshot name already used
+	ErrSnapshotExist = errors.New("snapshot already exist")
+
+	// ErrBadSnapshotName for snapshot name not allowed
+	ErrBadSnapshotName = errors.New("snapshot name must be letters, digits, '.', '_' or '-', and not start with " + AutoSnapshotPrefix)
+
+	snapshotName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+)
+
+// Snapshot of running configuration
+type Snapshot struct {
+	Name   string `json:"name"`
+	Time   int64  `json:"time"`
+	Auto   bool   `json:"auto"`
+	Config string `json:"config,omitempty"`
+}
+
+// SnapshotStore of snapshots in a directory, one json file each, the oldest
+// pruned over limits of automatic and named snapshots separately
+type SnapshotStore struct {
+	lock     sync.Mutex
+	dir      string
+	maxAuto  int
+	maxNamed int
+}
+
+// InitSnapshots to snapshot running configuration before each commit
+func InitSnapshots(dir string, maxAuto int, maxNamed int) {
+	GCommitManager.Snapshots = NewSnapshotStore(dir, maxAuto, maxNamed)
+}
+
+// NewSnapshotStore in dir, defaults used for empty dir or limits
+func NewSnapshotStore(dir string, maxAuto int, maxNamed int) *SnapshotStore {
+
+	if dir == "" {
+		dir = DefaultSnapshotDir
+	}
+
+	if maxAuto <= 0 {
+		maxAuto = DefaultMaxAutoSnapshots
+	}
+
+	if maxNamed <= 0 {
+		maxNamed = DefaultMaxNamedSnapshots
+	}
+
+	return &SnapshotStore{
+		dir:      dir,
+		maxAuto:  maxAuto,
+		maxNamed: maxNamed,
+	}
+}
+
+func (s *SnapshotStore) path(name string) string {
+	return filepath.Join(s.dir, name+snapshotSuffix)
+}
+
+// Save config as snapshot of name
+func (s *SnapshotStore) Save(name string, config string) (*Snapshot, error) {
+
+	if !snapshotName.MatchString(name) || strings.HasPrefix(name, AutoSnapshotPrefix) {
+		return nil, ErrBadSnapshotName
+	}
+
+	return s.save(&Snapshot{
+		Name:   name,
+		Time:   time.Now().Unix(),
+		Config: config,
+	})
+}
+
+// SaveAuto config as snapshot named by time
+func (s *SnapshotStore) SaveAuto(config string) (*Snapshot, error) {
+
+	now := time.Now()
+
+	return s.save(&Snapshot{
+		Name:   AutoSnapshotPrefix + now.Format("20060102-150405.000000"),
+		Time:   now.Unix(),
+		Auto:   true,
+		Config: config,
+	})
+}
+
+func (s *SnapshotStore) save(snapshot *Snapshot) (*Snapshot, error) {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return nil, err
+	}
+
+	path := s.path(snapshot.Name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, ErrSnapshotExist
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	// written aside and renamed, never a half snapshot
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return nil, err
+	}
+
+	s.prune(snapshot.Auto)
+
+	return snapshot, nil
+}
+
+// prune oldest snapshots of kind over limit, must be called with lock held.
+// Kind told by name and age by file time, no snapshot read.
+func (s *SnapshotStore) prune(auto bool) {
+
+	max := s.maxNamed
+	if auto {
+		max = s.maxAuto
+	}
+
+	files, _ := ioutil.ReadDir(s.dir)
+
+	snapshots := make([]os.FileInfo, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), snapshotSuffix) {
+			continue
+		}
+		if strings.HasPrefix(f.Name(), AutoSnapshotPrefix) == auto {
+			snapshots = append(snapshots, f)
+		}
+	}
+
+	if len(snapshots) <= max {
+		return
+	}
+
+	// newest first, automatic ones of the same time ordered by name
+	sort.Slice(snapshots, func(i, j int) bool {
+		if !snapshots[i].ModTime().Equal(snapshots[j].ModTime()) {
+			return snapshots[i].ModTime().After(snapshots[j].ModTime())
+		}
+		return snapshots[i].Name() > snapshots[j].Name()
+	})
+
+	for _, f := range snapshots[max:] {
+		name := strings.TrimSuffix(f.Name(), snapshotSuffix)
+		if err := os.Remove(s.path(name)); err != nil {
+			logger.Warnf("remove snapshot %s error %s\n", name, err)
+		} else {
+			logger.Debugf("snapshot %s pruned\n", name)
+		}
+	}
+}
+
+// load snapshot of name with config
+func (s *SnapshotStore) load(name string) (*Snapshot, error) {
+
+	data, err := ioutil.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrSnapshotNotExist
+	} else if err != nil {
+		return nil, err
+	}
+
+	snapshot := new(Snapshot)
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+// list snapshots without config, newest first, must be called with lock held
+func (s *SnapshotStore) list() []*Snapshot {
+
+	files, _ := ioutil.ReadDir(s.dir)
+
+	snapshots := make([]*Snapshot, 0, len(files))
+	saved := make(map[*Snapshot]time.Time, len(files))
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), snapshotSuffix) {
+			continue
+		}
+
+		snapshot, err := s.load(strings.TrimSuffix(f.Name(), snapshotSuffix))
+		if err != nil {
+			logger.Warnf("load snapshot %s error %s\n", f.Name(), err)
+			continue
+		}
+		snapshot.Config = ""
+
+		snapshots = append(snapshots, snapshot)
+		saved[snapshot] = f.ModTime()
+	}
+
+	// snapshots of the same second ordered by file time
+	sort.SliceStable(snapshots, func(i, j int) bool {
+		if snapshots[i].Time != snapshots[j].Time {
+			return snapshots[i].Time > snapshots[j].Time
+		}
+		return saved[snapshots[i]].After(saved[snapshots[j]])
+	})
+
+	return snapshots
+}
+
+// List snapshots without config, newest first
+func (s *SnapshotStore) List() []*Snapshot {
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.list()
+}
+
+// Get snapshot of name with config
+func (s *SnapshotStore) Get(name string) (*Snapshot, error) {
+
+	if !snapshotName.MatchString(name) {
+		return nil, ErrSnapshotNotExist
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.load(name)
+}
+
+// Delete snapshot of name
+func (s *SnapshotStore) Delete(name string) error {
+
+	if !snapshotName.MatchString(name) {
+		return ErrSnapshotNotExist
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err := os.Remove(s.path(name))
+	if os.IsNotExist(err) {
+		return ErrSnapshotNotExist
+	}
+
+	return err
+}
+
+// Tree of snapshot config
+func (snapshot *Snapshot) Tree() *ConfigTree {
+	return NewParserFromConfiguration(snapshot.Config).Tree
+}
@@ -0,0 +1,99 @@
+package vyos
+
+import (
+	"io/ioutil"
+	"octlink/ovs/utils/configuration"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotStore(t *testing.T) {
+
+	dir, _ := ioutil.TempDir("", "ovs-snapshot")
+	defer os.RemoveAll(dir)
+
+	configuration.Conf.LogDirectory = dir
+	InitLog(0)
+
+	s := NewSnapshotStore(dir, 2, 1)
+
+	for i := 0; i < 3; i++ {
+		if _, err := s.SaveAuto("service {\n}\n"); err != nil {
+			t.Fatalf("save auto snapshot error %s", err)
+		}
+	}
+
+	if _, err := s.Save("good", "service {\n    ssh {\n        port 22\n    }\n}\n"); err != nil {
+		t.Fatalf("save snapshot error %s", err)
+	}
+
+	if _, err := s.Save("good", ""); err != ErrSnapshotExist {
+		t.Fatalf("snapshot name should not be reused, %v got", err)
+	}
+
+	for _, name := range []string{"../etc", "auto-1", ""} {
+		if _, err := s.Save(name, ""); err != ErrBadSnapshotName {
+			t.Fatalf("snapshot name %q should be rejected, %v got", name, err)
+		}
+	}
+
+	auto := 0
+	for _, snapshot := range s.List() {
+		if snapshot.Config != "" {
+			t.Fatalf("snapshots should be listed without config")
+		}
+		if snapshot.Auto {
+			auto++
+		}
+	}
+	if auto != 2 || len(s.List()) != 3 {
+		t.Fatalf("automatic snapshots should be pruned to 2, %v got", s.List())
+	}
+
+	// pruned by file time, never read
+	broken := filepath.Join(dir, "broken.json")
+	ioutil.WriteFile(broken, []byte("{"), 0600)
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(broken, old, old)
+
+	if _, err := s.Save("newer", ""); err != nil || len(s.List()) != 3 {
+		t.Fatalf("named snapshots should be pruned to 1, %v %v", s.List(), err)
+	}
+	if _, err := s.Get("good"); err != ErrSnapshotNotExist {
+		t.Fatalf("oldest named snapshot should be pruned, %v got", err)
+	}
+	if _, err := os.Stat(broken); !os.IsNotExist(err) {
+		t.Fatalf("broken snapshot should be pruned, %v got", err)
+	}
+
+	if err := s.Delete("newer"); err != nil {
+		t.Fatalf("delete snapshot error %s", err)
+	}
+	if err := s.Delete("newer"); err != ErrSnapshotNotExist {
+		t.Fatalf("delete snapshot twice should fail, %v got", err)
+	}
+}
+
+func TestConverge(t *testing.T) {
+
+	running := NewParserFromConfiguration("service {\n    ssh {\n        port 2222\n    }\n    dns {\n        forwarding {\n            listen-on eth1\n        }\n    }\n}\n").Tree
+	snapshot := NewParserFromConfiguration("service {\n    ssh {\n        port 22\n    }\n}\n").Tree
+
+	running.Converge(snapshot)
+
+	want := []string{
+		"$DELETE service ssh port 2222",
+		"$DELETE service dns",
+		"$SET service ssh port 22",
+	}
+	if !reflect.DeepEqual(running.Commands(), want) {
+		t.Fatalf("tree should be converged to snapshot, %v got", running.Commands())
+	}
+
+	if running.String() != snapshot.String() {
+		t.Fatalf("converged tree should equal snapshot, %s got", running.String())
+	}
+}
@@ -0,0 +1,385 @@
+package vyos
+
+import (
+	"fmt"
+	"strings"
+)
+
+// configIndent of config.boot syntax
+const configIndent = "    "
+
+// tagNames of vyos tag nodes, whose children are named by the user, like
+// "ethernet eth0" or "rule 10", by name or by "parent name". True for tag
+// nodes always, false for names which are tag nodes only if nested, like
+// "firewall group address-group" but not "rule 1 source group address-group".
+// Used when serializing trees built by set commands, trees parsed from
+// config.boot remember their tag nodes.
+var tagNames = map[string]bool{
+	"bonding":             true,
+	"bridge":              true,
+	"dummy":               true,
+	"esp-group":           true,
+	"ethernet":            true,
+	"firewall name":       true,
+	"ike-group":           true,
+	"interface-route":     true,
+	"login user":          true,
+	"loopback":            true,
+	"next-hop":            true,
+	"ntp server":          true,
+	"openvpn":             true,
+	"peer":                true,
+	"pppoe":               true,
+	"public-keys":         true,
+	"rule":                true,
+	"shared-network-name": true,
+	"static route":        true,
+	"static-mapping":      true,
+	"subnet":              true,
+	"syslog host":         true,
+	"tunnel":              true,
+	"vif":                 true,
+	"vti":                 true,
+	"wireguard":           true,
+
+	"address-group": false,
+	"group":         false,
+	"host":          false,
+	"name":          false,
+	"network-group": false,
+	"port-group":    false,
+	"route":         false,
+	"server":        false,
+	"user":          false,
+}
+
+// multiValueLeaves of vyos leaves taking many values, "*" for any name of a
+// tag node. Used to add to a leaf instead of replacing its value when the
+// running tree cannot tell, a leaf of one value may take more.
+var multiValueLeaves = [][]string{
+	{"firewall", "group", "address-group", "*", "address"},
+	{"firewall", "group", "network-group", "*", "network"},
+	{"firewall", "group", "port-group", "*", "port"},
+	{"interfaces", "*", "*", "address"},
+	{"interfaces", "*", "*", "vif", "*", "address"},
+	{"service", "dns", "forwarding", "listen-on"},
+	{"service", "dns", "forwarding", "name-server"},
+	{"system", "name-server"},
+}
+
+// isMultiValue if leaf at key takes many values
+func isMultiValue(key []string) bool {
+
+	for _, pattern := range multiValueLeaves {
+		if len(pattern) != len(key) {
+			continue
+		}
+		matched := true
+		for i, word := range pattern {
+			if word != "*" && word != key[i] {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// token of configuration text, braces are tokens unless quoted
+type token struct {
+	text   string
+	quoted bool
+}
+
+func (t token) is(text string) bool {
+	return !t.quoted && t.text == text
+}
+
+// tokenize configuration text into lines of tokens. Values may be quoted by
+// double or single quotes, backslash escapes the next char in double quotes.
+// Comments of /* */ and // are dropped.
+func tokenize(text string) ([][]token, error) {
+
+	lines := make([][]token, 0)
+	line := make([]token, 0)
+
+	rs := []rune(text)
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+
+		switch {
+		case c == '\n':
+			lines = append(lines, line)
+			line = make([]token, 0)
+
+		case c == ' ' || c == '\t' || c == '\r':
+
+		case c == '/' && i+1 < len(rs) && rs[i+1] == '*':
+			j := i + 2
+			for j+1 < len(rs) && !(rs[j] == '*' && rs[j+1] == '/') {
+				j++
+			}
+			if j+1 >= len(rs) {
+				return nil, fmt.Errorf("unterminated comment at line %d", len(lines)+1)
+			}
+			i = j + 1
+
+		case c == '/' && i+1 < len(rs) && rs[i+1] == '/':
+			for i+1 < len(rs) && rs[i+1] != '\n' {
+				i++
+			}
+
+		case c == '{' || c == '}':
+			line = append(line, token{text: string(c)})
+
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			closed := false
+			for i++; i < len(rs); i++ {
+				if rs[i] == c {
+					closed = true
+					break
+				}
+				if c == '"' && rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				b.WriteRune(rs[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quote at line %d", len(lines)+1)
+			}
+			line = append(line, token{text: b.String(), quoted: true})
+
+		default:
+			start := i
+			for i+1 < len(rs) && !strings.ContainsRune(" \t\r\n{}\"'", rs[i+1]) {
+				i++
+			}
+			line = append(line, token{text: string(rs[start : i+1])})
+		}
+	}
+
+	return append(lines, line), nil
+}
+
+// splitPath of config path or command into words, quoted words kept whole
+func splitPath(config string) []string {
+
+	words := make([]string, 0)
+
+	rs := []rune(config)
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			continue
+		}
+
+		var b strings.Builder
+		for ; i < len(rs) && !strings.ContainsRune(" \t\n\r", rs[i]); i++ {
+			q := rs[i]
+			if q != '"' && q != '\'' {
+				b.WriteRune(q)
+				continue
+			}
+			for i++; i < len(rs) && rs[i] != q; i++ {
+				if q == '"' && rs[i] == '\\' && i+1 < len(rs) {
+					i++
+				}
+				b.WriteRune(rs[i])
+			}
+		}
+		words = append(words, b.String())
+	}
+
+	return words
+}
+
+// needsQuote if word is not safe unquoted in shell or config.boot
+func needsQuote(word string) bool {
+
+	if word == "" {
+		return true
+	}
+
+	for _, c := range word {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case strings.ContainsRune("_./:@%+,=!-", c):
+		default:
+			return true
+		}
+	}
+
+	return false
+}
+
+// quoteWord for vyos commands run by shell
+func quoteWord(word string) string {
+
+	if !needsQuote(word) {
+		return word
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range word {
+		if strings.ContainsRune("\\\"$`", c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// quoteConfigWord for config.boot syntax
+func quoteConfigWord(word string) string {
+
+	if !needsQuote(word) {
+		return word
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, c := range word {
+		if c == '\\' || c == '"' {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	b.WriteByte('"')
+
+	return b.String()
+}
+
+// formatPath of words, quoted for vyos commands
+func formatPath(words []string) string {
+
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = quoteWord(w)
+	}
+
+	return strings.Join(quoted, " ")
+}
+
+// isTagNode if children of n are named by the user
+func (n *ConfigNode) isTagNode() bool {
+
+	if n.tag {
+		return true
+	}
+
+	always, ok := false, false
+	if n.parent != nil {
+		always, ok = tagNames[n.parent.name+" "+n.name]
+	}
+	if !ok {
+		always, ok = tagNames[n.name]
+	}
+
+	if !ok || len(n.children) == 0 {
+		return false
+	}
+
+	return always || !n.allLeaves()
+}
+
+// allLeaves if all children of n are leaves, values of a key
+func (n *ConfigNode) allLeaves() bool {
+
+	for _, c := range n.children {
+		if !c.isLeaf() {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeConfig of n in config.boot syntax at depth
+func (n *ConfigNode) writeConfig(b *strings.Builder, depth int) {
+
+	indent := strings.Repeat(configIndent, depth)
+	name := quoteConfigWord(n.name)
+
+	switch {
+	case n.isLeaf():
+		b.WriteString(indent + name + "\n")
+
+	case n.isTagNode():
+		for _, c := range n.children {
+			b.WriteString(indent + name + " " + quoteConfigWord(c.name) + " {\n")
+			for _, cc := range c.children {
+				cc.writeConfig(b, depth+1)
+			}
+			b.WriteString(indent + "}\n")
+		}
+
+	case n.allLeaves():
+		// one line for each value of multi-value leaf
+		for _, c := range n.children {
+			b.WriteString(indent + name + " " + quoteConfigWord(c.name) + "\n")
+		}
+
+	default:
+		b.WriteString(indent + name + " {\n")
+		for _, c := range n.children {
+			c.writeConfig(b, depth+1)
+		}
+		b.WriteString(indent + "}\n")
+	}
+}
+
+// Config of tree in config.boot syntax
+func (t *ConfigTree) Config() string {
+
+	if t.Root == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, c := range t.Root.children {
+		c.writeConfig(&b, 0)
+	}
+
+	return b.String()
+}
+
+// SetCommands of tree, one set command for each leaf as "show configuration
+// commands" of vyos cli
+func (t *ConfigTree) SetCommands() []string {
+
+	commands := make([]string, 0)
+	if t.Root == nil {
+		return commands
+	}
+
+	for _, leaf := range t.Root.leaves() {
+		commands = append(commands, "set "+leaf)
+	}
+
+	return commands
+}
+
+// NewTreeFromSetCommands to build a tree of set commands
+func NewTreeFromSetCommands(commands []string) *ConfigTree {
+
+	tree := &ConfigTree{}
+	tree.init()
+
+	for _, command := range commands {
+		words := splitPath(command)
+		if len(words) < 2 || words[0] != "set" {
+			continue
+		}
+		tree.Root.addPath(words[1:])
+	}
+
+	return tree
+}
@@ -0,0 +1,199 @@
+package vyos
+
+import (
+	"reflect"
+	"testing"
+)
+
+// configBoot of a vyos 1.1 router, as saved in /config/config.boot
+const configBoot = `firewall {
+    all-ping enable
+    broadcast-ping disable
+    name eth0.in {
+        default-action drop
+        rule 1 {
+            action accept
+            description "web server"
+            destination {
+                address 10.0.0.2
+                port 80,443
+            }
+            protocol tcp
+            state {
+                established enable
+                new enable
+            }
+        }
+        rule 2 {
+            action reject
+            description "say \"hi\" to \\ the world"
+            source {
+                address !10.0.0.0/8
+            }
+        }
+    }
+}
+interfaces {
+    ethernet eth0 {
+        address 172.20.14.209/16
+        address 172.20.14.210/16
+        description "public, uplink"
+        duplex auto
+        firewall {
+            in {
+                name eth0.in
+            }
+        }
+        hw-id fa:da:21:1f:1a:00
+        speed auto
+    }
+    ethernet eth1 {
+        address dhcp
+        /* guest network */
+        description guest
+    }
+    loopback lo {
+    }
+}
+service {
+    dns {
+        forwarding {
+            cache-size 150
+            listen-on eth1
+            listen-on eth2
+            name-server 8.8.8.8
+            name-server 114.114.114.114
+        }
+    }
+    ssh {
+        disable-host-validation
+        port 22
+    }
+}
+system {
+    host-name vyos
+    login {
+        user vyos {
+            authentication {
+                encrypted-password "$1$abc$def/ghi"
+            }
+            full-name "Router Admin"
+            level admin
+        }
+    }
+    ntp {
+        server 0.pool.ntp.org {
+        }
+        server 1.pool.ntp.org {
+        }
+    }
+    time-zone Asia/Shanghai
+}
+
+
+/* Warning: Do not remove the following line. */
+/* === vyatta-config-version: "cluster@1:config-management@1:conntrack-sync@1:dhcp-server@4:firewall@5:nat@4:quagga@2:system@6:vrrp@1:webgui@1" === */
+/* Release version: VyOS 1.1.8 */
+`
+
+func TestParseQuotedAndComments(t *testing.T) {
+
+	tree := NewParserFromConfiguration(configBoot).Tree
+
+	cases := map[string]string{
+		"firewall name eth0.in rule 1 description": "web server",
+		"firewall name eth0.in rule 2 description": `say "hi" to \ the world`,
+		"interfaces ethernet eth0 description":     "public, uplink",
+		"interfaces ethernet eth1 description":     "guest",
+		"system login user vyos full-name":         "Router Admin",
+	}
+	for path, want := range cases {
+		if n := tree.Get(path); n == nil || n.Value() != want {
+			t.Fatalf("value of %s should be %q, %v got", path, want, n)
+		}
+	}
+
+	values := tree.Get("service dns forwarding name-server").Values()
+	if !reflect.DeepEqual(values, []string{"8.8.8.8", "114.114.114.114"}) {
+		t.Fatalf("multi-value leaf should keep all values, %v got", values)
+	}
+
+	if n := tree.Get(`system login user vyos full-name "Router Admin"`); n == nil {
+		t.Fatalf("quoted path should be found")
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+
+	tree := NewParserFromConfiguration(configBoot).Tree
+
+	config := tree.Config()
+	again := NewParserFromConfiguration(config).Tree
+
+	if again.String() != tree.String() {
+		t.Fatalf("parse, serialize and parse should keep the tree, %s got", again.String())
+	}
+
+	if again.Config() != config {
+		t.Fatalf("serialized config should be stable, %s got", again.Config())
+	}
+
+	// vyos comments dropped, the rest as saved by vyos
+	want := configBoot[:len(configBoot)-len(configBoot[indexOf(configBoot, "\n\n\n")+1:])]
+	want = removeLine(want, "        /* guest network */\n")
+	if config != want {
+		t.Fatalf("serialized config should be valid config.boot, %s got", config)
+	}
+
+	commands := tree.SetCommands()
+	fromSet := NewTreeFromSetCommands(commands)
+	if fromSet.String() != tree.String() {
+		t.Fatalf("set commands should rebuild the tree, %s got", fromSet.String())
+	}
+
+	if fromSet.Config() != config {
+		t.Fatalf("tree of set commands should serialize as parsed, %s got", fromSet.Config())
+	}
+}
+
+func TestSetQuoted(t *testing.T) {
+
+	tree := NewParserFromConfiguration(configBoot).Tree
+
+	tree.Set(`interfaces ethernet eth1 description 'office $LAN'`)
+	tree.Set(`firewall name eth0.in rule 1 description "web server"`)
+
+	want := []string{
+		`$DELETE interfaces ethernet eth1 description`,
+		`$SET interfaces ethernet eth1 description "office \$LAN"`,
+	}
+	if !reflect.DeepEqual(tree.Commands(), want) {
+		t.Fatalf("value with spaces should be one word, %v got", tree.Commands())
+	}
+
+	if v := tree.Get("interfaces ethernet eth1 description").Value(); v != "office $LAN" {
+		t.Fatalf("value should be kept unquoted in tree, %s got", v)
+	}
+
+	n := tree.Get("system login user vyos full-name")
+	if n.String() != `system login user vyos full-name` || n.Children()[0].String() != `system login user vyos full-name "Router Admin"` {
+		t.Fatalf("path of node should be quoted, %s got", n.Children()[0].String())
+	}
+}
+
+func indexOf(s, sub string) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		if s[i:i+len(sub)] == sub {
+			return i
+		}
+	}
+	return -1
+}
+
+func removeLine(s, line string) string {
+	i := indexOf(s, line)
+	if i < 0 {
+		return s
+	}
+	return s[:i] + s[i+len(line):]
+}
@@ -7,11 +7,6 @@ import (
 	"octlink/ovs/utils/octlog"
 	"os"
 	"strings"
-	"sync"
-)
-
-var (
-	vyosScriptLock = &sync.Mutex{}
 )
 
 var logger *octlog.LogConfig
@@ -152,8 +147,8 @@ trap atexit EXIT SIGHUP SIGINT SIGTERM
 // Lock for command management
 func Lock(fn CommandHandler) CommandHandler {
 	return func(ctx *CommandContext) interface{} {
-		vyosScriptLock.Lock()
-		defer vyosScriptLock.Unlock()
+		GCommitManager.Lock()
+		defer GCommitManager.Unlock()
 		return fn(ctx)
 	}
 }
@@ -1,7 +1,6 @@
 package vyos
 
 import (
-	"bufio"
 	"fmt"
 	"octlink/ovs/utils"
 	"strings"
@@ -40,36 +39,30 @@ var (
 	UnitTest = false
 )
 
-func matchToken(words []string) (int, role, []string, string) {
-	ws := make([]string, 0)
-	next := 0
-
-	// find until \n
-	for next = 0; next < len(words); next++ {
-		w := words[next]
-		if w == "\n" {
-			break
-		}
-
-		ws = append(ws, w)
-	}
-
-	length := len(ws)
-	if length == 2 && ws[length-1] == "{" {
-		return next, Root, []string{ws[0]}, ""
-	} else if length > 2 && ws[length-1] == "{" {
-		return next, RootAttribute, ws[:length-1], ""
-	} else if length >= 2 && ws[length-1] != "{" && ws[length-1] != "}" {
-		return next, KeyValue, []string{ws[0]}, strings.Join(ws[1:], " ")
-	} else if length == 1 && ws[0] == "}" {
-		return next, Close, nil, ""
-	} else if length == 1 && ws[0] != "{" && ws[0] != "}" {
-		return next, Value, nil, ws[0]
+// matchToken of tokens of a line to its role, keys and value
+func matchToken(line []token) (role, []string, string) {
+
+	length := len(line)
+	texts := make([]string, length)
+	for i, t := range line {
+		texts[i] = t.text
+	}
+
+	if length == 2 && line[1].is("{") {
+		return Root, texts[:1], ""
+	} else if length > 2 && line[length-1].is("{") {
+		return RootAttribute, texts[:length-1], ""
+	} else if length >= 2 && !line[length-1].is("{") && !line[length-1].is("}") {
+		return KeyValue, texts[:1], strings.Join(texts[1:], " ")
+	} else if length == 1 && line[0].is("}") {
+		return Close, nil, ""
+	} else if length == 1 && !line[0].is("{") {
+		return Value, nil, texts[0]
 	} else if length == 0 {
-		return next + 1, Ignore, nil, ""
-	} else {
-		panic(fmt.Errorf("unable to parser the words: %s", strings.Join(words, " ")))
+		return Ignore, nil, ""
 	}
+
+	panic(fmt.Errorf("unable to parser the words: %s", strings.Join(texts, " ")))
 }
 
 // GetValue from parser
@@ -85,27 +78,18 @@ func (parser *Parser) GetValue(key string) (string, bool) {
 func (parser *Parser) Parse(text string) *ConfigTree {
 	parser.parsed = true
 
-	words := make([]string, 0)
-	for _, s := range strings.Split(text, "\n") {
-		scanner := bufio.NewScanner(strings.NewReader(s))
-		scanner.Split(bufio.ScanWords)
-		ws := make([]string, 0)
-		for scanner.Scan() {
-			ws = append(ws, scanner.Text())
-		}
-		ws = append(ws, "\n")
-		words = append(words, ws...)
+	lines, err := tokenize(text)
+	if err != nil {
+		panic(fmt.Errorf("unable to parse configuration, %s", err))
 	}
 
-	offset := 0
 	tree := &ConfigTree{Root: &ConfigNode{}}
 	tree.Root.tree = tree
 	tstack := &utils.Stack{}
 
 	currentNode := tree.Root
-	for i := 0; i < len(words); i += offset {
-		o, role, keys, value := matchToken(words[i:])
-		offset = o
+	for _, line := range lines {
+		role, keys, value := matchToken(line)
 		if role == Root {
 			tstack.Push(currentNode)
 			currentNode = currentNode.addNode(keys[0])
@@ -116,14 +100,21 @@ func (parser *Parser) Parse(text string) *ConfigTree {
 		} else if role == RootAttribute {
 			tstack.Push(currentNode)
 
-			for _, key := range keys {
+			// like "ethernet eth0 {", ethernet is a tag node
+			for i, key := range keys {
 				if n := currentNode.getNode(key); n == nil {
 					currentNode = currentNode.addNode(key)
 				} else {
 					currentNode = n
 				}
+				if i == 0 && len(keys) == 2 {
+					currentNode.tag = true
+				}
 			}
 		} else if role == Close {
+			if tstack.Len() == 0 {
+				panic(fmt.Errorf("unable to parse configuration, unbalanced }"))
+			}
 			currentNode = tstack.Pop().(*ConfigNode)
 		}
 	}
@@ -136,16 +127,11 @@ func (parser *Parser) Parse(text string) *ConfigTree {
 	return tree
 }
 
-// ConfigurationSourceFunc for configure source
+// ConfigurationSourceFunc for configure source, of GBackend by default
 var ConfigurationSourceFunc = func() string {
-	bash := utils.Bash{
-		Command: "/bin/cli-shell-api showCfg",
-		NoLog:   true,
-	}
-
-	_, o, _, _ := bash.RunWithReturn()
-	bash.PanicIfError()
-	return o
+	config, err := GBackend.ShowConfiguration()
+	utils.PanicOnError(err)
+	return config
 }
 
 // ShowConfiguration for configure display
@@ -174,6 +160,9 @@ type ConfigNode struct {
 	childrenIndex map[string]*ConfigNode
 	parent        *ConfigNode
 	tree          *ConfigTree
+
+	// tag node, like ethernet of "ethernet eth0 {"
+	tag bool
 }
 
 // Children for config node
@@ -190,25 +179,18 @@ func (n *ConfigNode) ChildNodeKeys() []string {
 	return keys
 }
 
-// String for config node
-func (n *ConfigNode) String() string {
-	stack := &utils.Stack{}
-	p := n
-	for {
-		if p == nil {
-			return func() string {
-				sl := stack.Slice()
-				ss := make([]string, len(sl))
-				for i, s := range sl {
-					ss[i] = s.(string)
-				}
-				return strings.TrimSpace(strings.Join(ss, " "))
-			}()
-		}
-
-		stack.Push(p.name)
-		p = p.parent
+// path of node as words from root
+func (n *ConfigNode) path() []string {
+	words := make([]string, 0)
+	for p := n; p != nil && p.parent != nil; p = p.parent {
+		words = append([]string{p.name}, words...)
 	}
+	return words
+}
+
+// String for config node, words quoted if needed
+func (n *ConfigNode) String() string {
+	return formatPath(n.path())
 }
 
 func (n *ConfigNode) isValueNode() bool {
@@ -284,11 +266,17 @@ func (n *ConfigNode) Getf(f string, args ...interface{}) *ConfigNode {
 
 // Get for config node
 func (n *ConfigNode) Get(config string) *ConfigNode {
-	cs := strings.Split(config, " ")
-	current := n
+	return n.getPath(splitPath(config))
+}
+
+func (n *ConfigNode) getPath(words []string) *ConfigNode {
+	if len(words) == 0 {
+		return nil
+	}
 
-	for _, c := range cs {
-		current = current.getNode(c)
+	current := n
+	for _, w := range words {
+		current = current.getNode(w)
 		if current == nil {
 			return nil
 		}
@@ -297,6 +285,21 @@ func (n *ConfigNode) Get(config string) *ConfigNode {
 	return current
 }
 
+// addPath of words under n, true if any node added
+func (n *ConfigNode) addPath(words []string) bool {
+	current := n
+	added := false
+	for _, w := range words {
+		if c := current.getNode(w); c != nil {
+			current = c
+			continue
+		}
+		current = current.addNode(w)
+		added = true
+	}
+	return added
+}
+
 func (n *ConfigNode) getNode(name string) *ConfigNode {
 	return n.childrenIndex[name]
 }
@@ -329,6 +332,39 @@ func (n *ConfigNode) addNode(name string) *ConfigNode {
 type ConfigTree struct {
 	Root           *ConfigNode
 	changeCommands []string
+
+	// hooks run once changes committed, or reverts once changes reverted
+	hooks   []func()
+	reverts []func()
+}
+
+// OnCommit to run fn once changes of tree committed
+func (t *ConfigTree) OnCommit(fn func()) {
+	t.hooks = append(t.hooks, fn)
+}
+
+// OnRevert to run fn once changes of tree reverted, like not confirmed
+func (t *ConfigTree) OnRevert(fn func()) {
+	t.reverts = append(t.reverts, fn)
+}
+
+// Committed to run hooks of tree, by the committer once changes committed
+func (t *ConfigTree) Committed() {
+	hooks := t.hooks
+	t.hooks, t.reverts = nil, nil
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// Reverted to run revert hooks of tree, by the committer once changes
+// reverted, commit hooks dropped
+func (t *ConfigTree) Reverted() {
+	reverts := t.reverts
+	t.hooks, t.reverts = nil, nil
+	for _, fn := range reverts {
+		fn()
+	}
 }
 
 // HasChanges judge changes of command tree
@@ -348,11 +384,8 @@ func (t *ConfigTree) Apply(asVyosUser bool) {
 		return
 	}
 
-	if asVyosUser {
-		RunVyosScriptAsUserVyos(strings.Join(t.changeCommands, "\n"))
-	} else {
-		RunVyosScript(strings.Join(t.changeCommands, "\n"), nil)
-	}
+	utils.PanicOnError(GBackend.Apply(t.changeCommands))
+	utils.PanicOnError(GBackend.Commit(asVyosUser))
 }
 
 func (t *ConfigTree) init() {
@@ -386,7 +419,7 @@ func (t *ConfigTree) has(config ...string) bool {
 
 // Has judgement for config tree
 func (t *ConfigTree) Has(config string) bool {
-	return t.has(strings.Split(config, " ")...)
+	return t.has(splitPath(config)...)
 }
 
 // AttachFirewallToInterface to add firewall config for interface
@@ -417,29 +450,29 @@ func (t *ConfigTree) SetFirewallDefaultAction(ethname, direction, action string)
 	t.Setf("firewall name %s.%s default-action %v", ethname, direction, action)
 }
 
-// SetFirewallOnInterface to set firewall on interface
+// SetFirewallOnInterface to set firewall on interface, numbered by range of
+// system rules
 func (t *ConfigTree) SetFirewallOnInterface(ethname, direction string, rules ...string) int {
+	return t.SetFirewallRule(ethname, direction, RuleSystem, rules...)
+}
+
+// SetFirewallRule to set firewall on interface, numbered by range of feature
+func (t *ConfigTree) SetFirewallRule(ethname, direction, feature string, rules ...string) int {
 	if direction != "in" && direction != "out" && direction != "local" {
 		panic(fmt.Sprintf("the direction can only be [in, out, local], but %s get", direction))
 	}
 
-	currentRuleNum := -1
-	for i := 1; i <= 9999; i++ {
-		if c := t.Getf("firewall name %s.%s rule %v", ethname, direction, i); c == nil {
-			currentRuleNum = i
-			break
-		}
-	}
-
-	if currentRuleNum == -1 {
-		panic(fmt.Sprintf("No firewall rule number found for the interface %s.%s. You have set more than 9999 rules???", ethname, direction))
-	}
+	return t.SetRule(FirewallRules(ethname, direction), feature, rules...)
+}
 
-	for _, rule := range rules {
-		t.Setf("firewall name %v.%v rule %v %s", ethname, direction, currentRuleNum, rule)
-	}
+// FirewallRules container of firewall rules on interface
+func FirewallRules(ethname, direction string) string {
+	return formatPath([]string{ChainFirewall, "name", ethname + "." + direction, RuleContainer})
+}
 
-	return currentRuleNum
+// RulePath of rule number in container
+func RulePath(container string, number int) string {
+	return fmt.Sprintf("%s %d", container, number)
 }
 
 // SetFirewallWithRuleNumber to set firewall with rule number
@@ -453,26 +486,9 @@ func (t *ConfigTree) SetFirewallWithRuleNumber(ethname, direction string, number
 	}
 }
 
-// SetDnat to set dnat by config tree
+// SetDnat to set dnat by config tree, numbered by range of dnat rules
 func (t *ConfigTree) SetDnat(rules ...string) int {
-	currentRuleNum := -1
-
-	for i := 1; i <= 9999; i++ {
-		if c := t.Getf("nat destination rule %v", i); c == nil {
-			currentRuleNum = i
-			break
-		}
-	}
-
-	if currentRuleNum == -1 {
-		panic("No rule number avaible for dnat. You have set more than 9999 rules???")
-	}
-
-	for _, rule := range rules {
-		t.Setf("nat destination rule %v %s", currentRuleNum, rule)
-	}
-
-	return currentRuleNum
+	return t.SetRule(DnatRules, RuleDnat, rules...)
 }
 
 // FindDnatRuleDescription to find dnat rule's description
@@ -515,37 +531,22 @@ func (t *ConfigTree) SetSnatWithRuleNumber(ruleNum int, rules ...string) {
 	}
 }
 
-// SetSnatWithStartRuleNumber to set snat with rule number start
+// SetSnatWithStartRuleNumber to set snat numbered by range of startNum
 func (t *ConfigTree) SetSnatWithStartRuleNumber(startNum int, rules ...string) int {
-	currentRuleNum := -1
-
-	for i := startNum; i <= 9999; i++ {
-		if c := t.Getf("nat source rule %v", i); c == nil {
-			currentRuleNum = i
-			break
-		}
-	}
-
-	if currentRuleNum == -1 {
-		panic("No rule number avaible for source nat. You have set more than 9999 rules???")
-	}
-
-	for _, rule := range rules {
-		t.Setf("nat source rule %v %s", currentRuleNum, rule)
-	}
-
-	return currentRuleNum
+	r := ruleRangeOf(ChainSnat, startNum)
+	utils.Assertf(r != nil, "no rule range of source nat rule %d", startNum)
+	return t.SetRule(SnatRules, r.Feature, rules...)
 }
 
-// SetSnat for config node
+// SetSnat for config node, numbered by range of system rules
 func (t *ConfigTree) SetSnat(rules ...string) int {
-	return t.SetSnatWithStartRuleNumber(1, rules...)
+	return t.SetRule(SnatRules, RuleSystem, rules...)
 }
 
 // SetWithoutCheckExisting set the config without checking any existing config with the same path
 // usually used for set multi-value keys
 func (t *ConfigTree) SetWithoutCheckExisting(config string) {
-	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", config))
+	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", formatPath(splitPath(config))))
 }
 
 // SetfWithoutCheckExisting set the config without checking any existing config with the same path
@@ -568,13 +569,21 @@ func (t *ConfigTree) Setf(f string, args ...interface{}) bool {
 }
 
 // Set if existing value is different from the config
-// delete the old one and set the new one
+// delete the old one and set the new one, or add it to a multi-value key
 func (t *ConfigTree) Set(config string) bool {
 	t.init()
-	cs := strings.Split(config, " ")
-	key := strings.Join(cs[:len(cs)-1], " ")
+	cs := splitPath(config)
+	if len(cs) == 0 {
+		return false
+	}
+
+	key := cs[:len(cs)-1]
 	value := cs[len(cs)-1]
-	keyNode := t.Get(key)
+	keyNode := t.Root.getPath(key)
+	if keyNode != nil && keyNode.ValueSize() > 1 {
+		// a multi-value key, the value added
+		return t.setValue(key, value, false)
+	}
 	if keyNode != nil && keyNode.ValueSize() > 0 {
 		// the key found
 		cvalue := keyNode.Value()
@@ -582,18 +591,15 @@ func (t *ConfigTree) Set(config string) bool {
 			keyNode.deleteNode(cvalue)
 			keyNode.addNode(value)
 			// the value is changed, delete the old one
-			t.changeCommands = append(t.changeCommands, fmt.Sprintf("$DELETE %s", key))
-			t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", config))
+			t.changeCommands = append(t.changeCommands, fmt.Sprintf("$DELETE %s", formatPath(key)))
+			t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", formatPath(cs)))
 			return true
 		} // the value is unchanged
 		return false
 	}
 	// the key not found
-	current := t.Root
-	for _, c := range cs {
-		current = current.addNode(c)
-	}
-	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", config))
+	t.Root.addPath(cs)
+	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$SET %s", formatPath(cs)))
 	return true
 }
 
@@ -626,8 +632,13 @@ func (t *ConfigTree) Delete(config string) bool {
 		return false
 	}
 
+	if n.isRule() {
+		t.releaseRule(n)
+	}
+
+	path := n.String()
 	n.deleteSelf()
-	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$DELETE %s", config))
+	t.changeCommands = append(t.changeCommands, fmt.Sprintf("$DELETE %s", path))
 	return true
 }
 
@@ -641,42 +652,11 @@ func (t *ConfigTree) Commands() []string {
 	return t.changeCommands
 }
 
-// ConfigTree to string
+// ConfigTree to string, one path of each leaf a line
 func (t *ConfigTree) String() string {
 	if t.Root == nil {
 		return ""
 	}
 
-	strs := make([]string, 0)
-	for _, n := range t.Root.children {
-		path := utils.Stack{}
-
-		var pathBuilder func(node *ConfigNode)
-		pathBuilder = func(node *ConfigNode) {
-			if node.children == nil {
-				path.Push(node.name)
-				strs = append(strs, func() string {
-					sl := path.ReverseSlice()
-					ss := make([]string, len(sl))
-					for i, s := range sl {
-						ss[i] = s.(string)
-					}
-					return strings.Join(ss, " ")
-				}())
-				path.Pop()
-
-				return
-			}
-
-			path.Push(node.name)
-			for _, cn := range node.children {
-				pathBuilder(cn)
-			}
-			path.Pop()
-		}
-
-		pathBuilder(n)
-	}
-
-	return strings.Join(strs, "\n")
+	return strings.Join(t.Root.leaves(), "\n")
 }
@@ -156,3 +156,31 @@ ABC E
 	fmt.Println(tree.CommandsAsString())
 	fmt.Println(tree.String())
 }
+
+func TestSetMultiValue(t *testing.T) {
+
+	tree := NewParserFromConfiguration(`
+interfaces {
+    ethernet eth0 {
+        address 10.0.0.1/24
+        address "10.0.0.2/24"
+    }
+}
+`).Tree
+
+	if !tree.Set("interfaces ethernet eth0 address 10.0.0.3/24") {
+		t.Fatalf("value should be added to multi-value key")
+	}
+	if tree.Set("interfaces ethernet eth0 address 10.0.0.2/24") {
+		t.Fatalf("value existing should be unchanged")
+	}
+
+	if values := tree.Get("interfaces ethernet eth0 address").Values(); len(values) != 3 {
+		t.Fatalf("values should be kept, %v got", values)
+	}
+
+	want := "$SET interfaces ethernet eth0 address 10.0.0.3/24"
+	if commands := tree.Commands(); len(commands) != 1 || commands[0] != want {
+		t.Fatalf("value should be set alone, %v got", commands)
+	}
+}